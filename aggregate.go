@@ -0,0 +1,138 @@
+package thyme
+
+import (
+	"regexp"
+	"time"
+)
+
+// AggregateOptions says which snapshots and windows Aggregate counts,
+// and how it groups their time. The zero value counts all of them,
+// grouped by application.
+type AggregateOptions struct {
+	// Group is how to group the time spent in windows. It defaults to
+	// grouping by application.
+	Group *Grouping
+
+	// Categories determine the category of windows, when grouping by
+	// category.
+	Categories *Categories
+
+	// Since and Until, if set, restrict the snapshots counted to those
+	// taken at or after Since and before Until.
+	Since, Until time.Time
+
+	// App, if set, restricts the windows counted to those whose
+	// application, as returned by AppID, it matches.
+	App *regexp.Regexp
+
+	// IdleThreshold, if set, stops counting windows as active once the
+	// user had been idle for that long, as Stream.WithoutIdle does.
+	IdleThreshold time.Duration
+
+	// IncludeLocked counts windows as active even while the screen was
+	// locked.
+	IncludeLocked bool
+
+	// SingleActive attributes the time of each snapshot to its focused
+	// window only, as Stream.WithoutSplitActive does, rather than
+	// splitting it among all its active windows.
+	SingleActive bool
+
+	// Interval is the time between snapshots the tracker was asked
+	// for. A snapshot stands for at most that long, so that periods
+	// without snapshots, e.g. while the computer was asleep, don't
+	// count. It defaults to the median time between snapshots.
+	Interval time.Duration
+}
+
+// Result is the time spent in each group of windows, as computed by
+// Aggregate.
+type Result struct {
+	// Start and End are the times of the first and last snapshots
+	// counted. They are zero if none were.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Snapshots is the number of snapshots counted.
+	Snapshots int `json:"snapshots"`
+
+	// ActiveSeconds is how long any of the windows counted was
+	// active, snapshots with several active windows counting for the
+	// shares of those counted (see Snapshot.ActiveShare).
+	ActiveSeconds float64 `json:"active_seconds"`
+
+	// GroupBy names the grouping of Totals, the time spent in each
+	// group, ordered as Grouping.Totals orders them.
+	GroupBy string   `json:"group_by"`
+	Totals  []*Total `json:"totals"`
+}
+
+// Aggregate returns the time spent in the windows of stream, which
+// must be in chronological order, grouped and filtered as opts says.
+// It is what `thyme top`, `thyme show -w json` and Grouping.Totals
+// compute their totals with.
+func Aggregate(stream *Stream, opts AggregateOptions) *Result {
+	g := opts.Group
+	if g == nil {
+		g, _ = LookupGrouping("app")
+	}
+	stream = stream.Between(opts.Since, opts.Until).WithoutIdle(opts.IdleThreshold)
+	if !opts.IncludeLocked {
+		stream = stream.WithoutLocked()
+	}
+	if opts.SingleActive {
+		stream = stream.WithoutSplitActive()
+	}
+	// How long snapshots stand for depends on their times only, so the
+	// windows filtered out don't change it.
+	var durations []time.Duration
+	if opts.Interval > 0 {
+		durations = intervalDurations(stream, opts.Interval)
+	} else {
+		durations = sampleDurations(stream)
+	}
+	if opts.App != nil {
+		stream = stream.matchingApps(opts.App)
+	}
+
+	result := &Result{Snapshots: len(stream.Snapshots), GroupBy: g.Name, Totals: g.totals(stream, opts.Categories, durations)}
+	if n := len(stream.Snapshots); n > 0 {
+		result.Start, result.End = stream.Snapshots[0].Time, stream.Snapshots[n-1].End()
+	}
+	for i, snap := range stream.Snapshots {
+		// With --app, only the shares of the active windows left
+		// count.
+		if n := len(snap.ActiveWindows()); n > 0 {
+			result.ActiveSeconds += durations[i].Seconds() * snap.ActiveShare() * float64(n)
+		}
+	}
+	return result
+}
+
+// matchingApps returns a copy of the stream containing only the
+// windows whose application rx matches. Active and AlsoActive are kept
+// as they are, so that the windows left still get the share of the
+// time they had.
+func (s *Stream) matchingApps(rx *regexp.Regexp) *Stream {
+	filtered := *s
+	filtered.Snapshots = make([]*Snapshot, 0, len(s.Snapshots))
+	for _, snap := range s.Snapshots {
+		c := *snap
+		c.Windows = make([]*Window, 0, len(snap.Windows))
+		kept := make(map[int64]bool)
+		for _, w := range snap.Windows {
+			if rx.MatchString(appID(w)) {
+				c.Windows = append(c.Windows, w)
+				kept[w.ID] = true
+			}
+		}
+		c.Visible = make([]int64, 0, len(snap.Visible))
+		for _, id := range snap.Visible {
+			if kept[id] {
+				c.Visible = append(c.Visible, id)
+			}
+		}
+		filtered.Snapshots = append(filtered.Snapshots, &c)
+	}
+	return &filtered
+}
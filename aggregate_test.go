@@ -0,0 +1,219 @@
+package thyme
+
+import (
+	"math"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// splitStream returns a stream of two snapshots taken a minute apart,
+// the first with the editor and the terminal active side by side, the
+// second with only the editor active.
+func splitStream() *Stream {
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	windows := []*Window{
+		{ID: 1, Name: "main.go - Code"},
+		{ID: 2, Name: "~ - Terminal"},
+	}
+	return &Stream{Interval: time.Minute, Snapshots: []*Snapshot{
+		{Time: start, Windows: windows, Active: 1, AlsoActive: []int64{2}, Visible: []int64{1, 2}},
+		{Time: start.Add(time.Minute), Windows: windows, Active: 1, Visible: []int64{1, 2}},
+	}}
+}
+
+// idleStream returns splitStream, with the user idle for 10 minutes
+// in its first snapshot.
+func idleStream() *Stream {
+	stream := splitStream()
+	stream.Snapshots[0].Idle = 10 * time.Minute
+	return stream
+}
+
+// lockedStream returns splitStream, with the screen locked in its
+// first snapshot.
+func lockedStream() *Stream {
+	stream := splitStream()
+	stream.Snapshots[0].Locked = true
+	return stream
+}
+
+// sleepStream returns splitStream followed, after the computer slept
+// for an hour, by a snapshot with only the terminal active.
+func sleepStream() *Stream {
+	stream := splitStream()
+	last := stream.Snapshots[len(stream.Snapshots)-1]
+	stream.Snapshots = append(stream.Snapshots, &Snapshot{Time: last.Time.Add(time.Hour), Windows: last.Windows, Active: 2, Visible: []int64{1, 2}})
+	return stream
+}
+
+func TestAggregate(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		stream func() *Stream
+		opts   AggregateOptions
+		active float64
+		totals map[string]float64
+	}{
+		{
+			name:   "split",
+			active: 120,
+			totals: map[string]float64{"Code": 90, "Terminal": 30},
+		},
+		{
+			name:   "single active",
+			opts:   AggregateOptions{SingleActive: true},
+			active: 120,
+			totals: map[string]float64{"Code": 120},
+		},
+		{
+			name:   "app sharing a snapshot",
+			opts:   AggregateOptions{App: regexp.MustCompile("Terminal")},
+			active: 30,
+			totals: map[string]float64{"Terminal": 30},
+		},
+		{
+			name:   "app with the focus",
+			opts:   AggregateOptions{App: regexp.MustCompile("Code")},
+			active: 90,
+			totals: map[string]float64{"Code": 90},
+		},
+		{
+			name:   "idle",
+			stream: idleStream,
+			opts:   AggregateOptions{IdleThreshold: 5 * time.Minute},
+			active: 60,
+			totals: map[string]float64{"Code": 60},
+		},
+		{
+			name:   "locked",
+			stream: lockedStream,
+			active: 60,
+			totals: map[string]float64{"Code": 60},
+		},
+		{
+			name:   "including locked",
+			stream: lockedStream,
+			opts:   AggregateOptions{IncludeLocked: true},
+			active: 120,
+			totals: map[string]float64{"Code": 90, "Terminal": 30},
+		},
+		{
+			name:   "asleep",
+			stream: sleepStream,
+			active: 180,
+			totals: map[string]float64{"Code": 90, "Terminal": 90},
+		},
+		{
+			name:   "asleep with an interval",
+			stream: sleepStream,
+			opts:   AggregateOptions{Interval: 2 * time.Minute},
+			active: 300,
+			totals: map[string]float64{"Code": 150, "Terminal": 150},
+		},
+		{
+			name:   "since",
+			opts:   AggregateOptions{Since: time.Date(2024, 3, 4, 9, 1, 0, 0, time.UTC)},
+			active: 60,
+			totals: map[string]float64{"Code": 60},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := splitStream
+			if tt.stream != nil {
+				stream = tt.stream
+			}
+			result := Aggregate(stream(), tt.opts)
+			if math.Abs(result.ActiveSeconds-tt.active) > 1e-9 {
+				t.Errorf("active for %gs, want %gs", result.ActiveSeconds, tt.active)
+			}
+			got := make(map[string]float64)
+			for _, total := range result.Totals {
+				if total.ActiveSeconds > 0 {
+					got[total.Label] = total.ActiveSeconds
+				}
+			}
+			if len(got) != len(tt.totals) {
+				t.Errorf("got totals %v, want %v", got, tt.totals)
+			}
+			for label, want := range tt.totals {
+				if math.Abs(got[label]-want) > 1e-9 {
+					t.Errorf("%s active for %gs, want %gs", label, got[label], want)
+				}
+			}
+		})
+	}
+}
+
+// TestAggregateCallers checks that the totals of the other outputs are
+// those computed by Aggregate, which counts the snapshots before the
+// computer slept for as long as they stand for rather than until the
+// next one.
+func TestAggregateCallers(t *testing.T) {
+	apps, _ := LookupGrouping("app")
+	titles, _ := LookupGrouping("title")
+	days, _ := LookupGrouping("day")
+	for _, tt := range []struct {
+		name   string
+		group  *Grouping
+		totals func(*Stream) map[string]float64
+	}{
+		{
+			name:   "summary apps",
+			group:  apps,
+			totals: func(s *Stream) map[string]float64 { return activeSeconds(Summarize(s).Apps) },
+		},
+		{
+			name:   "summary titles",
+			group:  titles,
+			totals: func(s *Stream) map[string]float64 { return activeSeconds(Summarize(s).Titles) },
+		},
+		{
+			name:  "summary groups",
+			group: days,
+			totals: func(s *Stream) map[string]float64 {
+				summary := Summarize(s)
+				summary.Group(s, days, nil)
+				return activeSeconds(summary.Groups)
+			},
+		},
+		{
+			name:  "group chart",
+			group: apps,
+			totals: func(s *Stream) map[string]float64 {
+				got := make(map[string]float64)
+				for label, minutes := range NewGroupChart(s, apps, nil).Series {
+					got[label] = float64(minutes * 60)
+				}
+				return got
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := sleepStream()
+			want := activeSeconds(Aggregate(stream, AggregateOptions{Group: tt.group, IncludeLocked: true}).Totals)
+			got := tt.totals(stream)
+			if len(got) != len(want) {
+				t.Errorf("got totals %v, want %v", got, want)
+			}
+			for label, seconds := range want {
+				// The group chart rounds to the minute.
+				if math.Abs(got[label]-seconds) > 30 {
+					t.Errorf("%s active for %gs, want %gs", label, got[label], seconds)
+				}
+			}
+		})
+	}
+}
+
+// activeSeconds returns the active time of each of totals that was
+// active at all, by label.
+func activeSeconds(totals []*Total) map[string]float64 {
+	seconds := make(map[string]float64)
+	for _, t := range totals {
+		if t.ActiveSeconds > 0 {
+			seconds[t.Label] = t.ActiveSeconds
+		}
+	}
+	return seconds
+}
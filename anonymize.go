@@ -0,0 +1,94 @@
+package thyme
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Anonymizer hides what a stream reveals about the user, such as the
+// applications they use and the titles of their windows, while keeping
+// its timing, so that it can be shared for analysis.
+type Anonymizer struct {
+	// key makes pseudonyms impossible to guess by hashing application
+	// names, and different each time a stream is anonymized.
+	key []byte
+
+	// pseudonyms maps names to their pseudonyms, and names the other
+	// way round.
+	pseudonyms, names map[string]string
+}
+
+// NewAnonymizer returns an Anonymizer with a random key, so that the
+// pseudonyms it gives are the same for all the streams it anonymizes,
+// but differ from those of any other Anonymizer.
+func NewAnonymizer() *Anonymizer {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return &Anonymizer{key: key, pseudonyms: make(map[string]string), names: make(map[string]string)}
+}
+
+// Pseudonym returns the pseudonym of name, made of prefix and a hash
+// of name, e.g. "app-7f3a". Different names get different pseudonyms.
+func (a *Anonymizer) Pseudonym(prefix, name string) string {
+	if p, exists := a.pseudonyms[prefix+"\x00"+name]; exists {
+		return p
+	}
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(prefix + "\x00" + name))
+	sum := hex.EncodeToString(mac.Sum(nil))
+	// Lengthen the hash until the pseudonym isn't taken.
+	p := prefix + "-" + sum[:4]
+	for n := 6; a.names[p] != ""; n += 2 {
+		p = prefix + "-" + sum[:n]
+	}
+	a.pseudonyms[prefix+"\x00"+name], a.names[p] = p, name
+	return p
+}
+
+// Names returns what each of the pseudonyms given so far stands for,
+// which reverses the anonymization.
+func (a *Anonymizer) Names() map[string]string {
+	names := make(map[string]string, len(a.names))
+	for p, name := range a.names {
+		names[p] = name
+	}
+	return names
+}
+
+// Anonymize returns a copy of s in which the name of each window is
+// replaced by the pseudonym of its application, as determined by
+// appID, and the projects by pseudonyms of their own. The titles,
+// URLs, processes and PIDs of windows are left out. Everything else,
+// including the times of snapshots and which windows were open,
+// visible and active, is kept.
+func (a *Anonymizer) Anonymize(s *Stream) *Stream {
+	anonymized := *s
+	anonymized.Snapshots = make([]*Snapshot, len(s.Snapshots))
+	for i, snap := range s.Snapshots {
+		copied := *snap
+		copied.Windows = make([]*Window, len(snap.Windows))
+		for j, w := range snap.Windows {
+			app := a.Pseudonym("app", appID(w))
+			copied.Windows[j] = &Window{
+				ID:         w.ID,
+				Desktop:    w.Desktop,
+				Name:       app,
+				X:          w.X,
+				Y:          w.Y,
+				Width:      w.Width,
+				Height:     w.Height,
+				Monitor:    w.Monitor,
+				Display:    w.Display,
+				FullScreen: w.FullScreen,
+				Partial:    w.Partial,
+			}
+		}
+		if snap.Project != "" {
+			copied.Project = a.Pseudonym("project", snap.Project)
+		}
+		anonymized.Snapshots[i] = &copied
+	}
+	return &anonymized
+}
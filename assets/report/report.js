@@ -0,0 +1,448 @@
+// report.js draws the charts of the page generated by `thyme show -w stats`.
+// It is inlined into the page and has no dependencies, so the report works
+// without network access.
+var thyme = (function () {
+  var svgNS = "http://www.w3.org/2000/svg";
+
+  var palette = [
+    "#4285f4", "#db4437", "#f4b400", "#0f9d58", "#ab47bc", "#00acc1",
+    "#ff7043", "#9e9d24", "#5c6bc0", "#f06292", "#00796b", "#c2185b",
+    "#7e57c2", "#8d6e63", "#26a69a", "#d4e157"
+  ];
+
+  // overrides are the colors of the labels set with setColors.
+  var overrides = {};
+
+  // setColors sets the colors of the labels colors lists, as read from
+  // ~/.thyme/colors.json, overriding those of the palette in every chart.
+  function setColors(colors) {
+    overrides = colors || {};
+  }
+
+  // icons are the icons of applications set with setIcons, as data URIs.
+  var icons = {};
+
+  // setIcons sets the icons of the applications icons lists, which bar charts
+  // of applications show next to their names.
+  function setIcons(i) {
+    icons = i || {};
+  }
+
+  // appBadge returns the icon of app, or its initial on a background of its
+  // color if it has none, so that every row of a chart lines up.
+  function appBadge(app) {
+    if (Object.prototype.hasOwnProperty.call(icons, app)) {
+      var img = document.createElement("img");
+      img.className = "app-icon";
+      img.src = icons[app];
+      img.alt = "";
+      return img;
+    }
+    var initial = document.createElement("span");
+    initial.className = "app-icon app-initial";
+    initial.style.background = color(app);
+    initial.textContent = (app.trim().charAt(0) || "?").toUpperCase();
+    return initial;
+  }
+
+  // color returns a color for label that is stable across charts and page
+  // loads: the one set with setColors, or one of the palette picked from the
+  // label alone.
+  function color(label) {
+    if (Object.prototype.hasOwnProperty.call(overrides, label)) {
+      return overrides[label];
+    }
+    var h = 0;
+    for (var i = 0; i < label.length; i++) {
+      h = (h * 31 + label.charCodeAt(i)) | 0;
+    }
+    return palette[Math.abs(h) % palette.length];
+  }
+
+  function el(name, attrs, parent) {
+    var e = document.createElementNS(svgNS, name);
+    for (var k in attrs) {
+      e.setAttribute(k, attrs[k]);
+    }
+    if (parent) {
+      parent.appendChild(e);
+    }
+    return e;
+  }
+
+  function pad(n) {
+    return n < 10 ? "0" + n : "" + n;
+  }
+
+  function formatTime(d) {
+    return pad(d.getHours()) + ":" + pad(d.getMinutes());
+  }
+
+  function formatDate(d) {
+    return d.getFullYear() + "-" + pad(d.getMonth() + 1) + "-" + pad(d.getDate());
+  }
+
+  function formatDuration(ms) {
+    var s = Math.round(ms / 1000);
+    var h = Math.floor(s / 3600), m = Math.floor((s % 3600) / 60);
+    if (h > 0) {
+      return h + "h" + pad(m) + "m";
+    }
+    if (m > 0) {
+      return m + "m" + pad(s % 60) + "s";
+    }
+    return s + "s";
+  }
+
+  var tickSteps = [60e3, 300e3, 900e3, 1800e3, 3600e3, 3 * 3600e3, 6 * 3600e3, 12 * 3600e3, 24 * 3600e3, 7 * 24 * 3600e3];
+
+  // timeline draws rows, a list of [group, label, start, end] tuples, as
+  // horizontal bars. Each group gets its own band of lanes; bars of the same
+  // group that overlap in time are stacked in separate lanes.
+  function timeline(container, rows) {
+    if (rows.length === 0) {
+      container.textContent = "No data.";
+      return;
+    }
+
+    var laneHeight = 20, groupGap = 10, axisHeight = 24, labelWidth = 80;
+    var width = Math.max(container.clientWidth, 600);
+
+    var groups = [], lanes = {}, min = Infinity, max = -Infinity;
+    rows.forEach(function (r) {
+      if (!lanes[r[0]]) {
+        lanes[r[0]] = [];
+        groups.push(r[0]);
+      }
+      min = Math.min(min, r[2].getTime());
+      max = Math.max(max, r[3].getTime());
+    });
+    if (max <= min) {
+      max = min + 60e3;
+    }
+
+    rows.slice().sort(function (a, b) { return a[2] - b[2]; }).forEach(function (r) {
+      var start = r[2].getTime(), end = r[3].getTime();
+      var group = lanes[r[0]], i = 0;
+      while (i < group.length && group[i].end > start) {
+        i++;
+      }
+      if (i === group.length) {
+        group.push({ end: -Infinity, bars: [] });
+      }
+      group[i].end = end;
+      group[i].bars.push({ label: r[1], start: start, end: end });
+    });
+
+    var height = axisHeight;
+    groups.forEach(function (g) {
+      height += lanes[g].length * laneHeight + groupGap;
+    });
+
+    var svg = el("svg", { width: width, height: height, "class": "timeline" });
+    var x = function (t) {
+      return labelWidth + (t - min) / (max - min) * (width - labelWidth - 10);
+    };
+
+    var step = tickSteps[tickSteps.length - 1];
+    for (var i = 0; i < tickSteps.length; i++) {
+      if ((max - min) / tickSteps[i] <= 12) {
+        step = tickSteps[i];
+        break;
+      }
+    }
+    var offset = new Date(min).getTimezoneOffset() * 60e3;
+    for (var t = Math.ceil((min - offset) / step) * step + offset; t <= max; t += step) {
+      var tx = x(t), d = new Date(t);
+      el("line", { x1: tx, x2: tx, y1: axisHeight - 6, y2: height, "class": "grid" }, svg);
+      el("text", { x: tx, y: axisHeight - 10, "class": "tick" }, svg).textContent =
+        step >= 24 * 3600e3 || (d.getHours() === 0 && d.getMinutes() === 0) ? formatDate(d) : formatTime(d);
+    }
+
+    var y = axisHeight;
+    groups.forEach(function (g, gi) {
+      var bandHeight = lanes[g].length * laneHeight;
+      el("rect", { x: 0, y: y, width: width, height: bandHeight, "class": gi % 2 ? "band odd" : "band" }, svg);
+      el("text", { x: 4, y: y + 14, "class": "group" }, svg).textContent = g;
+      lanes[g].forEach(function (lane, li) {
+        lane.bars.forEach(function (b) {
+          var bx = x(b.start), bw = Math.max(x(b.end) - bx, 1);
+          var bar = el("g", {}, svg);
+          el("rect", { x: bx, y: y + li * laneHeight + 2, width: bw, height: laneHeight - 4, fill: color(b.label) }, bar);
+          el("title", {}, bar).textContent = b.label + "\n" + formatTime(new Date(b.start)) + " – " +
+            formatTime(new Date(b.end)) + " (" + formatDuration(b.end - b.start) + ")";
+          var chars = Math.floor((bw - 6) / 7);
+          if (chars >= 3) {
+            el("text", { x: bx + 3, y: y + li * laneHeight + 14, "class": "bar-label" }, bar).textContent =
+              b.label.length > chars ? b.label.slice(0, chars - 1) + "…" : b.label;
+          }
+        });
+      });
+      y += bandHeight + groupGap;
+    });
+
+    container.appendChild(svg);
+  }
+
+  // barChart draws bars, a list of [label, value] pairs, as a horizontal bar
+  // chart. formatValue, if given, formats the values shown next to the bars.
+  // If apps is set, the labels are applications, shown with their icons.
+  function barChart(container, title, xLabel, yLabel, bars, formatValue, apps) {
+    formatValue = formatValue || String;
+    var h = document.createElement("h3");
+    h.textContent = title;
+    container.appendChild(h);
+    if (bars.length === 0) {
+      container.appendChild(document.createTextNode("No data."));
+      return;
+    }
+
+    var max = 0;
+    bars.forEach(function (b) {
+      max = Math.max(max, b[1]);
+    });
+
+    var table = document.createElement("table");
+    table.className = "bar-chart";
+    var head = table.insertRow();
+    head.className = "axis";
+    head.insertCell().textContent = xLabel;
+    head.insertCell().textContent = yLabel;
+    bars.forEach(function (b) {
+      var row = table.insertRow();
+      var label = row.insertCell();
+      label.className = "bar-label";
+      if (apps) {
+        label.appendChild(appBadge(b[0]));
+      }
+      label.appendChild(document.createTextNode(b[0]));
+      label.title = b[0];
+      var cell = row.insertCell();
+      var bar = document.createElement("div");
+      bar.className = "bar";
+      bar.style.width = (max > 0 ? 90 * b[1] / max : 0) + "%";
+      bar.style.background = color(b[0]);
+      var value = document.createElement("span");
+      value.className = "bar-value";
+      value.textContent = formatValue(b[1]);
+      cell.appendChild(bar);
+      cell.appendChild(value);
+    });
+    container.appendChild(table);
+  }
+
+  // heatmap draws a grid with rowLabels.length rows and colLabels.length
+  // columns. cells is a list of [row, col, seconds, label, topApp] tuples for
+  // the cells with activity; the darker a cell, the more seconds it has.
+  function heatmap(container, title, rowLabels, colLabels, cells) {
+    var h = document.createElement("h3");
+    h.textContent = title;
+    container.appendChild(h);
+    if (cells.length === 0) {
+      container.appendChild(document.createTextNode("No data."));
+      return;
+    }
+
+    var max = 0;
+    cells.forEach(function (c) {
+      max = Math.max(max, c[2]);
+    });
+
+    var table = document.createElement("table");
+    table.className = "heatmap";
+    var head = table.insertRow();
+    head.className = "axis";
+    head.insertCell();
+    colLabels.forEach(function (l) {
+      head.insertCell().textContent = l;
+    });
+    var grid = rowLabels.map(function (l) {
+      var row = table.insertRow();
+      var label = row.insertCell();
+      label.className = "axis";
+      label.textContent = l;
+      return colLabels.map(function () {
+        var cell = row.insertCell();
+        cell.className = "cell";
+        return cell;
+      });
+    });
+    cells.forEach(function (c) {
+      var cell = grid[c[0]][c[1]];
+      cell.style.background = "rgba(15, 157, 88, " + (0.15 + 0.85 * c[2] / max).toFixed(3) + ")";
+      cell.title = c[3] + ": " + formatDuration(c[2] * 1000) + " active, mostly in " + c[4];
+    });
+    container.appendChild(table);
+  }
+
+  // lineChart draws points, a list of [label, value] pairs, as a line over a
+  // vertical axis from 0 to yMax, with the labels along the horizontal axis.
+  function lineChart(container, title, points, yMax) {
+    var h = document.createElement("h3");
+    h.textContent = title;
+    container.appendChild(h);
+    if (points.length === 0) {
+      container.appendChild(document.createTextNode("No data."));
+      return;
+    }
+
+    var height = 160, axisHeight = 24, labelWidth = 32, padding = 12;
+    var width = Math.max(container.clientWidth, 600);
+    var svg = el("svg", {width: width, height: height + axisHeight, "class": "line-chart"}, container);
+    var step = points.length > 1 ? (width - labelWidth - 2 * padding) / (points.length - 1) : 0;
+    var x = function (i) {
+      return labelWidth + padding + i * step;
+    };
+    var y = function (v) {
+      return padding + (height - 2 * padding) * (1 - v / yMax);
+    };
+
+    [0, yMax / 2, yMax].forEach(function (v) {
+      el("line", {x1: labelWidth, x2: width, y1: y(v), y2: y(v), "class": "grid"}, svg);
+      el("text", {x: labelWidth - 6, y: y(v) + 4, "class": "tick y"}, svg).textContent = v;
+    });
+    var every = Math.ceil(points.length / Math.floor((width - labelWidth) / 60));
+    el("polyline", {
+      points: points.map(function (p, i) {
+        return x(i) + "," + y(p[1]);
+      }).join(" "),
+      "class": "line"
+    }, svg);
+    points.forEach(function (p, i) {
+      var dot = el("circle", {cx: x(i), cy: y(p[1]), r: 3, "class": "point"}, svg);
+      el("title", {}, dot).textContent = p[0] + ": " + p[1];
+      if (i % every === 0) {
+        el("text", {x: x(i), y: height + axisHeight - 6, "class": "tick"}, svg).textContent = p[0];
+      }
+    });
+  }
+
+  // stackedArea draws series, a list of [label, shares] pairs, as bands
+  // stacked on top of each other, where shares are the share of each slot of
+  // the day, slotMinutes long, that goes to the label. Each slot is drawn as
+  // a step, so that slots without shares are left blank.
+  function stackedArea(container, title, series, slotMinutes) {
+    var h = document.createElement("h3");
+    h.textContent = title;
+    container.appendChild(h);
+    if (series.length === 0) {
+      container.appendChild(document.createTextNode("No data."));
+      return;
+    }
+
+    var height = 200, axisHeight = 24, labelWidth = 40, padding = 12;
+    var width = Math.max(container.clientWidth, 600);
+    var slots = series[0][1].length;
+    var svg = el("svg", {width: width, height: height + axisHeight, "class": "line-chart stacked-area"}, container);
+    var step = (width - labelWidth - 2 * padding) / slots;
+    var x = function (i) {
+      return labelWidth + padding + i * step;
+    };
+    var y = function (v) {
+      return padding + (height - 2 * padding) * (1 - v);
+    };
+
+    [0, 0.5, 1].forEach(function (v) {
+      el("line", {x1: labelWidth, x2: width, y1: y(v), y2: y(v), "class": "grid"}, svg);
+      el("text", {x: labelWidth - 6, y: y(v) + 4, "class": "tick y"}, svg).textContent = (v * 100) + "%";
+    });
+    var perHour = 60 / slotMinutes;
+    for (var hour = 0; hour <= 24; hour += 3) {
+      el("text", {x: x(hour * perHour), y: height + axisHeight - 6, "class": "tick"}, svg).textContent = pad(hour % 24) + ":00";
+    }
+
+    var base = [];
+    for (var i = 0; i < slots; i++) {
+      base.push(0);
+    }
+    series.forEach(function (s) {
+      var top = [], bottom = [];
+      s[1].forEach(function (v, i) {
+        top.push(x(i) + "," + y(base[i] + v), x(i + 1) + "," + y(base[i] + v));
+        bottom.unshift(x(i + 1) + "," + y(base[i]), x(i) + "," + y(base[i]));
+        base[i] += v;
+      });
+      var band = el("polygon", {points: top.concat(bottom).join(" "), fill: color(s[0])}, svg);
+      el("title", {}, band).textContent = s[0];
+    });
+
+    var legend = document.createElement("div");
+    legend.className = "legend";
+    series.forEach(function (s) {
+      var item = document.createElement("span");
+      var swatch = document.createElement("span");
+      swatch.className = "swatch";
+      swatch.style.background = color(s[0]);
+      item.appendChild(swatch);
+      item.appendChild(document.createTextNode(s[0]));
+      legend.appendChild(item);
+    });
+    container.appendChild(legend);
+  }
+
+  // weekdayBands draws rows, a list of [label, start, end, startDev, endDev,
+  // days] arrays with times in minutes since midnight, as one band per row
+  // from start to end, whose fainter ends span a standard deviation on either
+  // side of them.
+  function weekdayBands(container, title, rows) {
+    var h = document.createElement("h3");
+    h.textContent = title;
+    container.appendChild(h);
+    if (rows.length === 0) {
+      container.appendChild(document.createTextNode("No data."));
+      return;
+    }
+
+    var rowHeight = 24, axisHeight = 24, labelWidth = 90, padding = 12;
+    var width = Math.max(container.clientWidth, 600);
+    var svg = el("svg", {width: width, height: rows.length * rowHeight + axisHeight, "class": "timeline weekday-hours"}, container);
+    var x = function (minutes) {
+      minutes = Math.min(Math.max(minutes, 0), 24 * 60);
+      return labelWidth + (width - labelWidth - padding) * minutes / (24 * 60);
+    };
+    var clock = function (minutes) {
+      minutes = Math.round(minutes);
+      return pad(Math.floor(minutes / 60) % 24) + ":" + pad(minutes % 60);
+    };
+
+    for (var hour = 0; hour <= 24; hour += 3) {
+      el("line", {x1: x(hour * 60), x2: x(hour * 60), y1: 0, y2: rows.length * rowHeight, "class": "grid"}, svg);
+      el("text", {x: x(hour * 60), y: rows.length * rowHeight + axisHeight - 6, "class": "tick"}, svg).textContent = pad(hour % 24) + ":00";
+    }
+    rows.forEach(function (r, i) {
+      var top = i * rowHeight + 4, height = rowHeight - 8;
+      el("text", {x: 0, y: top + height - 3, "class": "group"}, svg).textContent = r[0];
+      var fill = color(r[0]);
+      [[r[1] - r[3], r[1] + r[3]], [r[2] - r[4], r[2] + r[4]]].forEach(function (dev) {
+        el("rect", {x: x(dev[0]), y: top, width: Math.max(x(dev[1]) - x(dev[0]), 1), height: height, fill: fill, "fill-opacity": 0.3}, svg);
+      });
+      var band = el("rect", {x: x(r[1]), y: top + 3, width: Math.max(x(r[2]) - x(r[1]), 1), height: height - 6, fill: fill}, svg);
+      el("title", {}, band).textContent = r[0] + ": " + clock(r[1]) + " (±" + formatDuration(r[3] * 60 * 1000) + ") to " +
+        clock(r[2]) + " (±" + formatDuration(r[4] * 60 * 1000) + "), over " + r[5] + (r[5] === 1 ? " day" : " days");
+    });
+  }
+
+  // onLoad calls f once the page has been parsed, so charts can be drawn into
+  // elements that follow the script that draws them.
+  function onLoad(f) {
+    if (document.readyState === "loading") {
+      document.addEventListener("DOMContentLoaded", f);
+    } else {
+      f();
+    }
+  }
+
+  return {
+    color: color,
+    setColors: setColors,
+    setIcons: setIcons,
+    formatDuration: formatDuration,
+    timeline: timeline,
+    barChart: barChart,
+    heatmap: heatmap,
+    lineChart: lineChart,
+    stackedArea: stackedArea,
+    weekdayBands: weekdayBands,
+    onLoad: onLoad
+  };
+})();
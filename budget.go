@@ -0,0 +1,164 @@
+package thyme
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Budget limits how long the applications whose names match Match
+// may be active each day.
+type Budget struct {
+	Match *regexp.Regexp
+	Limit time.Duration
+}
+
+// LoadBudgets reads budgets from the JSON file at path, which maps
+// application name regexes to daily limits written as Go durations,
+// e.g.
+//
+//	{"^Slack$": "30m", "Chrome|Firefox": "2h"}
+//
+// A missing file yields no budgets rather than an error.
+func LoadBudgets(path string) ([]*Budget, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("could not parse budgets file %s: %s", path, err)
+	}
+
+	var budgets []*Budget
+	for pattern, limit := range raw {
+		rx, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid budget pattern %q in %s: %s", pattern, path, err)
+		}
+		d, err := time.ParseDuration(limit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid budget limit %q for %q in %s: %s", limit, pattern, path, err)
+		}
+		budgets = append(budgets, &Budget{Match: rx, Limit: d})
+	}
+	sort.Slice(budgets, func(a, b int) bool { return budgets[a].Match.String() < budgets[b].Match.String() })
+	return budgets, nil
+}
+
+// Notifier alerts the user about something that needs their
+// attention.
+type Notifier interface {
+	Notify(title, message string) error
+}
+
+// NewNotifier returns a Notifier that shows desktop notifications
+// using the tools available on the current OS. Where there are none,
+// notifications are logged instead.
+func NewNotifier() Notifier {
+	switch runtime.GOOS {
+	case "darwin":
+		return osascriptNotifier{}
+	case "windows":
+		return logNotifier{}
+	default:
+		return notifySendNotifier{}
+	}
+}
+
+// notifySendNotifier shows notifications with `notify-send`.
+type notifySendNotifier struct{}
+
+func (notifySendNotifier) Notify(title, message string) error {
+	if out, err := exec.Command("notify-send", title, message).CombinedOutput(); err != nil {
+		return fmt.Errorf("notify-send failed with error: %s, output was: %s", err, string(out))
+	}
+	return nil
+}
+
+// osascriptNotifier shows notifications with AppleScript's `display
+// notification`.
+type osascriptNotifier struct{}
+
+func (osascriptNotifier) Notify(title, message string) error {
+	quote := func(s string) string {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+	}
+	script := "display notification " + quote(message) + " with title " + quote(title)
+	if out, err := exec.Command("osascript", "-e", script).CombinedOutput(); err != nil {
+		return fmt.Errorf("AppleScript error: %s, output was:\n%s", err, string(out))
+	}
+	return nil
+}
+
+// logNotifier writes notifications to the log.
+type logNotifier struct{}
+
+func (logNotifier) Notify(title, message string) error {
+	slog.Warn(title + ": " + message)
+	return nil
+}
+
+// BudgetWatcher keeps a running total of the time spent today in the
+// applications covered by a list of budgets, and notifies the user
+// once per day for each budget that is exceeded.
+type BudgetWatcher struct {
+	budgets  []*Budget
+	notifier Notifier
+
+	day     string
+	spent   []time.Duration
+	alerted []bool
+}
+
+// NewBudgetWatcher returns a BudgetWatcher that enforces budgets by
+// sending alerts to notifier.
+func NewBudgetWatcher(budgets []*Budget, notifier Notifier) *BudgetWatcher {
+	return &BudgetWatcher{budgets: budgets, notifier: notifier}
+}
+
+// Observe attributes d, the time the snapshot stands for, to the
+// application active in snap and sends a notification for every
+// budget this pushes over its limit.
+func (bw *BudgetWatcher) Observe(snap *Snapshot, d time.Duration) error {
+	if day := snap.Time.Format("2006-01-02"); day != bw.day {
+		bw.day = day
+		bw.spent = make([]time.Duration, len(bw.budgets))
+		bw.alerted = make([]bool, len(bw.budgets))
+	}
+
+	active := snap.ActiveWindow()
+	if active == nil {
+		return nil
+	}
+	app := appID(active)
+
+	var errs []string
+	for i, budget := range bw.budgets {
+		if !budget.Match.MatchString(app) {
+			continue
+		}
+		bw.spent[i] += d
+		if bw.alerted[i] || bw.spent[i] <= budget.Limit {
+			continue
+		}
+		bw.alerted[i] = true
+		msg := fmt.Sprintf("You've spent %s in %s today, over your budget of %s.", bw.spent[i].Round(time.Minute), app, budget.Limit)
+		if err := bw.notifier.Notify("Thyme budget exceeded", msg); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("could not send budget alert: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+package thyme
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DayTotals are the totals of the applications active on a day.
+type DayTotals struct {
+	// Date is the day, as midnight UTC.
+	Date time.Time `json:"date"`
+
+	// Apps are the totals of each application, ordered by decreasing
+	// active time.
+	Apps []*Total `json:"apps"`
+}
+
+// DayTotalsOptions say how DayTotaler.DayTotals counts time.
+type DayTotalsOptions struct {
+	// Location is the time zone days are those of.
+	Location *time.Location
+
+	// IdleThreshold and IncludeLocked filter the snapshots as
+	// Stream.WithoutIdle and Stream.WithoutLocked do.
+	IdleThreshold time.Duration
+	IncludeLocked bool
+
+	// SingleActive attributes the time of snapshots to their focused
+	// window only, as Stream.WithoutSplitActive does.
+	SingleActive bool
+
+	// Rebuild discards the cached totals and computes them anew.
+	Rebuild bool
+}
+
+// key identifies the options in the cache. Totals counted with other
+// options are cached separately.
+func (o *DayTotalsOptions) key() string {
+	zone := o.Location.String()
+	if o.Location == time.Local {
+		zone = LocalZone(time.Now())
+	}
+	key := fmt.Sprintf("tz=%s idle=%s locked=%t", zone, o.IdleThreshold, o.IncludeLocked)
+	// SingleActive only goes in the key when set, so that the totals
+	// cached before it was an option stay valid.
+	if o.SingleActive {
+		key += " single"
+	}
+	return key
+}
+
+// DayTotaler is implemented by stores that cache the totals of each
+// day, so that a long history needn't be read again to total it.
+type DayTotaler interface {
+	// DayTotals returns the totals of each day with snapshots, in
+	// chronological order. The days before the last one are cached,
+	// and only the snapshots taken since are read to bring the cache
+	// up to date; the last day, which may still be going on, is
+	// totaled anew every time.
+	DayTotals(opts DayTotalsOptions) ([]*DayTotals, error)
+}
+
+var _ DayTotaler = (*sqliteStore)(nil)
+
+// DayTotals implements DayTotaler with the day_totals table. As only
+// the snapshots of the days being totaled are read, how long each
+// stands for is capped at the median time between them rather than
+// between all the snapshots, so the totals may differ slightly from
+// those computed from the whole stream.
+func (s *sqliteStore) DayTotals(opts DayTotalsOptions) ([]*DayTotals, error) {
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+	key := opts.key()
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if opts.Rebuild {
+		for _, table := range []string{"day_totals_state", "day_totals", "day_totals_stale"} {
+			if _, err := tx.Exec("DELETE FROM "+table+" WHERE options = ?", key); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// through is the start of the first day that isn't cached.
+	var through time.Time
+	switch err := tx.QueryRow("SELECT through FROM day_totals_state WHERE options = ?", key).Scan(&through); err {
+	case nil, sql.ErrNoRows:
+	default:
+		return nil, fmt.Errorf("could not read day totals cache: %s", err)
+	}
+	if err := refreshStaleDays(tx, key, through, opts); err != nil {
+		return nil, err
+	}
+	fresh, err := querySnapshots(tx, through, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	days := totalDays(fresh.In(opts.Location), opts)
+
+	// All but the last day are complete.
+	if len(days) > 1 {
+		if err := cacheDayTotals(tx, key, days[:len(days)-1]); err != nil {
+			return nil, err
+		}
+		y, m, d := days[len(days)-1].Date.Date()
+		through = time.Date(y, m, d, 0, 0, 0, 0, opts.Location)
+		if _, err := tx.Exec("INSERT OR REPLACE INTO day_totals_state(options, through) VALUES(?, ?)", key, through); err != nil {
+			return nil, fmt.Errorf("could not cache day totals: %s", err)
+		}
+	}
+
+	cached, err := cachedDayTotals(tx, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	if len(days) > 0 {
+		last := days[len(days)-1]
+		if n := len(cached); n > 0 && cached[n-1].Date.Equal(last.Date) {
+			cached = cached[:n-1]
+		}
+		cached = append(cached, last)
+	}
+	return cached, nil
+}
+
+// refreshStaleDays totals anew the cached days before through that
+// snapshots were added to or deleted from since they were cached, as
+// recorded in day_totals_stale by the triggers on the data table, in
+// the cache of the options identified by key.
+func refreshStaleDays(tx *sql.Tx, key string, through time.Time, opts DayTotalsOptions) error {
+	rows, err := tx.Query("SELECT day FROM day_totals_stale WHERE options = ?", key)
+	if err != nil {
+		return err
+	}
+	var stale []time.Time
+	seen := make(map[time.Time]bool)
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			rows.Close()
+			return err
+		}
+		utc, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("invalid day %q in day totals cache: %s", day, err)
+		}
+		// A UTC date overlaps one or two days of opts.Location.
+		for _, t := range []time.Time{utc, utc.AddDate(0, 0, 1).Add(-time.Nanosecond)} {
+			y, m, d := t.In(opts.Location).Date()
+			start := time.Date(y, m, d, 0, 0, 0, 0, opts.Location)
+			if start.Before(through) && !seen[start] {
+				seen[start] = true
+				stale = append(stale, start)
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, start := range stale {
+		if _, err := tx.Exec("DELETE FROM day_totals WHERE options = ? AND day = ?", key, start.Format("2006-01-02")); err != nil {
+			return err
+		}
+		snaps, err := querySnapshots(tx, start, start.AddDate(0, 0, 1))
+		if err != nil {
+			return err
+		}
+		if err := cacheDayTotals(tx, key, totalDays(snaps.In(opts.Location), opts)); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("DELETE FROM day_totals_stale WHERE options = ?", key)
+	return err
+}
+
+// querySnapshots returns the snapshots taken from since and before
+// until, or until the last one if until is zero.
+func querySnapshots(tx *sql.Tx, since, until time.Time) (*Stream, error) {
+	query, args := "SELECT value FROM data WHERE julianday(time) >= julianday(?)", []any{since}
+	if !until.IsZero() {
+		query, args = query+" AND julianday(time) < julianday(?)", append(args, until)
+	}
+	rows, err := tx.Query(query+" ORDER BY time", args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanStream(rows)
+}
+
+// cacheDayTotals stores the totals of days, which must be complete, in
+// the cache of the options identified by key.
+func cacheDayTotals(tx *sql.Tx, key string, days []*DayTotals) error {
+	for _, d := range days {
+		for _, t := range d.Apps {
+			if _, err := tx.Exec("INSERT OR REPLACE INTO day_totals(options, day, label, active_seconds, visible_seconds, open_seconds, active_samples, visible_samples, open_samples) VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)",
+				key, d.Date.Format("2006-01-02"), t.Label, t.ActiveSeconds, t.VisibleSeconds, t.OpenSeconds, t.ActiveSamples, t.VisibleSamples, t.OpenSamples); err != nil {
+				return fmt.Errorf("could not cache day totals: %s", err)
+			}
+		}
+	}
+	return nil
+}
+
+// cachedDayTotals reads the cached totals of the options identified by
+// key.
+func cachedDayTotals(tx *sql.Tx, key string) ([]*DayTotals, error) {
+	rows, err := tx.Query("SELECT day, label, active_seconds, visible_seconds, open_seconds, active_samples, visible_samples, open_samples FROM day_totals WHERE options = ? ORDER BY day, active_seconds DESC, label", key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var days []*DayTotals
+	for rows.Next() {
+		var day string
+		t := &Total{}
+		if err := rows.Scan(&day, &t.Label, &t.ActiveSeconds, &t.VisibleSeconds, &t.OpenSeconds, &t.ActiveSamples, &t.VisibleSamples, &t.OpenSamples); err != nil {
+			return nil, err
+		}
+		date, err := time.Parse("2006-01-02", day)
+		if err != nil {
+			return nil, fmt.Errorf("invalid day %q in day totals cache: %s", day, err)
+		}
+		if n := len(days); n == 0 || !days[n-1].Date.Equal(date) {
+			days = append(days, &DayTotals{Date: date})
+		}
+		days[len(days)-1].Apps = append(days[len(days)-1].Apps, t)
+	}
+	return days, rows.Err()
+}
+
+// TotalDays returns the totals of each day of stream, which must be in
+// chronological order, as DayTotaler.DayTotals does, for streams read
+// from stores that don't cache them. If opts.Location is nil, days
+// are those of the location of the snapshots' times.
+func TotalDays(stream *Stream, opts DayTotalsOptions) []*DayTotals {
+	if opts.Location != nil {
+		stream = stream.In(opts.Location)
+	}
+	return totalDays(stream, opts)
+}
+
+// totalDays returns the totals of each day of stream, whose times must
+// be in the location days are those of, filtered as opts says.
+func totalDays(stream *Stream, opts DayTotalsOptions) []*DayTotals {
+	stream = stream.WithoutIdle(opts.IdleThreshold)
+	if !opts.IncludeLocked {
+		stream = stream.WithoutLocked()
+	}
+	if opts.SingleActive {
+		stream = stream.WithoutSplitActive()
+	}
+	durations := sampleDurations(stream)
+	apps, _ := LookupGrouping("app")
+	var days []*DayTotals
+	// How long the last snapshot of a day stands for depends on when
+	// the first of the next one was taken, so durations are those of
+	// the whole stream.
+	start := 0
+	for _, d := range SplitDays(stream) {
+		end := start + len(d.Stream.Snapshots)
+		days = append(days, &DayTotals{Date: d.Date, Apps: apps.totals(d.Stream, nil, durations[start:end])})
+		start = end
+	}
+	return days
+}
+
+// SumDayTotals adds up the totals of each application over days, and
+// returns them ordered by decreasing active time.
+func SumDayTotals(days []*DayTotals) []*Total {
+	sums := make(map[string]*Total)
+	for _, d := range days {
+		for _, t := range d.Apps {
+			sum := sums[t.Label]
+			if sum == nil {
+				sum = &Total{Label: t.Label}
+				sums[t.Label] = sum
+			}
+			sum.ActiveSeconds += t.ActiveSeconds
+			sum.VisibleSeconds += t.VisibleSeconds
+			sum.OpenSeconds += t.OpenSeconds
+			sum.ActiveSamples += t.ActiveSamples
+			sum.VisibleSamples += t.VisibleSamples
+			sum.OpenSamples += t.OpenSamples
+		}
+	}
+	list := make([]*Total, 0, len(sums))
+	for _, t := range sums {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if list[a].ActiveSeconds != list[b].ActiveSeconds {
+			return list[a].ActiveSeconds > list[b].ActiveSeconds
+		}
+		return list[a].Label < list[b].Label
+	})
+	return list
+}
@@ -0,0 +1,147 @@
+package thyme
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// openTestSQLite opens a sqlite store in a file that is removed at the
+// end of the test.
+func openTestSQLite(t *testing.T) *sqliteStore {
+	t.Helper()
+	s, _, err := openSQLiteStore(filepath.Join(t.TempDir(), "thyme.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// dayStream returns a stream with a snapshot every interval from 9:00
+// to 10:00 UTC on each of days days from March 4th 2024, alternating
+// between two applications every ten minutes.
+func dayStream(days int, interval time.Duration) *Stream {
+	stream := &Stream{Interval: interval}
+	windows := []*Window{{ID: 1, Name: "main.go - Code"}, {ID: 2, Name: "~ - Terminal"}}
+	for day := range days {
+		start := time.Date(2024, 3, 4+day, 9, 0, 0, 0, time.UTC)
+		for at := start; at.Before(start.Add(time.Hour)); at = at.Add(interval) {
+			active := int64(1 + int(at.Sub(start)/(10*time.Minute))%2)
+			stream.Snapshots = append(stream.Snapshots, &Snapshot{Time: at, Windows: windows, Active: active, Visible: []int64{1, 2}, Interval: interval})
+		}
+	}
+	return stream
+}
+
+func appendStream(t *testing.T, s Store, stream *Stream) {
+	t.Helper()
+	for _, snap := range stream.Snapshots {
+		if err := s.Append(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// checkDayTotals checks that the days of got have the same totals as
+// those of want, going by their labels and active time.
+func checkDayTotals(t *testing.T, got, want []*DayTotals) {
+	t.Helper()
+	summarize := func(days []*DayTotals) map[string]map[string]float64 {
+		m := make(map[string]map[string]float64)
+		for _, d := range days {
+			apps := make(map[string]float64)
+			for _, a := range d.Apps {
+				apps[a.Label] = a.ActiveSeconds
+			}
+			m[d.Date.Format("2006-01-02")] = apps
+		}
+		return m
+	}
+	if g, w := summarize(got), summarize(want); !reflect.DeepEqual(g, w) {
+		t.Errorf("got day totals %v, want %v", g, w)
+	}
+}
+
+func TestDayTotals(t *testing.T) {
+	s := openTestSQLite(t)
+	stream := dayStream(3, time.Minute)
+	appendStream(t, s, stream)
+	opts := DayTotalsOptions{Location: time.UTC}
+	for range 2 {
+		// Totaled anew, then read from the cache.
+		days, err := s.DayTotals(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkDayTotals(t, days, TotalDays(stream, opts))
+	}
+	if got := len(TotalDays(stream, opts)); got != 3 {
+		t.Fatalf("got %d days, want 3", got)
+	}
+}
+
+func TestDayTotalsOnlyStaleDaysRecomputed(t *testing.T) {
+	s := openTestSQLite(t)
+	stream := dayStream(4, time.Minute)
+	appendStream(t, s, stream)
+	opts := DayTotalsOptions{Location: time.UTC}
+	if _, err := s.DayTotals(opts); err != nil {
+		t.Fatal(err)
+	}
+	// Mark the cached totals of the third day, so as to tell whether
+	// they are computed again.
+	if _, err := s.db.Exec("UPDATE day_totals SET active_seconds = 1234 WHERE day = '2024-03-06'"); err != nil {
+		t.Fatal(err)
+	}
+
+	// As thyme track --retention does, delete the first day and part
+	// of the second.
+	before := time.Date(2024, 3, 5, 9, 30, 0, 0, time.UTC)
+	if _, err := s.Prune(before); err != nil {
+		t.Fatal(err)
+	}
+	// And add a snapshot before the end of the cached days, as thyme
+	// import may.
+	late := &Snapshot{Time: time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC), Windows: []*Window{{ID: 3, Name: "thyme - Firefox"}}, Active: 3, Interval: time.Minute}
+	if err := s.Append(late); err != nil {
+		t.Fatal(err)
+	}
+
+	days, err := s.DayTotals(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kept []*Snapshot
+	for _, snap := range stream.Snapshots {
+		if !snap.Time.Before(before) {
+			kept = append(kept, snap)
+		}
+	}
+	kept = append(kept[:30], append([]*Snapshot{late}, kept[30:]...)...)
+	want := TotalDays(&Stream{Interval: time.Minute, Snapshots: kept}, opts)
+	for _, a := range want[1].Apps {
+		a.ActiveSeconds = 1234
+	}
+	checkDayTotals(t, days, want)
+}
+
+func TestDayTotalsRebuild(t *testing.T) {
+	s := openTestSQLite(t)
+	stream := dayStream(3, time.Minute)
+	appendStream(t, s, stream)
+	opts := DayTotalsOptions{Location: time.UTC}
+	if _, err := s.DayTotals(opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.db.Exec("UPDATE day_totals SET active_seconds = 1234"); err != nil {
+		t.Fatal(err)
+	}
+	opts.Rebuild = true
+	days, err := s.DayTotals(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkDayTotals(t, days, TotalDays(stream, opts))
+}
@@ -0,0 +1,113 @@
+package thyme
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// Uncategorized is the category of applications that no rule matches.
+const Uncategorized = "Uncategorized"
+
+// Categories rolls applications up into higher-level categories such
+// as "Development" or "Communication".
+type Categories struct {
+	// Rules are tried in order; the first one whose pattern matches
+	// an application's name determines its category.
+	Rules []*CategoryRule
+
+	// Weights say how productive time spent in each category is, from
+	// 0 for not at all to 1 for fully, for the focus score.
+	Weights map[string]float64
+}
+
+// CategoryRule puts the applications whose names match Match in
+// Category.
+type CategoryRule struct {
+	Match    *regexp.Regexp
+	Category string
+}
+
+// categoriesFile is the on-disk format of the categories file.
+type categoriesFile struct {
+	Rules []struct {
+		Match    string `json:"match"`
+		Category string `json:"category"`
+	} `json:"rules"`
+	Weights map[string]float64 `json:"weights"`
+}
+
+// LoadCategories reads categorization rules from the JSON file at
+// path, e.g.
+//
+//	{"rules": [
+//	  {"match": "Code|Terminal|Emacs", "category": "Development"},
+//	  {"match": "Slack|Mail", "category": "Communication"}
+//	],
+//	 "weights": {"Communication": 0.3, "Uncategorized": 0.5}}
+//
+// The patterns are regexes matched against application names as
+// extracted by Window.Info. The optional weights say how productive
+// each category is, for the focus score. A missing file yields no
+// rules rather than an error.
+func LoadCategories(path string) (*Categories, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Categories{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var f categoriesFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("could not parse categories file %s: %s", path, err)
+	}
+	cats := &Categories{Weights: f.Weights}
+	for category, w := range f.Weights {
+		if w < 0 || w > 1 {
+			return nil, fmt.Errorf("invalid weight %v for category %q in %s: must be between 0 and 1", w, category, path)
+		}
+	}
+	for _, r := range f.Rules {
+		rx, err := regexp.Compile(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid category pattern %q in %s: %s", r.Match, path, err)
+		}
+		cats.Rules = append(cats.Rules, &CategoryRule{Match: rx, Category: r.Category})
+	}
+	return cats, nil
+}
+
+// Categorize returns the category of the application that owns w, or
+// Uncategorized if no rule matches it.
+func (c *Categories) Categorize(w *Window) string {
+	if c == nil || w == nil {
+		return Uncategorized
+	}
+	return c.categorizeApp(appID(w))
+}
+
+// categorizeApp returns the category of the application called app.
+func (c *Categories) categorizeApp(app string) string {
+	if c == nil {
+		return Uncategorized
+	}
+	for _, r := range c.Rules {
+		if r.Match.MatchString(app) {
+			return r.Category
+		}
+	}
+	return Uncategorized
+}
+
+// Weight returns how productive time spent in category is, from 0 to
+// 1. Categories without a weight are fully productive.
+func (c *Categories) Weight(category string) float64 {
+	if c == nil {
+		return 1
+	}
+	if w, exists := c.Weights[category]; exists {
+		return w
+	}
+	return 1
+}
@@ -0,0 +1,358 @@
+//go:build darwin && cgo
+// +build darwin,cgo
+
+package thyme
+
+/*
+#cgo CFLAGS: -x objective-c
+#cgo LDFLAGS: -framework CoreGraphics -framework CoreFoundation -framework IOKit -framework AppKit
+#import <AppKit/AppKit.h>
+#include <CoreGraphics/CoreGraphics.h>
+#include <IOKit/IOKitLib.h>
+#include <stdlib.h>
+
+typedef struct {
+	long number;
+	int pid;
+	int layer;
+	int onscreen;
+	double x, y, width, height;
+	char *owner;
+	char *name;
+} thyme_cg_window;
+
+// thyme_copy_string returns a copy of s in UTF-8, to be freed by the
+// caller, or NULL if s is NULL or can't be converted.
+static char *thyme_copy_string(CFStringRef s) {
+	if (s == NULL) {
+		return NULL;
+	}
+	CFIndex size = CFStringGetMaximumSizeForEncoding(CFStringGetLength(s), kCFStringEncodingUTF8) + 1;
+	char *buf = malloc(size);
+	if (buf != NULL && !CFStringGetCString(s, buf, size, kCFStringEncodingUTF8)) {
+		free(buf);
+		return NULL;
+	}
+	return buf;
+}
+
+// thyme_cg_windows lists the windows matching option, front to back,
+// into *out, to be freed with thyme_cg_free, and returns how many
+// there are, or -1 on failure.
+static int thyme_cg_windows(CGWindowListOption option, thyme_cg_window **out) {
+	CFArrayRef list = CGWindowListCopyWindowInfo(option | kCGWindowListExcludeDesktopElements, kCGNullWindowID);
+	if (list == NULL) {
+		return -1;
+	}
+	CFIndex n = CFArrayGetCount(list);
+	thyme_cg_window *windows = calloc(n > 0 ? n : 1, sizeof(thyme_cg_window));
+	if (windows == NULL) {
+		CFRelease(list);
+		return -1;
+	}
+	for (CFIndex i = 0; i < n; i++) {
+		CFDictionaryRef info = (CFDictionaryRef)CFArrayGetValueAtIndex(list, i);
+		thyme_cg_window *w = &windows[i];
+		CFNumberRef number;
+		if ((number = (CFNumberRef)CFDictionaryGetValue(info, kCGWindowNumber)) != NULL) {
+			CFNumberGetValue(number, kCFNumberLongType, &w->number);
+		}
+		if ((number = (CFNumberRef)CFDictionaryGetValue(info, kCGWindowOwnerPID)) != NULL) {
+			CFNumberGetValue(number, kCFNumberIntType, &w->pid);
+		}
+		if ((number = (CFNumberRef)CFDictionaryGetValue(info, kCGWindowLayer)) != NULL) {
+			CFNumberGetValue(number, kCFNumberIntType, &w->layer);
+		}
+		CFBooleanRef onscreen = (CFBooleanRef)CFDictionaryGetValue(info, kCGWindowIsOnscreen);
+		w->onscreen = onscreen != NULL && CFBooleanGetValue(onscreen);
+		CFDictionaryRef bounds = (CFDictionaryRef)CFDictionaryGetValue(info, kCGWindowBounds);
+		CGRect rect;
+		if (bounds != NULL && CGRectMakeWithDictionaryRepresentation(bounds, &rect)) {
+			w->x = rect.origin.x;
+			w->y = rect.origin.y;
+			w->width = rect.size.width;
+			w->height = rect.size.height;
+		}
+		w->owner = thyme_copy_string((CFStringRef)CFDictionaryGetValue(info, kCGWindowOwnerName));
+		w->name = thyme_copy_string((CFStringRef)CFDictionaryGetValue(info, kCGWindowName));
+	}
+	CFRelease(list);
+	*out = windows;
+	return (int)n;
+}
+
+static void thyme_cg_free(thyme_cg_window *windows, int n) {
+	for (int i = 0; i < n; i++) {
+		free(windows[i].owner);
+		free(windows[i].name);
+	}
+	free(windows);
+}
+
+typedef struct {
+	double x, y, width, height, scale;
+	char *name;
+} thyme_cg_display;
+
+// thyme_screen_name returns the localized name of the NSScreen showing
+// display, to be freed by the caller, or NULL if it has none.
+static char *thyme_screen_name(CGDirectDisplayID display) {
+	char *name = NULL;
+	@autoreleasepool {
+		for (NSScreen *screen in [NSScreen screens]) {
+			NSNumber *number = [[screen deviceDescription] objectForKey:@"NSScreenNumber"];
+			if (number == nil || [number unsignedIntValue] != display) {
+				continue;
+			}
+			if ([screen respondsToSelector:@selector(localizedName)]) {
+				name = thyme_copy_string((CFStringRef)[screen localizedName]);
+			}
+			break;
+		}
+	}
+	return name;
+}
+
+// thyme_cg_displays lists the active displays, the main one first,
+// into *out, to be freed with thyme_cg_free_displays, and returns how
+// many there are, or -1 on failure.
+static int thyme_cg_displays(thyme_cg_display **out) {
+	uint32_t n = 0;
+	if (CGGetActiveDisplayList(0, NULL, &n) != kCGErrorSuccess) {
+		return -1;
+	}
+	CGDirectDisplayID *ids = calloc(n > 0 ? n : 1, sizeof(CGDirectDisplayID));
+	if (ids == NULL) {
+		return -1;
+	}
+	if (CGGetActiveDisplayList(n, ids, &n) != kCGErrorSuccess) {
+		free(ids);
+		return -1;
+	}
+	thyme_cg_display *displays = calloc(n > 0 ? n : 1, sizeof(thyme_cg_display));
+	if (displays == NULL) {
+		free(ids);
+		return -1;
+	}
+	for (uint32_t i = 0; i < n; i++) {
+		thyme_cg_display *d = &displays[i];
+		// Display bounds, like window bounds, are in points from the
+		// top-left corner of the main display.
+		CGRect bounds = CGDisplayBounds(ids[i]);
+		d->x = bounds.origin.x;
+		d->y = bounds.origin.y;
+		d->width = bounds.size.width;
+		d->height = bounds.size.height;
+		CGDisplayModeRef mode = CGDisplayCopyDisplayMode(ids[i]);
+		if (mode != NULL) {
+			size_t points = CGDisplayModeGetWidth(mode);
+			if (points > 0) {
+				d->scale = (double)CGDisplayModeGetPixelWidth(mode) / points;
+			}
+			CGDisplayModeRelease(mode);
+		}
+		d->name = thyme_screen_name(ids[i]);
+	}
+	free(ids);
+	*out = displays;
+	return (int)n;
+}
+
+static void thyme_cg_free_displays(thyme_cg_display *displays, int n) {
+	for (int i = 0; i < n; i++) {
+		free(displays[i].name);
+	}
+	free(displays);
+}
+
+// thyme_hid_idle returns the HIDIdleTime property of IOHIDSystem, how
+// long the system has gone without user input in nanoseconds, or -1
+// if it can't be read.
+static int64_t thyme_hid_idle(void) {
+	io_service_t service = IOServiceGetMatchingService(MACH_PORT_NULL, IOServiceMatching("IOHIDSystem"));
+	if (service == IO_OBJECT_NULL) {
+		return -1;
+	}
+	CFTypeRef value = IORegistryEntryCreateCFProperty(service, CFSTR("HIDIdleTime"), kCFAllocatorDefault, 0);
+	IOObjectRelease(service);
+	if (value == NULL) {
+		return -1;
+	}
+	int64_t ns = -1;
+	if (CFGetTypeID(value) == CFNumberGetTypeID()) {
+		CFNumberGetValue((CFNumberRef)value, kCFNumberSInt64Type, &ns);
+	} else if (CFGetTypeID(value) == CFDataGetTypeID() && CFDataGetLength((CFDataRef)value) == sizeof(ns)) {
+		CFDataGetBytes((CFDataRef)value, CFRangeMake(0, sizeof(ns)), (UInt8 *)&ns);
+	}
+	CFRelease(value);
+	return ns;
+}
+
+// thyme_screen_locked returns 1 if the screen of the current session
+// is locked, 0 if it isn't, and -1 if there is no session.
+static int thyme_screen_locked(void) {
+	CFDictionaryRef session = CGSessionCopyCurrentDictionary();
+	if (session == NULL) {
+		return -1;
+	}
+	CFBooleanRef locked = (CFBooleanRef)CFDictionaryGetValue(session, CFSTR("CGSSessionScreenIsLocked"));
+	int result = locked != NULL && CFGetTypeID(locked) == CFBooleanGetTypeID() && CFBooleanGetValue(locked);
+	CFRelease(session);
+	return result;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+	"unsafe"
+)
+
+// cgNormalLayer is the window layer of ordinary application windows,
+// as opposed to the menu bar, the Dock and the like.
+const cgNormalLayer = 0
+
+// errNoWindowTitles is returned by cgSnapshot when the titles of other
+// applications' windows are hidden from it, which happens unless the
+// user granted the Screen Recording permission.
+var errNoWindowTitles = errors.New("window titles are not available; grant Screen Recording permission to read them")
+
+// cgWindow is a window as listed by CoreGraphics.
+type cgWindow struct {
+	window   Window
+	layer    int
+	onscreen bool
+	titled   bool
+}
+
+// listCGWindows lists the windows matching option, front to back.
+func listCGWindows(option C.CGWindowListOption) ([]cgWindow, error) {
+	var list *C.thyme_cg_window
+	n := C.thyme_cg_windows(option, &list)
+	if n < 0 {
+		return nil, fmt.Errorf("CGWindowListCopyWindowInfo failed")
+	}
+	defer C.thyme_cg_free(list, n)
+	var windows []cgWindow
+	for _, w := range unsafe.Slice(list, int(n)) {
+		owner := C.GoString(w.owner)
+		cw := cgWindow{
+			window: Window{
+				ID:      int64(w.number),
+				Name:    owner,
+				X:       int(w.x),
+				Y:       int(w.y),
+				Width:   int(w.width),
+				Height:  int(w.height),
+				PID:     int(w.pid),
+				Process: owner,
+			},
+			layer:    int(w.layer),
+			onscreen: w.onscreen != 0,
+		}
+		// Windows are named "<title> - <application>", as with
+		// AppleScript.
+		if w.name != nil {
+			if title := C.GoString(w.name); title != "" {
+				cw.window.Name, cw.titled = title+" - "+owner, true
+			}
+		}
+		windows = append(windows, cw)
+	}
+	return windows, nil
+}
+
+// cgSnapshot takes a snapshot of the application windows through
+// CoreGraphics, which is much faster than AppleScript and needs no
+// Accessibility permission. The active window is the frontmost
+// ordinary window on screen. It returns errNoWindowTitles if it
+// can't read the titles of other applications' windows.
+func cgSnapshot() (*Snapshot, error) {
+	all, err := listCGWindows(C.kCGWindowListOptionAll)
+	if err != nil {
+		return nil, err
+	}
+	onscreen, err := listCGWindows(C.kCGWindowListOptionOnScreenOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &Snapshot{Time: time.Now()}
+	var othersTitled, others int
+	for _, cw := range all {
+		if cw.layer != cgNormalLayer || cw.window.IsSystem() {
+			continue
+		}
+		w := cw.window
+		snap.Windows = append(snap.Windows, &w)
+		if w.PID != os.Getpid() {
+			others++
+			if cw.titled {
+				othersTitled++
+			}
+		}
+	}
+	if others > 0 && othersTitled == 0 {
+		return nil, errNoWindowTitles
+	}
+	for _, cw := range onscreen {
+		if cw.layer != cgNormalLayer || cw.window.IsSystem() {
+			continue
+		}
+		if snap.Active == 0 {
+			snap.Active = cw.window.ID
+		}
+		snap.Visible = append(snap.Visible, cw.window.ID)
+	}
+	return snap, nil
+}
+
+// cgMonitors returns the displays attached to the system, as listed by
+// CoreGraphics, named after the NSScreen that shows them.
+func cgMonitors() ([]*Monitor, error) {
+	var list *C.thyme_cg_display
+	n := C.thyme_cg_displays(&list)
+	if n < 0 {
+		return nil, fmt.Errorf("CGGetActiveDisplayList failed")
+	}
+	defer C.thyme_cg_free_displays(list, n)
+	var monitors []*Monitor
+	for i, d := range unsafe.Slice(list, int(n)) {
+		name := fmt.Sprintf("Display %d", i+1)
+		if d.name != nil {
+			name = C.GoString(d.name)
+		}
+		monitors = append(monitors, &Monitor{
+			Name:   name,
+			X:      int(d.x),
+			Y:      int(d.y),
+			Width:  int(d.width),
+			Height: int(d.height),
+			Scale:  float64(d.scale),
+		})
+	}
+	return monitors, nil
+}
+
+// cgIdle returns how long the system has gone without user input, as
+// reported by the HIDIdleTime property of IOHIDSystem.
+func cgIdle() (time.Duration, error) {
+	ns := C.thyme_hid_idle()
+	if ns < 0 {
+		return 0, fmt.Errorf("could not read HIDIdleTime")
+	}
+	return time.Duration(ns), nil
+}
+
+// cgLocked reports whether the screen is locked, as reported by the
+// CGSSessionScreenIsLocked key of the current session's dictionary.
+func cgLocked() (bool, error) {
+	locked := C.thyme_screen_locked()
+	if locked < 0 {
+		return false, fmt.Errorf("CGSessionCopyCurrentDictionary failed")
+	}
+	return locked == 1, nil
+}
@@ -0,0 +1,33 @@
+//go:build !darwin || !cgo
+// +build !darwin !cgo
+
+package thyme
+
+import (
+	"errors"
+	"time"
+)
+
+// errNoCoreGraphics is returned by the functions that need CoreGraphics
+// in builds without it.
+var errNoCoreGraphics = errors.New("CoreGraphics is not available in this build")
+
+// cgSnapshot is only available on macOS, in builds with cgo.
+func cgSnapshot() (*Snapshot, error) {
+	return nil, errNoCoreGraphics
+}
+
+// cgMonitors is only available on macOS, in builds with cgo.
+func cgMonitors() ([]*Monitor, error) {
+	return nil, errNoCoreGraphics
+}
+
+// cgIdle is only available on macOS, in builds with cgo.
+func cgIdle() (time.Duration, error) {
+	return 0, errNoCoreGraphics
+}
+
+// cgLocked is only available on macOS, in builds with cgo.
+func cgLocked() (bool, error) {
+	return false, errNoCoreGraphics
+}
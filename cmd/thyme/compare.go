@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+// CompareCmd is the subcommand that compares the time spent in each
+// application during the current and the previous period.
+type CompareCmd struct {
+	In            string        `long:"in" short:"i" description:"input file (default: read the database written by thyme track)"`
+	DB            string        `long:"db" env:"THYME_DB" description:"database to read if --in isn't set (default: the one thyme track records in)"`
+	Store         string        `long:"store" env:"THYME_STORE" description:"store to read instead of --db: a sqlite database file, a .jsonl file or a postgres:// connection string"`
+	Period        string        `long:"period" default:"week" choice:"day" choice:"week" choice:"month" description:"length of the periods to compare; the current one, which is still going on, is compared to the previous one"`
+	By            string        `long:"by" default:"app" choice:"app" choice:"title" choice:"document" choice:"category" choice:"project" description:"group active time by application, window title, document, category or project"`
+	IdleThreshold time.Duration `long:"idle-threshold" description:"don't count windows as active once the user has been idle this long (e.g. 5m)"`
+	IncludeLocked bool          `long:"include-locked" description:"count windows as active even while the screen was locked"`
+	SingleActive  bool          `long:"single-active" description:"attribute the time of snapshots recorded with thyme track --split-active to the focused window only, rather than splitting it among the windows active with it"`
+	MinDuration   time.Duration `long:"min-duration" description:"attribute the periods shorter than this (e.g. 30s) spent in an application, such as those of an alt-tab, to the application active before them"`
+}
+
+var compareCmd CompareCmd
+
+// filter returns the filter of the snapshots set by the options of c.
+func (c *CompareCmd) filter() streamFilter {
+	return streamFilter{idleThreshold: c.IdleThreshold, includeLocked: c.IncludeLocked, singleActive: c.SingleActive, minDuration: c.MinDuration}
+}
+
+func (c *CompareCmd) Execute(args []string) error {
+	group, err := thyme.LookupGrouping(c.By)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	current, err := thyme.PeriodStart(now, c.Period)
+	if err != nil {
+		return err
+	}
+	previous, err := thyme.PeriodStart(current.Add(-time.Nanosecond), c.Period)
+	if err != nil {
+		return err
+	}
+
+	db, err := dbPath(c.DB)
+	if err != nil {
+		return err
+	}
+	stream, err := loadStream(c.In, c.Store, db)
+	if err != nil {
+		return err
+	}
+	stream = c.filter().apply(stream)
+	cats, err := loadCategories()
+	if err != nil {
+		return err
+	}
+	changes := thyme.Compare(stream.Between(previous, current), stream.Between(current, time.Time{}), group, cats)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\tCHANGE\t\n", strings.ToUpper(group.Label), periodName(previous, c.Period), periodName(current, c.Period))
+	for _, ch := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", truncate(ch.Label, topLabelWidth), formatDuration(ch.Before), formatDuration(ch.After), formatChange(ch))
+	}
+	return w.Flush()
+}
+
+// periodName names the period of the given length that starts at
+// start, e.g. "week of Oct 5".
+func periodName(start time.Time, period string) string {
+	switch period {
+	case "day":
+		return start.Format("Mon Jan 2")
+	case "month":
+		return start.Format("January 2006")
+	}
+	return start.Format("week of Jan 2")
+}
+
+// formatDuration formats d like formatSeconds, or as "-" if it is
+// zero.
+func formatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "-"
+	}
+	return formatSeconds(d.Seconds())
+}
+
+// formatChange describes ch with an arrow pointing up or down, the
+// difference in active time and, if there is one, the percentage
+// change.
+func formatChange(ch *thyme.Change) string {
+	switch {
+	case ch.New():
+		return "new"
+	case ch.Dropped():
+		return "dropped"
+	}
+	delta := ch.Delta()
+	arrow, sign := "▲", "+"
+	if delta < 0 {
+		arrow, sign, delta = "▼", "-", -delta
+	}
+	if delta < time.Second {
+		return "="
+	}
+	s := fmt.Sprintf("%s %s%s", arrow, sign, formatSeconds(delta.Seconds()))
+	if p, ok := ch.Percent(); ok {
+		s += fmt.Sprintf(" (%+.0f%%)", p)
+	}
+	return s
+}
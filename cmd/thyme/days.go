@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+// readDayTotals returns the totals of each day of the snapshots of the
+// file in, or else of the store named by store or db, for thyme show
+// -w days and the /days.json of thyme serve. Days are those of
+// opts.Location, or of the zone each snapshot was recorded in if it is
+// nil. Databases that cache the totals of past days (see
+// thyme.DayTotaler) only have the snapshots taken since read again,
+// except with recorded zones, which the cache doesn't hold.
+func readDayTotals(in, store, db string, opts thyme.DayTotalsOptions) ([]*thyme.DayTotals, error) {
+	if in == "" && opts.Location != nil {
+		s, err := openRecordedStore(store, db)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			defer s.Close()
+		}
+		if cache, ok := s.(thyme.DayTotaler); ok {
+			days, err := cache.DayTotals(opts)
+			if err != nil {
+				return nil, fmt.Errorf("read cached totals: %w", err)
+			}
+			return days, nil
+		}
+	}
+	stream, err := loadStream(in, store, db)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Location == nil {
+		stream = stream.InRecordedZones()
+	}
+	return thyme.TotalDays(stream, opts), nil
+}
+
+// daysBetween returns the days of days that overlap the time from
+// since until until, days being those of loc. Either may be zero for
+// no limit.
+func daysBetween(days []*thyme.DayTotals, since, until time.Time, loc *time.Location) []*thyme.DayTotals {
+	if loc == nil {
+		loc = time.Local
+	}
+	var kept []*thyme.DayTotals
+	for _, d := range days {
+		y, m, day := d.Date.Date()
+		start := time.Date(y, m, day, 0, 0, 0, 0, loc)
+		if (since.IsZero() || start.AddDate(0, 0, 1).After(since)) && (until.IsZero() || start.Before(until)) {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// writeDays writes the active time of each of days, and the
+// application active the longest that day, as a table.
+func writeDays(w io.Writer, days []*thyme.DayTotals) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "DAY\tACTIVE\tTOP APPLICATION\t\n")
+	for _, d := range days {
+		var active float64
+		for _, t := range d.Apps {
+			active += t.ActiveSeconds
+		}
+		if active == 0 {
+			continue
+		}
+		top := d.Apps[0]
+		fmt.Fprintf(tw, "%s\t%s\t%s (%.0f%%)\t\n", d.Date.Format("2006-01-02 Mon"), formatSeconds(active), truncate(top.Label, topLabelWidth), 100*top.ActiveSeconds/active)
+	}
+	return tw.Flush()
+}
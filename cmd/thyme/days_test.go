@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+func TestTotalsWithoutDatabase(t *testing.T) {
+	home := useTempHome(t)
+	for _, loc := range []*time.Location{time.UTC, nil} {
+		days, err := readDayTotals("", "", "", thyme.DayTotalsOptions{Location: loc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(days) != 0 {
+			t.Errorf("got %d days, want none", len(days))
+		}
+	}
+	if totals, err := (&TopCmd{By: "app"}).totals(); err != nil {
+		t.Fatal(err)
+	} else if len(totals) != 0 {
+		t.Errorf("thyme top got %d totals, want none", len(totals))
+	}
+	if totals, err := (&SummaryCmd{}).totals(startOfDay(time.Now())); err != nil {
+		t.Fatal(err)
+	} else if len(totals) != 0 {
+		t.Errorf("thyme summary got %d totals, want none", len(totals))
+	}
+	if _, err := os.Stat(filepath.Join(home, "thyme.db")); !os.IsNotExist(err) {
+		t.Errorf("reading the totals created the database: %v", err)
+	}
+}
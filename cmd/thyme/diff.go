@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mehdidc/thyme"
+)
+
+// DiffCmd is the subcommand that prints how the windows changed from
+// one snapshot to another.
+type DiffCmd struct {
+	Args struct {
+		Old string `positional-arg-name:"old" required:"true"`
+		New string `positional-arg-name:"new" required:"true"`
+	} `positional-args:"true"`
+}
+
+var diffCmd DiffCmd
+
+func (c *DiffCmd) Execute(args []string) error {
+	old, err := lastSnapshot(c.Args.Old)
+	if err != nil {
+		return err
+	}
+	current, err := lastSnapshot(c.Args.New)
+	if err != nil {
+		return err
+	}
+	for _, change := range thyme.DiffSnapshots(old, current) {
+		fmt.Println(change)
+	}
+	return nil
+}
+
+// lastSnapshot reads the file at path, as written by `thyme track -o`,
+// and returns its only snapshot, or its last one if it holds a stream.
+func lastSnapshot(path string) (*thyme.Snapshot, error) {
+	stream, err := loadStream(path, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if len(stream.Snapshots) == 0 {
+		return nil, fmt.Errorf("read %s: no snapshot", path)
+	}
+	return stream.Snapshots[len(stream.Snapshots)-1], nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+// DoctorCmd is the subcommand that checks that thyme track can work.
+type DoctorCmd struct {
+	DB      string        `long:"db" env:"THYME_DB" description:"database to check (default: the one thyme track records in)"`
+	Store   string        `long:"store" env:"THYME_STORE" description:"store to check instead of --db: a sqlite database file, a .jsonl file or a postgres:// connection string"`
+	Timeout time.Duration `long:"timeout" default:"5s" description:"give up on the test snapshot after this long"`
+}
+
+var doctorCmd DoctorCmd
+
+// checkup prints the outcome of each check and counts the failures.
+type checkup struct {
+	failed int
+}
+
+// pass reports a check that succeeded.
+func (c *checkup) pass(format string, args ...interface{}) {
+	fmt.Printf("[ OK ] %s\n", fmt.Sprintf(format, args...))
+}
+
+// warn reports a check that failed without stopping thyme track from
+// working, with a hint on how to fix it.
+func (c *checkup) warn(hint, format string, args ...interface{}) {
+	fmt.Printf("[WARN] %s\n", fmt.Sprintf(format, args...))
+	fmt.Printf("       %s\n", hint)
+}
+
+// fail reports a check that failed in a way that stops thyme track
+// from working, with a hint on how to fix it.
+func (c *checkup) fail(hint, format string, args ...interface{}) {
+	c.failed++
+	fmt.Printf("[FAIL] %s\n", fmt.Sprintf(format, args...))
+	fmt.Printf("       %s\n", hint)
+}
+
+func (c *DoctorCmd) Execute(args []string) error {
+	var ck checkup
+	t := platformTracker()
+
+	if runtime.GOOS != "windows" && runtime.GOOS != "darwin" {
+		if err := thyme.CheckDisplay(); err != nil {
+			ck.fail("run thyme in a graphical session, or set DISPLAY to its display (e.g. DISPLAY=:0)", "%s", err)
+		} else if d := os.Getenv("WAYLAND_DISPLAY"); d != "" {
+			ck.pass("Wayland display %s", d)
+		} else {
+			ck.pass("X display %s", os.Getenv("DISPLAY"))
+		}
+	}
+
+	if pt, ok := t.(thyme.ProgramTracker); ok {
+		for _, p := range pt.Programs() {
+			path, err := exec.LookPath(p.Name)
+			switch {
+			case err == nil:
+				ck.pass("%s found at %s", p.Name, path)
+			case p.Fallback != "":
+				ck.warn("install it to "+p.Purpose+"; without it, "+p.Fallback+"; see `thyme dep`", "%s not found on PATH", p.Name)
+			case p.Optional:
+				ck.warn("install it to "+p.Purpose+"; see `thyme dep`", "%s not found on PATH", p.Name)
+			default:
+				ck.fail("install it, it is needed to "+p.Purpose+"; see `thyme dep`", "%s not found on PATH", p.Name)
+			}
+		}
+	}
+
+	c.checkStore(&ck)
+	c.checkSnap(&ck, t)
+
+	if ck.failed > 0 {
+		return fmt.Errorf("%d checks failed", ck.failed)
+	}
+	fmt.Println("thyme track is ready to go")
+	return nil
+}
+
+// checkStore checks that snapshots can be recorded in the store. A
+// sqlite database that doesn't exist yet isn't created, but its
+// directory must be writable.
+func (c *DoctorCmd) checkStore(ck *checkup) {
+	if c.Store != "" {
+		name := thyme.StoreName(c.Store)
+		s, _, err := thyme.OpenStore(c.Store)
+		if err != nil {
+			ck.fail("check the connection string and that the database accepts connections", "could not open %s: %s", name, err)
+			return
+		}
+		s.Close()
+		ck.pass("store %s is reachable", name)
+		return
+	}
+
+	path, err := dbPath(c.DB)
+	if err != nil {
+		ck.fail("set --db or THYME_DB to where snapshots should be recorded", "%s", err)
+		return
+	}
+	if _, err := os.Stat(path); err == nil {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			ck.fail("check the permissions of the file, or pick another database with --db", "database %s is not writable: %s", path, err)
+			return
+		}
+		f.Close()
+		ck.pass("database %s is writable", path)
+		return
+	}
+	dir := filepath.Dir(path)
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	f, err := os.CreateTemp(dir, ".thyme-doctor-*")
+	if err != nil {
+		ck.fail("check the permissions of the directory, or pick another database with --db", "cannot create database %s: %s", path, err)
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+	ck.pass("database %s can be created", path)
+}
+
+// checkSnap checks that t can take a snapshot with at least one
+// window in it.
+func (c *DoctorCmd) checkSnap(ck *checkup, t thyme.Tracker) {
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	snap, err := t.Snap(ctx)
+	if err != nil {
+		ck.fail("fix the problems above, or see `thyme dep` for what the tracker needs", "could not take a snapshot: %s", err)
+		return
+	}
+	if len(snap.Windows) == 0 {
+		ck.fail("make sure thyme can see your windows, e.g. that it is allowed to on macOS; see `thyme dep`", "the snapshot has no windows")
+		return
+	}
+	ck.pass("took a snapshot of %d windows", len(snap.Windows))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/mehdidc/thyme"
+)
+
+// ExportCmd is the subcommand that writes the recorded snapshots to a
+// file, optionally anonymized for sharing.
+type ExportCmd struct {
+	In        string `long:"in" short:"i" description:"input file (default: read the database written by thyme track)"`
+	DB        string `long:"db" env:"THYME_DB" description:"database to read if --in isn't set (default: the one thyme track records in)"`
+	Store     string `long:"store" env:"THYME_STORE" description:"store to read instead of --db: a sqlite database file, a .jsonl file or a postgres:// connection string"`
+	Out       string `long:"out" short:"o" required:"true" description:"file to write the snapshots to, as JSON lines, one snapshot per line, if it ends in .jsonl, and gzipped if it ends in .gz"`
+	Anonymize bool   `long:"anonymize" description:"replace application names with pseudonyms such as app-7f3a and leave out window titles, keeping the timing of snapshots"`
+	Key       string `long:"key" description:"with --anonymize, also write what each pseudonym stands for to this file, as JSON; keep it private"`
+}
+
+var exportCmd ExportCmd
+
+func (c *ExportCmd) Execute(args []string) error {
+	if c.Key != "" && !c.Anonymize {
+		return fmt.Errorf("--key requires --anonymize")
+	}
+	db, err := dbPath(c.DB)
+	if err != nil {
+		return err
+	}
+	stream, err := loadStream(c.In, c.Store, db)
+	if err != nil {
+		return err
+	}
+	var anonymizer *thyme.Anonymizer
+	if c.Anonymize {
+		anonymizer = thyme.NewAnonymizer()
+		stream = anonymizer.Anonymize(stream)
+	}
+	if err := writeStream(c.Out, stream); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	if c.Key != "" {
+		b, err := json.MarshalIndent(anonymizer.Names(), "", "  ")
+		if err != nil {
+			return err
+		}
+		// The key reverses the anonymization, so only the user may
+		// read it.
+		if err := os.WriteFile(c.Key, append(b, '\n'), 0600); err != nil {
+			return fmt.Errorf("write key: %w", err)
+		}
+	}
+	return nil
+}
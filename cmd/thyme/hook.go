@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+// snapHook runs the command set with thyme track --on-snap after each
+// snapshot.
+type snapHook struct {
+	command string
+	timeout time.Duration
+
+	// done is closed once the last run of the command exits. It is nil
+	// if the command hasn't run yet.
+	done chan struct{}
+}
+
+// run starts the hook's command for snap, unless the previous run
+// hasn't exited yet, in which case snap is skipped so that a slow
+// command doesn't pile up. The command runs in the background, through
+// the shell, with the application and title of the active window of
+// snap as its arguments ($1 and $2, except on Windows) and in the
+// environment variables
+// THYME_APP and THYME_TITLE, along with THYME_TIME, THYME_IDLE (in
+// seconds) and THYME_LOCKED. Its exit status is logged; it can't fail
+// the track loop. The command is killed after h.timeout, if set, or
+// once ctx is done.
+func (h *snapHook) run(ctx context.Context, snap *thyme.Snapshot) {
+	if h.done != nil {
+		select {
+		case <-h.done:
+		default:
+			slog.Warn("the --on-snap command is still running, skipping a snapshot", "command", h.command)
+			return
+		}
+	}
+	var app, title string
+	if w := snap.ActiveWindow(); w != nil {
+		app, title = thyme.AppID(w), w.Info().Title
+	}
+	var cancel context.CancelFunc = func() {}
+	if h.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", h.command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", h.command, "thyme", app, title)
+	}
+	cmd.Env = append(os.Environ(),
+		"THYME_APP="+app,
+		"THYME_TITLE="+title,
+		"THYME_TIME="+snap.Time.Format(time.RFC3339),
+		"THYME_IDLE="+strconv.Itoa(int(snap.Idle.Seconds())),
+		"THYME_LOCKED="+strconv.FormatBool(snap.Locked),
+	)
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	h.done = make(chan struct{})
+	go func(done chan struct{}) {
+		defer close(done)
+		defer cancel()
+		start := time.Now()
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			slog.Debug("ran the --on-snap command", "command", h.command, "app", app, "duration", time.Since(start))
+		case ctx.Err() != nil:
+			slog.Warn("killed the --on-snap command", "command", h.command, "reason", ctx.Err())
+		case errors.As(err, &exitErr):
+			slog.Warn("the --on-snap command failed", "command", h.command, "status", exitErr.ExitCode())
+		default:
+			slog.Warn("could not run the --on-snap command", "command", h.command, "error", err)
+		}
+	}(h.done)
+}
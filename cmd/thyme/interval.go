@@ -0,0 +1,61 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+// adaptiveStableSnapshots is how many identical snapshots in a row
+// make an adaptiveInterval lengthen the interval.
+const adaptiveStableSnapshots = 3
+
+// adaptiveInterval decides how long `thyme track --max-interval` waits
+// between snapshots: it doubles the interval, up to max, after
+// adaptiveStableSnapshots identical snapshots in a row, and goes back
+// to min as soon as the active window changes.
+type adaptiveInterval struct {
+	min, max time.Duration
+	current  time.Duration
+	stable   int
+	last     *thyme.Snapshot
+}
+
+func newAdaptiveInterval(min, max time.Duration) *adaptiveInterval {
+	return &adaptiveInterval{min: min, max: max, current: min}
+}
+
+// next returns how long to wait after snap, the latest snapshot,
+// before taking the next one.
+func (a *adaptiveInterval) next(snap *thyme.Snapshot) time.Duration {
+	last := a.last
+	a.last = snap
+	switch {
+	case last == nil:
+	case last.Equivalent(snap):
+		a.stable++
+		if a.stable >= adaptiveStableSnapshots {
+			a.current = min(2*a.current, a.max)
+			a.stable = 0
+		}
+	case activeWindowChanged(last, snap):
+		a.current = a.min
+		a.stable = 0
+	default:
+		// Something else changed, e.g. a background window was
+		// opened: keep the interval, but start counting again.
+		a.stable = 0
+	}
+	return a.current
+}
+
+// activeWindowChanged reports whether the active window of b differs
+// from that of a, either because another window is active or because
+// its title changed.
+func activeWindowChanged(a, b *thyme.Snapshot) bool {
+	wa, wb := a.ActiveWindow(), b.ActiveWindow()
+	if wa == nil || wb == nil {
+		return wa != wb
+	}
+	return wa.ID != wb.ID || wa.Name != wb.Name
+}
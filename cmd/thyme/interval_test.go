@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveInterval(t *testing.T) {
+	type step struct {
+		active int64
+		names  []string
+		want   time.Duration
+	}
+	editor := []string{"main.go - thyme - Visual Studio Code", "bash"}
+	same := func(n int, active int64, names []string, wants ...time.Duration) []step {
+		var steps []step
+		for i := 0; i < n; i++ {
+			steps = append(steps, step{active, names, wants[i]})
+		}
+		return steps
+	}
+	for _, tt := range []struct {
+		name     string
+		min, max time.Duration
+		steps    []step
+	}{
+		{
+			name: "doubles when stable",
+			min:  time.Second, max: time.Minute,
+			steps: same(7, 1, editor, time.Second, time.Second, time.Second, 2*time.Second, 2*time.Second, 2*time.Second, 4*time.Second),
+		},
+		{
+			name: "capped at max",
+			min:  time.Second, max: 3 * time.Second,
+			steps: same(10, 1, editor, time.Second, time.Second, time.Second, 2*time.Second, 2*time.Second, 2*time.Second, 3*time.Second, 3*time.Second, 3*time.Second, 3*time.Second),
+		},
+		{
+			name: "another window active",
+			min:  time.Second, max: time.Minute,
+			steps: append(same(4, 1, editor, time.Second, time.Second, time.Second, 2*time.Second),
+				step{2, editor, time.Second}),
+		},
+		{
+			name: "active title changed",
+			min:  time.Second, max: time.Minute,
+			steps: append(same(4, 1, editor, time.Second, time.Second, time.Second, 2*time.Second),
+				step{1, []string{"data.go - thyme - Visual Studio Code", "bash"}, time.Second}),
+		},
+		{
+			name: "background window changed",
+			min:  time.Second, max: time.Minute,
+			steps: append(append(same(4, 1, editor, time.Second, time.Second, time.Second, 2*time.Second),
+				step{1, []string{editor[0], "vim"}, 2 * time.Second}),
+				same(3, 1, []string{editor[0], "vim"}, 2*time.Second, 2*time.Second, 4*time.Second)...),
+		},
+	} {
+		a := newAdaptiveInterval(tt.min, tt.max)
+		at := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+		for i, s := range tt.steps {
+			if got := a.next(testSnapshot(at, s.active, s.names...)); got != s.want {
+				t.Errorf("%s: interval after snapshot %d is %v, want %v", tt.name, i, got, s.want)
+			}
+			at = at.Add(a.current)
+		}
+	}
+}
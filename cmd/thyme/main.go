@@ -1,20 +1,55 @@
 package main
 
 import (
-	"database/sql"
+	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/jessevdk/go-flags"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/mehdidc/thyme"
+	"html/template"
+	"io"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"regexp"
 	"runtime"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 )
 
 var CLI = flags.NewNamedParser("thyme", flags.PrintErrors|flags.PassDoubleDash)
 
+// globalOptions are the options accepted by every subcommand.
+var globalOptions struct {
+	Verbose bool   `long:"verbose" short:"v" description:"log what thyme does, e.g. the commands run to take snapshots and the rows written"`
+	Home    string `long:"home" env:"THYME_HOME" description:"directory holding the configuration files and, unless --db or --store is set, the database (default: ~/.thyme)"`
+}
+
+// logLevel is the level of the messages that are logged: only
+// warnings and errors, unless --verbose is set.
+var logLevel = new(slog.LevelVar)
+
 func init() {
+	if _, err := CLI.AddGroup("Application Options", "", &globalOptions); err != nil {
+		log.Fatal(err)
+	}
+	CLI.CommandHandler = func(cmd flags.Commander, args []string) error {
+		if globalOptions.Verbose {
+			logLevel.Set(slog.LevelDebug)
+		}
+		if cmd == nil {
+			return nil
+		}
+		if err := loadDocumentPatterns(); err != nil {
+			return err
+		}
+		return cmd.Execute(args)
+	}
 	CLI.Usage = `
 thyme - automatically track which applications you use and for how long.
 
@@ -28,8 +63,26 @@ thyme - automatically track which applications you use and for how long.
 Example usage:
 
   thyme dep
+  thyme doctor
+  thyme track -n 30s
   thyme track -o <file>
+  thyme track -o - | thyme show -w stats > viz.html
+  thyme track -n 30s --jsonl thyme.jsonl
   thyme show  -i <file> -w stats > viz.html
+  thyme show  -i <file> -w stats --output-dir reports
+  thyme top   -i <file> --limit 5
+  thyme summary --today
+  thyme serve --addr localhost:8080
+  thyme watch
+  thyme stream -n 10s | <program>
+  thyme compare -i <file> --period week
+  thyme report --period week --mail-to <address>
+  thyme tag   <project>
+  thyme pause --for 30m
+  thyme import -o <merged file> <file> <file>...
+  thyme export -o <file> --anonymize --key <key file>
+  thyme validate -i <file> --repair <repaired file>
+  thyme diff  <file> <file>
 
 `
 
@@ -39,6 +92,60 @@ Example usage:
 	if _, err := CLI.AddCommand("show", "visualize data", "Generate an HTML page visualizing the data from a file written to by `thyme track`.", &showCmd); err != nil {
 		log.Fatal(err)
 	}
+	if _, err := CLI.AddCommand("import", "merge data files", "Merge the snapshots of several files written by `thyme track -o` (e.g. on different machines) into a single file. Times are converted to UTC, and the time zone each snapshot was recorded in is kept, so that `thyme show --tz recorded` can show them as they were on the machine that recorded them.", &importCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("export", "write snapshots to a file", "Write the snapshots recorded by `thyme track` to a file, as `thyme track -o` does. With --anonymize, application names are replaced with pseudonyms and window titles left out, so that the file can be shared without revealing what you worked on.", &exportCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("validate", "check a data file", "Check that a file written by `thyme track -o` can be read, snapshot by snapshot, and report the malformed entries with their offset in the file. With --repair, the snapshots that could be read are written to another file.", &validateCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("diff", "compare two snapshots", "Print how the windows changed from one snapshot to another, e.g. two files written by `thyme track -o`: \"+\" for the windows that appeared, \"-\" for those that disappeared, and \"~\" for those that changed title or became or stopped being the active window. Files holding a stream are compared by their last snapshot.", &diffCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("watch", "show activity live", "Show the active window, how long its application has been active, and the time spent in each application today, starting from the time thyme track already recorded, redrawn every --interval until interrupted. Nothing is recorded unless --record is set.", &watchCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("stream", "print snapshots as they are taken", "Take a snapshot every --interval until interrupted and print each one to stdout as it is taken, as a line of JSON, without recording it, e.g. to feed another program. The output reads like a .jsonl file written by `thyme track`.", &streamCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("serve", "serve the stats report over HTTP", "Serve the stats page over HTTP, reading the snapshots anew on every request. The since, until, group-by, idle-threshold, include-locked, single-active, tz and format query parameters filter the report like the flags of `thyme show`; /summary.json serves the summary of `thyme show -w json`.", &serveCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("report", "write or mail a periodic report", "Write the stats page, or the Markdown summary, of the last complete day, week or month, e.g. from a cron job. With --mail-to, the report is mailed instead of printed, through the SMTP server set in ~/.thyme/smtp.json or the THYME_SMTP_HOST, THYME_SMTP_PORT, THYME_SMTP_USERNAME, THYME_SMTP_PASSWORD and THYME_SMTP_FROM environment variables.", &reportCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("compare", "compare two periods", "Compare the time spent in each application during the current day, week or month with the time spent in it during the previous one.", &compareCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("pause", "pause tracking", "Stop `thyme track` and `thyme watch --record` from recording snapshots, without stopping them, until --for has passed or `thyme resume` is run.", &pauseCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("resume", "resume tracking", "Let `thyme track` record snapshots again after `thyme pause`.", &resumeCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("status", "print the tracking status", "Print whether tracking is paused with `thyme pause`, and until when, and the project set with `thyme tag`.", &statusCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("tag", "set the current project", "Tag the snapshots recorded from now on by `thyme track` with a project, until another one is set. Without a project, print the current one.", &tagCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("top", "print top applications", "Print the applications, or windows, you spent the most active time in as a table.", &topCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("summary", "print today's active time on one line", "Print how long you were active today, and in which application the most, on a single line, e.g. \"2h14m · Visual Studio Code\", for a shell prompt or a status bar. It reads the totals cached in the database, like `thyme top`, so it is fast enough to run every few seconds. --format sets what is printed, as a text/template.", &summaryCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("prune", "delete old snapshots", "Delete the snapshots older than --older-than from the database written to by `thyme track`, and reclaim the space they took.", &pruneCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("migrate", "upgrade the database schema", "Upgrade the schema of the database written to by `thyme track` to the latest version. `thyme track` does this automatically; running it again is harmless.", &migrateCmd); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := CLI.AddCommand("doctor", "check the environment", "Check that everything `thyme track` needs is in place: the display, the external programs the tracker runs, the database, and that a snapshot can be taken. Prints what is wrong, with hints on how to fix it.", &doctorCmd); err != nil {
+		log.Fatal(err)
+	}
 	if _, err := CLI.AddCommand("dep", "dep install instructions", "Show installation instructions for required external dependencies (which vary depending on your OS and windowing system).", &depCmd); err != nil {
 		log.Fatal(err)
 	}
@@ -46,125 +153,956 @@ Example usage:
 
 // TrackCmd is the subcommand that tracks application usage.
 type TrackCmd struct {
-	Out string `long:"out" short:"o" description:"output file"`
+	Out            string        `long:"out" short:"o" description:"instead of recording a snapshot, write the snapshots recorded so far to this file, as JSON lines if it ends in .jsonl, or to stdout if it is -"`
+	Stdout         bool          `long:"stdout" description:"same as -o -"`
+	DB             string        `long:"db" env:"THYME_DB" description:"database to record snapshots in (default: ~/.thyme/thyme.db, or $XDG_DATA_HOME/thyme/thyme.db if that is set and the former doesn't exist)"`
+	Store          string        `long:"store" env:"THYME_STORE" description:"record snapshots in this store instead of --db: a sqlite database file, a .jsonl file or a postgres:// connection string"`
+	JSONLines      string        `long:"jsonl" description:"record snapshots by appending them to this file as JSON lines, one snapshot per line, instead of in --db, whatever the file is called; --db and --store do the same with files ending in .jsonl"`
+	Interval       time.Duration `long:"interval" short:"n" description:"keep running and record a snapshot every interval (e.g. 30s) until interrupted"`
+	MinInterval    time.Duration `long:"min-interval" description:"with --max-interval, the interval to go back to when the active window changes (default: --interval)"`
+	MaxInterval    time.Duration `long:"max-interval" description:"with --interval, wait longer between snapshots while nothing changes, doubling the interval up to this long"`
+	MetricsAddr    string        `long:"metrics-addr" description:"with --interval, serve Prometheus metrics at /metrics on this address (e.g. localhost:9090)"`
+	Retention      string        `long:"retention" description:"on startup, delete the snapshots taken longer ago than this (e.g. 90d), like thyme prune"`
+	NoDedup        bool          `long:"no-dedup" description:"store every snapshot as a new row, even if it is identical to the previous one"`
+	Timeout        time.Duration `long:"timeout" default:"5s" description:"give up on a snapshot that takes longer than this, e.g. because the window system is unresponsive (0 for no limit)"`
+	Retries        int           `long:"retries" default:"2" description:"run the programs that query the X server (e.g. wmctrl, xdotool) again this many times when they fail because it is busy or unreachable, before dropping the snapshot"`
+	RetryBackoff   time.Duration `long:"retry-backoff" default:"100ms" description:"how long to wait before the first retry; the wait doubles before each further one"`
+	CaptureURLs    bool          `long:"capture-urls" description:"also record the URL of the active browser tab (Safari and Chromium-based browsers on macOS; Chromium-based browsers started with --remote-debugging-port=9222 on Linux)"`
+	DetectMeetings bool          `long:"detect-meetings" description:"also record whether a camera or microphone is in use, as during calls, so that reports can tell meetings apart (Linux, macOS and Windows 10 or later)"`
+	TrackInput     bool          `long:"track-input" description:"also record how many keys, mouse buttons and scroll steps you press per minute, never which ones, so that reports can tell typing from reading; this reads every input device, so only enable it if you are comfortable with that (Linux, in the input group, and macOS)"`
+	Project        string        `long:"project" description:"tag snapshots with this project instead of the one set with thyme tag"`
+	NoRedact       bool          `long:"no-redact" description:"record window titles as they are, even those matching the patterns in ~/.thyme/redact.json"`
+	HashTitles     bool          `long:"hash-titles" description:"record a salted hash of each window title, e.g. [hashed 3fa9c02e1b7d], instead of the title itself, so that reports still tell windows apart without revealing what they showed; the salt is generated once in ~/.thyme/title-salt"`
+	NoIgnore       bool          `long:"no-ignore" description:"record the windows of every application, even those matching the patterns in ~/.thyme/ignore.json"`
+	Only           []string      `long:"only" description:"only record the windows of applications (or processes) matching this regex, dropping all others; repeat for several, and combine with ~/.thyme/ignore.json to leave some of them out again"`
+	ActiveOnly     bool          `long:"active-only" description:"only record the active window, rather than every open window, to keep the database small"`
+	SplitActive    bool          `long:"split-active" description:"also record as active the visible windows tiled next to the focused one on its monitor, overlapping no other window, so that reports split the time among them (e.g. with a tiling window manager or split screen)"`
+	OnSnap         string        `long:"on-snap" description:"with --interval, run this shell command after each snapshot, with the active application and window title as its arguments and in $THYME_APP and $THYME_TITLE; it is killed after --timeout"`
+	Display        []string      `long:"display" description:"X display to track instead of $DISPLAY, e.g. :1; repeat to track several, or pass auto for every running X server (Linux only)"`
+
+	// redactor hides the window titles that must not be stored.
+	redactor *thyme.Redactor
+
+	// ignorer drops the windows of applications that must not be
+	// recorded at all.
+	ignorer *thyme.Ignorer
+
+	// hasher replaces window titles with their hashes with
+	// --hash-titles.
+	hasher *thyme.TitleHasher
+
+	// tracker, if set, takes the snapshots instead of the tracker of
+	// this system, e.g. a fake one in tests.
+	tracker thyme.Tracker
+
+	// store, if set, is where snapshots are recorded instead of the
+	// store the flags name, e.g. an in-memory one in tests.
+	store thyme.Store
+
+	// input measures the input rate of snapshots with --track-input.
+	input *thyme.InputRater
 }
 
+// dedupMaxGap is how far apart snapshots may be taken and still be
+// merged when thyme track is run once per snapshot, so that it can't
+// tell what the sampling interval is.
+const dedupMaxGap = 5 * time.Minute
+
 var trackCmd TrackCmd
 
 func (c *TrackCmd) Execute(args []string) error {
-	t, err := getTracker()
-	if err != nil {
+	if c.Retries < 0 {
+		return fmt.Errorf("--retries must not be negative")
+	}
+	if c.SplitActive && c.ActiveOnly {
+		return fmt.Errorf("--split-active and --active-only are mutually exclusive")
+	}
+	t := c.tracker
+	if t == nil {
+		var err error
+		if t, err = getTracker(c.Display); err != nil {
+			return err
+		}
+		if lt, ok := t.(*thyme.LinuxTracker); ok {
+			lt.Retry = thyme.Retry{Attempts: c.Retries + 1, Backoff: c.RetryBackoff}
+		}
+	}
+	if err := c.loadPrivacy(); err != nil {
 		return err
 	}
-	snap, err := t.Snap()
+	if c.TrackInput {
+		var err error
+		if c.input, err = thyme.NewInputRater(); err != nil {
+			return fmt.Errorf("--track-input: %w", err)
+		}
+	}
+	store, err := c.openStore()
 	if err != nil {
 		return err
 	}
-	filename := os.Getenv("HOME") + "/.thyme/thyme.db"
-	db, err := sql.Open("sqlite3", filename)
+	defer store.Close()
+	if err := c.prune(store); err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	out := c.Out
+	if c.Stdout {
+		if out != "" && out != "-" {
+			return fmt.Errorf("--stdout and --out %s are mutually exclusive", out)
+		}
+		out = "-"
+	}
+	if c.Interval > 0 && out == "" {
+		return c.trackLoop(ctx, t, store)
+	}
+	if out == "" {
+		paused, until, err := pausedUntil(time.Now())
+		if err != nil {
+			return err
+		}
+		if paused && until.IsZero() {
+			slog.Info("tracking is paused until thyme resume; not recording")
+			return nil
+		} else if paused {
+			slog.Info("tracking is paused; not recording", "until", until)
+			return nil
+		}
+	}
+	snap, err := c.snap(ctx, t)
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	if out == "" {
+		return c.storeSnapshot(store, snap)
+	}
+
+	stream, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	// An estimated interval would pass for the one thyme track was
+	// configured with once written down.
+	if interval, estimated := stream.SamplingInterval(); !estimated {
+		stream.Interval = interval
+	}
+
+	if err := writeStream(out, stream); err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	return nil
+}
+
+// openStore opens the store snapshots are recorded in: the --jsonl
+// file if it is set, and the --store or --db one otherwise.
+func (c *TrackCmd) openStore() (thyme.Store, error) {
+	if c.store != nil {
+		return c.store, nil
+	}
+	if c.JSONLines == "" {
+		store, _, _, err := openStore(c.Store, c.DB)
+		return store, err
+	}
+	if c.Store != "" {
+		return nil, fmt.Errorf("--jsonl and --store are mutually exclusive")
+	}
+	store, err := thyme.OpenJSONLinesStore(c.JSONLines)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("--jsonl: %w", err)
+	}
+	return store, nil
+}
+
+// loadPrivacy loads what keeps the snapshots c takes from holding
+// what the user doesn't want recorded: the redaction and ignore
+// patterns, and the salt of --hash-titles.
+func (c *TrackCmd) loadPrivacy() error {
+	if err := c.loadRedactor(); err != nil {
+		return err
+	}
+	if err := c.loadIgnorer(); err != nil {
+		return err
+	}
+	return c.loadHasher()
+}
+
+// loadHasher reads the salt window titles are hashed with from
+// ~/.thyme/title-salt, generating it the first time, if --hash-titles
+// is set.
+func (c *TrackCmd) loadHasher() error {
+	if !c.HashTitles {
+		return nil
 	}
-	out, err := json.Marshal(snap)
+	saltPath, err := configPath("title-salt")
 	if err != nil {
-		panic(err)
+		return err
 	}
-	_, err = db.Exec("CREATE TABLE IF NOT EXISTS data(time TIMESTAMP PRIMARY KEY, value TEXT)")
+	if c.hasher, err = thyme.LoadTitleHasher(saltPath); err != nil {
+		return fmt.Errorf("--hash-titles: %w", err)
+	}
+	// -v would log the titles the tracker reads otherwise.
+	thyme.HideCommandOutput()
+	return nil
+}
+
+// loadRedactor reads the patterns of the titles that must not be
+// stored from ~/.thyme/redact.json, unless --no-redact is set.
+func (c *TrackCmd) loadRedactor() error {
+	if c.NoRedact {
+		return nil
+	}
+	redactPath, err := configPath("redact.json")
 	if err != nil {
-		panic(err)
+		return err
+	}
+	if c.redactor, err = thyme.LoadRedactor(redactPath); err != nil {
+		return err
 	}
-	if c.Out == "" {
-		stmt, err := db.Prepare("INSERT INTO data(time, value) values(?,?)")
+	if len(c.redactor.Patterns) > 0 {
+		thyme.HideCommandOutput()
+	}
+	return nil
+}
+
+// loadIgnorer reads the patterns of the applications that must not be
+// recorded from ~/.thyme/ignore.json, unless --no-ignore is set, and
+// those of the only ones that may be from --only.
+func (c *TrackCmd) loadIgnorer() error {
+	c.ignorer = &thyme.Ignorer{}
+	if !c.NoIgnore {
+		ignorePath, err := configPath("ignore.json")
 		if err != nil {
-			panic(err)
+			return err
 		}
-		_, err = stmt.Exec(snap.Time, out)
+		if c.ignorer, err = thyme.LoadIgnorer(ignorePath); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range c.Only {
+		rx, err := regexp.Compile(pattern)
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("--only: %w", err)
 		}
-	} else {
-		var value string
-		rows, err := db.Query("SELECT value FROM data")
+		c.ignorer.Only = append(c.ignorer.Only, rx)
+	}
+	return nil
+}
+
+// trackLoop records a snapshot every c.Interval, or at the intervals
+// picked by an adaptiveInterval with --max-interval, until ctx is done,
+// at which point it closes store and reports how many snapshots were
+// recorded. Failures to take or
+// store a single snapshot are logged rather than ending the loop, and
+// no snapshot is taken while tracking is paused with thyme pause, the
+// first one taken after the pause getting a row of its own.
+// Along the way, it alerts the user when they go over one of the
+// daily budgets in ~/.thyme/budgets.json, and runs the --on-snap
+// command after each snapshot.
+func (c *TrackCmd) trackLoop(ctx context.Context, t thyme.Tracker, store thyme.Store) error {
+	budgetsPath, err := configPath("budgets.json")
+	if err != nil {
+		return err
+	}
+	budgets, err := thyme.LoadBudgets(budgetsPath)
+	if err != nil {
+		return err
+	}
+	watcher := thyme.NewBudgetWatcher(budgets, thyme.NewNotifier())
+
+	var metrics *trackMetrics
+	if c.MetricsAddr != "" {
+		metrics = newTrackMetrics()
+		ln, err := metrics.serve(c.MetricsAddr)
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("serve metrics: %w", err)
+		}
+		defer ln.Close()
+	}
+
+	interval := c.Interval
+	var adaptive *adaptiveInterval
+	if c.MaxInterval > 0 {
+		minInterval := c.Interval
+		if c.MinInterval > 0 {
+			minInterval = c.MinInterval
+		}
+		if c.MaxInterval < minInterval {
+			return fmt.Errorf("--max-interval (%s) is shorter than the minimum interval (%s)", c.MaxInterval, minInterval)
+		}
+		adaptive = newAdaptiveInterval(minInterval, c.MaxInterval)
+		interval = minInterval
+	}
+	var hook *snapHook
+	if c.OnSnap != "" {
+		hook = &snapHook{command: c.OnSnap, timeout: c.Timeout}
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var recorded int
+	var paused, afterPause bool
+	for {
+		if paused = pauseState(paused); paused {
+			// Snapshots aren't even taken while paused, so that
+			// neither budgets, metrics nor --on-snap see them.
+			// Nor may the first one after the pause be merged into
+			// the last one before it, which would then stand for
+			// the pause.
+			afterPause = true
+		} else if snap, err := c.snap(ctx, t); err != nil {
+			slog.Error("could not take snapshot", "error", err)
+		} else {
+			if adaptive != nil {
+				if next := adaptive.next(snap); next != interval {
+					slog.Debug("changing interval", "from", interval, "to", next)
+					interval = next
+					ticker.Reset(interval)
+				}
+			}
+			snap.Interval = interval
+			storeSnapshot := c.storeSnapshot
+			if afterPause {
+				storeSnapshot = appendSnapshot
+			}
+			if err := storeSnapshot(store, snap); err != nil {
+				slog.Error("could not store snapshot", "error", err)
+			} else {
+				recorded++
+				afterPause = false
+			}
+			// The snapshot stands for the time until the next one.
+			if err := watcher.Observe(snap, interval); err != nil {
+				slog.Error("could not check budgets", "error", err)
+			}
+			if metrics != nil {
+				metrics.observe(snap, interval)
+			}
+			if hook != nil {
+				hook.run(ctx, snap)
+			}
 		}
-		f, err := os.Create(c.Out)
-		f.WriteString("{\n")
-		f.WriteString("\"Snapshots\" : [\n")
-		rows.Next()
-		err = rows.Scan(&value)
-		f.WriteString(value)
-		for rows.Next() {
-			f.WriteString(",")
-			err = rows.Scan(&value)
-			f.WriteString(value)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			slog.Info("stopped tracking", "reason", context.Cause(ctx), "recorded", recorded)
+			return store.Close()
 		}
-		f.WriteString("]\n")
-		f.WriteString("}")
-		rows.Close()
 	}
+}
 
+// pauseState returns whether tracking is paused with thyme pause,
+// logging when that changes from paused. If the pause can't be read,
+// the error is logged and paused returned.
+func pauseState(paused bool) bool {
+	now, until, err := pausedUntil(time.Now())
+	if err != nil {
+		slog.Error("could not read pause", "error", err)
+		return paused
+	}
+	switch {
+	case now && !paused && until.IsZero():
+		slog.Info("tracking paused until thyme resume")
+	case now && !paused:
+		slog.Info("tracking paused", "until", until)
+	case !now && paused:
+		slog.Info("tracking resumed")
+	}
+	return now
+}
+
+// snap takes a snapshot with t, giving up after c.Timeout or once ctx
+// is done. With --capture-urls, failing to capture the URL is logged
+// rather than failing the snapshot. Windows of applications that must
+// not be recorded are dropped, and titles that must not be stored are
+// redacted, from the snapshot before it is returned.
+func (c *TrackCmd) snap(ctx context.Context, t thyme.Tracker) (*thyme.Snapshot, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	snap, err := t.Snap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.ignorer.Ignore(snap)
+	if c.ActiveOnly {
+		snap.KeepActiveOnly()
+	}
+	if c.SplitActive {
+		snap.DetectSplitActive()
+	}
+	if c.CaptureURLs {
+		if err := thyme.CaptureURL(ctx, snap); err != nil {
+			slog.Warn("could not capture URL", "error", err)
+		}
+	}
+	if c.DetectMeetings {
+		// Where the camera and microphone can't be read, the
+		// snapshots are just recorded as outside meetings.
+		if err := thyme.DetectMeeting(ctx, snap); err != nil {
+			slog.Debug("could not detect meeting", "error", err)
+		}
+	}
+	if c.input != nil {
+		if err := c.input.Observe(snap); err != nil {
+			slog.Warn("could not measure input rate", "error", err)
+		}
+	}
+	c.redactor.Redact(snap)
+	c.hasher.Hash(snap)
+	snap.Zone = thyme.LocalZone(snap.Time)
+	snap.Project = c.Project
+	if snap.Project == "" {
+		// Read the project anew for every snapshot, so that a
+		// running thyme track picks up changes made with thyme tag.
+		if snap.Project, err = currentProject(); err != nil {
+			slog.Warn("could not read project", "error", err)
+		}
+	}
+	return snap, nil
+}
+
+// interruptContext returns a context that is canceled when the process
+// receives SIGINT or SIGTERM, with an error naming the signal as its
+// cause. The returned function stops listening for the signals.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-sigs:
+			cancel(fmt.Errorf("received %s", sig))
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancel(nil)
+	}
+}
+
+// storeSnapshot stores snap in store. Unless c.NoDedup is set, a
+// snapshot that is identical to the last one stored is merged into it
+// instead of being stored as a new one.
+func (c *TrackCmd) storeSnapshot(store thyme.Store, snap *thyme.Snapshot) error {
+	if c.NoDedup {
+		return appendSnapshot(store, snap)
+	}
+	maxGap := dedupMaxGap
+	if c.Interval > 0 {
+		maxGap = 2 * max(c.Interval, c.MaxInterval)
+	}
+
+	last, err := store.Last()
+	if err != nil || last == nil || !last.Mergeable(snap, maxGap) {
+		// Don't let one bad row stop us from recording.
+		return appendSnapshot(store, snap)
+	}
+	last.Merge(snap)
+	if err := store.UpdateLast(last); err != nil {
+		return fmt.Errorf("dedup: %w", err)
+	}
+	slog.Debug("merged snapshot into the last one", "time", snap.Time, "last", last.Time)
+	return nil
+}
+
+// appendSnapshot stores snap as a new snapshot in store.
+func appendSnapshot(store thyme.Store, snap *thyme.Snapshot) error {
+	if err := store.Append(snap); err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+	slog.Debug("stored snapshot", "time", snap.Time, "windows", len(snap.Windows))
 	return nil
 }
 
 // ShowCmd is the subcommand that reads the data emitted by the track
 // subcommand and displays the data to the user.
 type ShowCmd struct {
-	In   string `long:"in" short:"i" description:"input file"`
-	What string `long:"what" short:"w" description:"what to show {list,stats}" default:"list"`
+	In            string        `long:"in" short:"i" description:"input file, holding a stream or a single snapshot (default: standard input, unless --db or --store is set)"`
+	DB            string        `long:"db" env:"THYME_DB" description:"read snapshots directly from the database written by thyme track (e.g. ~/.thyme/thyme.db); ignored if --in is set"`
+	Store         string        `long:"store" env:"THYME_STORE" description:"read snapshots from this store instead of --db: a sqlite database file, a .jsonl file or a postgres:// connection string"`
+	What          string        `long:"what" short:"w" description:"what to show {list,stats,json,csv,gaps,sessions,switches,streaks,ics,markdown,days}, where streaks lists the longest uninterrupted time spent in each application, ics writes the sessions as calendar events, markdown writes a summary of each day and days the active time of each day, from the totals cached in the database" default:"list"`
+	IdleThreshold time.Duration `long:"idle-threshold" description:"don't count windows as active once the user has been idle this long (e.g. 5m)"`
+	IncludeLocked bool          `long:"include-locked" description:"count windows as active even while the screen was locked"`
+	SingleActive  bool          `long:"single-active" description:"attribute the time of snapshots recorded with thyme track --split-active to the focused window only, rather than splitting it among the windows active with it"`
+	MinDuration   time.Duration `long:"min-duration" description:"attribute the periods shorter than this (e.g. 30s) spent in an application, such as those of an alt-tab, to the application active before them"`
+	Since         string        `long:"since" description:"only show snapshots taken at or after this time (RFC 3339, YYYY-MM-DD, or a duration ago such as 7d or 24h)"`
+	Until         string        `long:"until" description:"only show snapshots taken before this time (same formats as --since)"`
+	Interval      time.Duration `long:"interval" description:"expected time between snapshots (e.g. 30s) for -w gaps (default: the interval the snapshots were taken at, if they say)"`
+	Gap           time.Duration `long:"gap" default:"15m" description:"with -w sessions, -w ics or -w markdown, the shortest break that ends a session"`
+	Format        string        `long:"format" choice:"html" choice:"svg" default:"html" description:"with -w stats, render the whole report as an HTML page, or only its main bar chart as a standalone SVG image"`
+	TZ            string        `long:"tz" description:"time zone to group by hour and day in, and to show times in, e.g. Europe/Paris or UTC, so that reports come out the same on every machine, or recorded for the zone each snapshot was taken in (default: the local time zone)"`
+	Template      string        `long:"template" description:"with -w stats, render the page with this html/template instead of ~/.thyme/report.tmpl, or the default one if that doesn't exist"`
+	OutputDir     string        `long:"output-dir" description:"with -w stats, write a page per day to this directory, as YYYY-MM-DD.html, and an index.html linking to them, instead of a single page to stdout"`
+	GroupBy       string        `long:"group-by" choice:"app" choice:"title" choice:"document" choice:"category" choice:"project" choice:"meeting" choice:"input" choice:"desktop" choice:"hour" choice:"day" choice:"weekday" description:"with -w stats or -w json, also total active time by application, window title, document (e.g. the file open in an editor, see ~/.thyme/documents.json), category, project (see thyme tag), meeting (see thyme track --detect-meetings), input (see thyme track --track-input), virtual desktop, hour of the day, day, or day of the week"`
+	RebuildCache  bool          `long:"rebuild-cache" description:"with -w days, recompute the cached totals of past days in the sqlite database from its snapshots"`
 }
 
 var showCmd ShowCmd
 
+// filter returns the filter of the snapshots set by the options of c.
+func (c *ShowCmd) filter() streamFilter {
+	return streamFilter{idleThreshold: c.IdleThreshold, includeLocked: c.IncludeLocked, singleActive: c.SingleActive, minDuration: c.MinDuration}
+}
+
 func (c *ShowCmd) Execute(args []string) error {
-	if c.In == "" {
-		var snap thyme.Snapshot
-		if err := json.NewDecoder(os.Stdin).Decode(&snap); err != nil {
+	in := c.In
+	if in == "" && c.DB == "" && c.Store == "" {
+		in = "-"
+	}
+	if c.What == "days" {
+		return c.showDays(in)
+	}
+	stream, err := loadStream(in, c.Store, c.DB)
+	if err != nil {
+		return err
+	}
+	since, until, err := c.timeRange(time.Now())
+	if err != nil {
+		return err
+	}
+	loc, err := loadLocation(c.TZ)
+	if err != nil {
+		return fmt.Errorf("--tz: %w", err)
+	}
+	stream = c.filter().apply(inLocation(stream.Between(since, until), loc))
+	var group *thyme.Grouping
+	if c.GroupBy != "" {
+		if group, err = thyme.LookupGrouping(c.GroupBy); err != nil {
 			return err
 		}
-		for _, w := range snap.Windows {
-			fmt.Printf("%+v\n", w.Info())
+	}
+	if c.OutputDir != "" && c.What != "stats" {
+		return fmt.Errorf("--output-dir requires -w stats")
+	}
+	switch c.What {
+	case "stats":
+		cats, err := loadCategories()
+		if err != nil {
+			return err
 		}
-	} else {
-		var stream thyme.Stream
-		f, err := os.Open(c.In)
+		goals, err := loadGoals()
 		if err != nil {
 			return err
 		}
-		defer f.Close()
-
-		if err := json.NewDecoder(f).Decode(&stream); err != nil {
+		colors, err := loadColors()
+		if err != nil {
 			return err
 		}
-		switch c.What {
-		case "stats":
-			if err := thyme.Stats(&stream); err != nil {
+		if c.Format == "svg" && c.OutputDir != "" {
+			return fmt.Errorf("--output-dir requires --format html")
+		}
+		opts := thyme.StatsOptions{Categories: cats, Group: group, Goals: goals, Colors: colors}
+		if c.Format == "svg" {
+			if err := thyme.StatsSVG(os.Stdout, stream, opts); err != nil {
 				return err
 			}
-		case "list":
-			fallthrough
-		default:
-			fmt.Println(stream)
-			thyme.List(&stream)
+			break
+		}
+		if opts.Template, err = loadStatsTemplate(c.Template); err != nil {
+			return err
+		}
+		if opts.Icons, err = iconResolver(); err != nil {
+			return err
+		}
+		if c.OutputDir != "" {
+			return writeDayReports(c.OutputDir, stream, func(w io.Writer, day *thyme.Stream) error {
+				return thyme.WriteStats(w, day, opts)
+			})
+		}
+		if err := thyme.WriteStats(os.Stdout, stream, opts); err != nil {
+			return err
+		}
+	case "json":
+		summary := thyme.Summarize(stream)
+		if group != nil {
+			cats, err := loadCategories()
+			if err != nil {
+				return err
+			}
+			summary.Group(stream, group, cats)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(summary); err != nil {
+			return err
+		}
+	case "csv":
+		if err := thyme.WriteCSV(os.Stdout, stream); err != nil {
+			return err
+		}
+	case "gaps":
+		interval := c.Interval
+		if interval <= 0 {
+			recorded, estimated := stream.SamplingInterval()
+			if estimated {
+				return fmt.Errorf("-w gaps requires --interval, as the snapshots don't say which interval they were taken at")
+			}
+			interval = recorded
+		}
+		for _, gap := range thyme.Gaps(stream, interval) {
+			fmt.Printf("%s\t%s\t%s\n", gap.Start.Format(time.RFC3339), gap.End.Format(time.RFC3339), gap.Duration())
+		}
+	case "switches":
+		switches := thyme.NewSwitches(stream)
+		fmt.Printf("%d switches between applications (%.1f per active hour)\n\nLongest stretches:\n", switches.Count, switches.PerHour())
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, s := range switches.Longest {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.App, s.Duration().Round(time.Second), s.Start.Format(time.RFC3339))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	case "streaks":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "APP\tLONGEST STREAK\tSTART\tEND\n")
+		for _, s := range thyme.NewSwitches(stream).Longest {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.App, s.Duration().Round(time.Second), s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	case "sessions":
+		for _, session := range thyme.Sessions(stream, c.Gap) {
+			fmt.Printf("%s\t%s\t%s\t%s\n", session.Start.Format(time.RFC3339), session.End.Format(time.RFC3339), session.Duration(), strings.Join(session.Apps, ", "))
+		}
+	case "ics":
+		if err := thyme.WriteICS(os.Stdout, thyme.Sessions(stream, c.Gap)); err != nil {
+			return err
+		}
+	case "markdown":
+		if err := thyme.WriteMarkdown(os.Stdout, stream, c.Gap); err != nil {
+			return err
+		}
+	case "list":
+		fallthrough
+	default:
+		fmt.Println(*stream)
+		if err := thyme.WriteList(os.Stdout, stream); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-type DepCmd struct{}
+// showDays writes the active time of each day the snapshots of in, or
+// of the --store or --db store, were taken, as -w days does. Only the
+// days overlapping --since and --until are shown, in whole.
+func (c *ShowCmd) showDays(in string) error {
+	if c.MinDuration > 0 {
+		return fmt.Errorf("--min-duration isn't supported with -w days")
+	}
+	since, until, err := c.timeRange(time.Now())
+	if err != nil {
+		return err
+	}
+	loc, err := loadLocation(c.TZ)
+	if err != nil {
+		return fmt.Errorf("--tz: %w", err)
+	}
+	days, err := readDayTotals(in, c.Store, c.DB, thyme.DayTotalsOptions{
+		Location:      loc,
+		IdleThreshold: c.IdleThreshold,
+		IncludeLocked: c.IncludeLocked,
+		SingleActive:  c.SingleActive,
+		Rebuild:       c.RebuildCache,
+	})
+	if err != nil {
+		return err
+	}
+	return writeDays(os.Stdout, daysBetween(days, since, until, loc))
+}
 
-var depCmd DepCmd
+// timeRange parses --since and --until relative to now. Either is
+// zero if unset.
+func (c *ShowCmd) timeRange(now time.Time) (since, until time.Time, err error) {
+	if c.Since != "" {
+		if since, err = thyme.ParseTime(c.Since, now); err != nil {
+			return since, until, fmt.Errorf("--since: %w", err)
+		}
+	}
+	if c.Until != "" {
+		if until, err = thyme.ParseTime(c.Until, now); err != nil {
+			return since, until, fmt.Errorf("--until: %w", err)
+		}
+	}
+	if !since.IsZero() && !until.IsZero() && until.Before(since) {
+		return since, until, fmt.Errorf("--until (%s) is before --since (%s)", until.Format(time.RFC3339), since.Format(time.RFC3339))
+	}
+	return since, until, nil
+}
 
-func (c *DepCmd) Execute(args []string) error {
-	t, err := getTracker()
+// recordedZone is the value of --tz that shows each snapshot in the
+// time zone it was recorded in.
+const recordedZone = "recorded"
+
+// loadLocation returns the time zone called name, e.g. "Europe/Paris",
+// or the local one if name is empty. It returns nil for recordedZone.
+func loadLocation(name string) (*time.Location, error) {
+	switch name {
+	case "":
+		return time.Local, nil
+	case recordedZone:
+		return nil, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// inLocation returns a copy of stream whose times are in loc, or in the
+// zone each snapshot was recorded in if loc is nil.
+func inLocation(stream *thyme.Stream, loc *time.Location) *thyme.Stream {
+	if loc == nil {
+		return stream.InRecordedZones()
+	}
+	return stream.In(loc)
+}
+
+// streamFilter says which of the snapshots the commands read count, as
+// their --idle-threshold, --include-locked, --single-active and
+// --min-duration options do.
+type streamFilter struct {
+	idleThreshold time.Duration
+	includeLocked bool
+	singleActive  bool
+	minDuration   time.Duration
+}
+
+// apply returns a copy of stream filtered as f says.
+func (f streamFilter) apply(stream *thyme.Stream) *thyme.Stream {
+	stream = stream.WithoutIdle(f.idleThreshold)
+	if !f.includeLocked {
+		stream = stream.WithoutLocked()
+	}
+	if f.singleActive {
+		stream = stream.WithoutSplitActive()
+	}
+	return stream.WithoutFlickers(f.minDuration)
+}
+
+// loadCategories reads the categorization rules in
+// ~/.thyme/categories.json.
+func loadCategories() (*thyme.Categories, error) {
+	path, err := configPath("categories.json")
+	if err != nil {
+		return nil, err
+	}
+	return thyme.LoadCategories(path)
+}
+
+// loadDocumentPatterns registers the patterns that find the documents
+// in window titles listed in ~/.thyme/documents.json, if it exists.
+func loadDocumentPatterns() error {
+	path, err := configPath("documents.json")
 	if err != nil {
 		return err
 	}
-	fmt.Println(t.Deps())
+	return thyme.LoadDocumentPatterns(path)
+}
+
+// loadStatsTemplate reads the template of the stats page from path if
+// it is set, or from ~/.thyme/report.tmpl if that exists. Otherwise it
+// returns nil, which stands for the default template.
+func loadStatsTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		var err error
+		if path, err = configPath("report.tmpl"); err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+	return thyme.LoadStatsTemplate(path)
+}
+
+// loadGoals reads the daily goals in ~/.thyme/goals.json.
+func loadGoals() ([]*thyme.Goal, error) {
+	path, err := configPath("goals.json")
+	if err != nil {
+		return nil, err
+	}
+	return thyme.LoadGoals(path)
+}
+
+// iconResolver returns the resolver of the icons of applications,
+// which caches them in ~/.thyme/icons.
+func iconResolver() (*thyme.IconResolver, error) {
+	dir, err := configPath("icons")
+	if err != nil {
+		return nil, err
+	}
+	return thyme.NewIconResolver(dir), nil
+}
+
+// loadColors reads the colors of labels in ~/.thyme/colors.json.
+func loadColors() (thyme.Colors, error) {
+	path, err := configPath("colors.json")
+	if err != nil {
+		return nil, err
+	}
+	return thyme.LoadColors(path)
+}
+
+// loadStream reads a stream from the JSON file in if it is set, or
+// from standard input if in is "-", from store if that is set, and from
+// the database at db otherwise, which may also be a .jsonl file, and
+// yields no snapshots if it doesn't exist. The JSON may also be a
+// single snapshot, or JSON lines.
+func loadStream(in, store, db string) (*thyme.Stream, error) {
+	if in == "" && store == "" && thyme.IsJSONLinesPath(db) {
+		store = db
+	}
+	if in == "" && store != "" {
+		s, _, err := thyme.OpenStore(store)
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", thyme.StoreName(store), err)
+		}
+		defer s.Close()
+		return s.Load()
+	}
+	if in == "" {
+		// Reading a database that doesn't exist would create it.
+		if _, err := os.Stat(db); os.IsNotExist(err) {
+			return &thyme.Stream{Snapshots: []*thyme.Snapshot{}}, nil
+		}
+		return thyme.LoadStream(db)
+	}
+	r := os.Stdin
+	if in != "-" {
+		f, err := os.Open(in)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	stream, err := thyme.ReadStream(r)
+	if err != nil {
+		return nil, err
+	}
+	if stream.Version > thyme.StreamVersion {
+		slog.Warn("the input was written by a newer version of thyme; some of it may be ignored", "file", in, "version", stream.Version, "supported", thyme.StreamVersion)
+	}
+	return stream, nil
+}
+
+// writeStream writes stream as JSON, in the current version of the
+// format, to the file at path, or as JSON lines, one snapshot per
+// line, if path ends in .jsonl or .jsonl.gz, compressing it with gzip
+// if path ends in .gz, or to stdout if path is "-".
+func writeStream(path string, stream *thyme.Stream) error {
+	if path == "-" {
+		return encodeStream(os.Stdout, stream, false, false)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := encodeStream(f, stream, strings.HasSuffix(path, ".gz"), thyme.IsJSONLinesPath(path)); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// encodeStream writes stream to w as writeStream does, compressing it
+// with gzip if compress is set, and as JSON lines if lines is.
+func encodeStream(w io.Writer, stream *thyme.Stream, compress, lines bool) error {
+	var zw *gzip.Writer
+	if compress {
+		zw = gzip.NewWriter(w)
+		w = zw
+	}
+	if lines {
+		if err := thyme.WriteJSONLines(w, stream); err != nil {
+			return err
+		}
+	} else {
+		versioned := *stream
+		versioned.Version = thyme.StreamVersion
+		if err := json.NewEncoder(w).Encode(&versioned); err != nil {
+			return err
+		}
+	}
+	if zw != nil {
+		return zw.Close()
+	}
+	return nil
+}
+
+// ImportCmd is the subcommand that merges data files.
+type ImportCmd struct {
+	Out  string `long:"out" short:"o" required:"true" description:"file to write the merged snapshots to, with their times in UTC, as JSON lines if it ends in .jsonl"`
+	Args struct {
+		Files []string `positional-arg-name:"file" required:"1"`
+	} `positional-args:"true"`
+}
+
+var importCmd ImportCmd
+
+func (c *ImportCmd) Execute(args []string) error {
+	var streams []*thyme.Stream
+	for _, file := range c.Args.Files {
+		stream, err := loadStream(file, "", "")
+		if err != nil {
+			return fmt.Errorf("import %s: %w", file, err)
+		}
+		streams = append(streams, stream)
+	}
+	// The files may come from machines in different time zones; the
+	// zone of each snapshot is kept in its Zone.
+	merged := thyme.MergeStreams(streams...).NormalizeZones()
+
+	if err := writeStream(c.Out, merged); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	return nil
+}
+
+// MigrateCmd is the subcommand that upgrades the database schema.
+type MigrateCmd struct {
+	DB    string `long:"db" env:"THYME_DB" description:"database to upgrade (default: the one thyme track records in)"`
+	Store string `long:"store" env:"THYME_STORE" description:"store to upgrade instead of --db: a sqlite database file, a .jsonl file or a postgres:// connection string"`
+}
+
+var migrateCmd MigrateCmd
+
+func (c *MigrateCmd) Execute(args []string) error {
+	store, name, applied, err := openStore(c.Store, c.DB)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if len(applied) == 0 {
+		fmt.Printf("%s is up to date (schema version %d)\n", name, thyme.SchemaVersion())
+		return nil
+	}
+	for _, m := range applied {
+		fmt.Printf("applied migration %d: %s\n", m.Version, m.Description)
+	}
+	fmt.Printf("%s is now at schema version %d\n", name, thyme.SchemaVersion())
+	return nil
+}
+
+type DepCmd struct{}
+
+var depCmd DepCmd
+
+func (c *DepCmd) Execute(args []string) error {
+	// The instructions are useful even before there is a display to
+	// track, e.g. when setting up a machine over SSH.
+	fmt.Println(platformTracker().Deps())
 	return nil
 }
 
 func main() {
+	logLevel.Set(slog.LevelWarn)
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
+
 	run := func() error {
 		_, err := CLI.Parse()
 		if err != nil {
@@ -179,18 +1117,57 @@ func main() {
 	}
 
 	if err := run(); err != nil {
-		log.Print(err)
+		slog.Error(err.Error())
 		os.Exit(1)
 	}
 }
 
-func getTracker() (thyme.Tracker, error) {
+// getTracker returns the tracker for this system, or an error if it
+// has no display server for the tracker to ask for windows. If
+// displays isn't empty, the tracker tracks those X displays; "auto"
+// stands for all the running X servers.
+func getTracker(displays []string) (thyme.Tracker, error) {
+	if len(displays) > 0 {
+		if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+			return nil, fmt.Errorf("--display is only supported on Linux")
+		}
+		var resolved []string
+		for _, d := range displays {
+			if d != "auto" {
+				resolved = append(resolved, d)
+				continue
+			}
+			found, err := thyme.XDisplays()
+			if err != nil {
+				return nil, err
+			}
+			resolved = append(resolved, found...)
+		}
+		if len(resolved) == 0 {
+			return nil, fmt.Errorf("no X server is running")
+		}
+		return &thyme.LinuxTracker{Displays: resolved}, nil
+	}
+	if runtime.GOOS != "windows" && runtime.GOOS != "darwin" {
+		if err := thyme.CheckDisplay(); err != nil {
+			return nil, err
+		}
+	}
+	return platformTracker(), nil
+}
+
+// platformTracker returns the tracker for this operating system and
+// windowing system.
+func platformTracker() thyme.Tracker {
 	switch runtime.GOOS {
 	case "windows":
-		return thyme.NewTracker("windows"), nil
+		return thyme.NewTracker("windows")
 	case "darwin":
-		return thyme.NewTracker("darwin"), nil
+		return thyme.NewTracker("darwin")
 	default:
-		return thyme.NewTracker("linux"), nil
+		if os.Getenv("XDG_SESSION_TYPE") == "wayland" || os.Getenv("WAYLAND_DISPLAY") != "" {
+			return thyme.NewTracker("wayland")
+		}
+		return thyme.NewTracker("linux")
 	}
 }
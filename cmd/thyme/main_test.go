@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	paris := time.FixedZone("CET", 3600)
+	laptop := &thyme.Stream{Snapshots: []*thyme.Snapshot{
+		testSnapshot(start, 1, "main.go - Code"),
+		testSnapshot(start.Add(2*time.Minute), 1, "main.go - Code"),
+	}}
+	// Recorded in another zone, and sharing a snapshot with laptop.
+	desktop := &thyme.Stream{Snapshots: []*thyme.Snapshot{
+		testSnapshot(start.Add(time.Minute).In(paris), 1, "~ - Terminal"),
+		testSnapshot(start.Add(2*time.Minute).In(paris), 1, "~ - Terminal"),
+	}}
+	out := filepath.Join(dir, "merged.json")
+	c := &ImportCmd{Out: out}
+	// laptop first, whose snapshot is kept over the one of desktop
+	// taken at the same time.
+	for _, f := range []struct {
+		name   string
+		stream *thyme.Stream
+	}{{"laptop.json", laptop}, {"desktop.jsonl", desktop}} {
+		path := filepath.Join(dir, f.name)
+		if err := writeStream(path, f.stream); err != nil {
+			t.Fatal(err)
+		}
+		c.Args.Files = append(c.Args.Files, path)
+	}
+	if err := c.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var merged thyme.Stream
+	if err := json.Unmarshal(b, &merged); err != nil {
+		t.Fatal(err)
+	}
+	want := []*thyme.Snapshot{laptop.Snapshots[0], desktop.Snapshots[0], laptop.Snapshots[1]}
+	checkSnapshots(t, merged.Snapshots, want)
+	for i, snap := range merged.Snapshots {
+		if snap.Time.Location() != time.UTC {
+			t.Errorf("snapshot %d taken at %s, want it in UTC", i, snap.Time)
+		}
+	}
+	if zone := merged.Snapshots[1].Zone; zone == "" {
+		t.Error("the snapshot of desktop lost its zone")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/mehdidc/thyme"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// trackMetrics are the Prometheus metrics exported by `thyme track
+// --metrics-addr`.
+type trackMetrics struct {
+	registry      *prometheus.Registry
+	activeSeconds *prometheus.CounterVec
+	snapshots     prometheus.Counter
+}
+
+func newTrackMetrics() *trackMetrics {
+	m := &trackMetrics{
+		registry: prometheus.NewRegistry(),
+		activeSeconds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "thyme_active_seconds_total",
+			Help: "Time spent with a window of the application active, in seconds.",
+		}, []string{"app"}),
+		snapshots: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "thyme_snapshots_total",
+			Help: "Number of snapshots taken.",
+		}),
+	}
+	m.registry.MustRegister(m.activeSeconds, m.snapshots)
+	return m
+}
+
+// observe attributes interval, the time between snapshots, to the
+// application active in snap.
+func (m *trackMetrics) observe(snap *thyme.Snapshot, interval time.Duration) {
+	m.snapshots.Inc()
+	if w := snap.ActiveWindow(); w != nil {
+		m.activeSeconds.WithLabelValues(thyme.AppID(w)).Add(interval.Seconds())
+	}
+}
+
+// serve serves the metrics at /metrics on addr in the background. The
+// returned listener is closed to stop serving.
+func (m *trackMetrics) serve(addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	go http.Serve(ln, mux)
+	return ln, nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/mehdidc/thyme"
+)
+
+// thymeHome returns the directory that holds the configuration files
+// and, by default, the database: the one set with --home, which falls
+// back to the THYME_HOME environment variable, or ~/.thyme.
+func thymeHome() (string, error) {
+	if globalOptions.Home != "" {
+		return globalOptions.Home, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".thyme"), nil
+}
+
+// configPath returns the path of the configuration file called name,
+// in the directory returned by thymeHome.
+func configPath(name string) (string, error) {
+	home, err := thymeHome()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, name), nil
+}
+
+// dbPath returns path if it is set, and the default location of the
+// database otherwise: thyme.db in the directory returned by thymeHome,
+// unless that doesn't exist yet, --home isn't set and XDG_DATA_HOME
+// is, in which case it is $XDG_DATA_HOME/thyme/thyme.db. The --db
+// flags fall back to the THYME_DB environment variable before getting
+// here.
+func dbPath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	legacy, err := configPath("thyme.db")
+	if err != nil {
+		return "", fmt.Errorf("locate database: %w", err)
+	}
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy, nil
+	}
+	if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" && globalOptions.Home == "" && runtime.GOOS != "windows" && runtime.GOOS != "darwin" {
+		return filepath.Join(xdg, "thyme", "thyme.db"), nil
+	}
+	return legacy, nil
+}
+
+// openStore opens the store described by the --store flag, store, or
+// if that isn't set, the sqlite database at the --db flag's path, db,
+// creating it and its directory if needed. It brings the store's
+// schema up to date, and returns the store, a name for it to show the
+// user, and the migrations that were applied.
+func openStore(store, db string) (thyme.Store, string, []*thyme.Migration, error) {
+	if store == "" {
+		path, err := dbPath(db)
+		if err != nil {
+			return nil, "", nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, "", nil, fmt.Errorf("create database directory: %w", err)
+		}
+		store = path
+	}
+	name := thyme.StoreName(store)
+	s, applied, err := thyme.OpenStore(store)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("open %s: %w", name, err)
+	}
+	return s, name, applied, nil
+}
+
+// openRecordedStore opens the store openStore does, to read what was
+// recorded in it, but returns a nil store rather than create the
+// sqlite database when store is empty and there is none at db's path
+// yet, e.g. because thyme track never ran.
+func openRecordedStore(store, db string) (thyme.Store, error) {
+	if store == "" {
+		path, err := dbPath(db)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil, nil
+		}
+	}
+	s, _, _, err := openStore(store, db)
+	return s, err
+}
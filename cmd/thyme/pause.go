@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PauseCmd is the subcommand that stops thyme track from recording
+// snapshots for a while.
+type PauseCmd struct {
+	For time.Duration `long:"for" default:"1h" description:"resume tracking automatically after this long (0 to stay paused until thyme resume)"`
+}
+
+var pauseCmd PauseCmd
+
+func (c *PauseCmd) Execute(args []string) error {
+	path, err := configPath("paused")
+	if err != nil {
+		return err
+	}
+	// The file holds the time tracking resumes at, or nothing if it
+	// only resumes with thyme resume.
+	var until string
+	if c.For > 0 {
+		until = time.Now().Add(c.For).Format(time.RFC3339) + "\n"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("pause: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(until), 0644); err != nil {
+		return fmt.Errorf("pause: %w", err)
+	}
+	return printPauseStatus()
+}
+
+// ResumeCmd is the subcommand that lets thyme track record snapshots
+// again after thyme pause.
+type ResumeCmd struct{}
+
+var resumeCmd ResumeCmd
+
+func (c *ResumeCmd) Execute(args []string) error {
+	path, err := configPath("paused")
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("resume: %w", err)
+	}
+	return printPauseStatus()
+}
+
+// StatusCmd is the subcommand that prints whether tracking is paused,
+// and the project snapshots are tagged with.
+type StatusCmd struct{}
+
+var statusCmd StatusCmd
+
+func (c *StatusCmd) Execute(args []string) error {
+	if err := printPauseStatus(); err != nil {
+		return err
+	}
+	project, err := currentProject()
+	if err != nil {
+		return err
+	}
+	if project == "" {
+		fmt.Println("no project set")
+	} else {
+		fmt.Printf("project: %s\n", project)
+	}
+	return nil
+}
+
+// printPauseStatus prints whether tracking is paused, and until when.
+func printPauseStatus() error {
+	paused, until, err := pausedUntil(time.Now())
+	switch {
+	case err != nil:
+		return err
+	case !paused:
+		fmt.Println("tracking")
+	case until.IsZero():
+		fmt.Println("paused until thyme resume")
+	default:
+		fmt.Printf("paused until %s (%s left)\n", until.Format("15:04:05"), formatSeconds(time.Until(until).Seconds()))
+	}
+	return nil
+}
+
+// pausedUntil returns whether tracking is paused at now by thyme
+// pause, which is kept in ~/.thyme/paused, and the time it resumes at,
+// which is zero if it only resumes with thyme resume. A pause that is
+// over is removed, so that it doesn't linger.
+func pausedUntil(now time.Time) (paused bool, until time.Time, err error) {
+	path, err := configPath("paused")
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, time.Time{}, nil
+	} else if err != nil {
+		return false, time.Time{}, err
+	}
+	if s := strings.TrimSpace(string(b)); s != "" {
+		if until, err = time.Parse(time.RFC3339, s); err != nil {
+			return false, time.Time{}, fmt.Errorf("could not parse pause file %s: %s", path, err)
+		}
+		if !now.Before(until) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return false, time.Time{}, err
+			}
+			return false, time.Time{}, nil
+		}
+	}
+	return true, until, nil
+}
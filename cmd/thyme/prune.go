@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+// PruneCmd is the subcommand that deletes old snapshots.
+type PruneCmd struct {
+	DB        string `long:"db" env:"THYME_DB" description:"database to prune (default: the one thyme track records in)"`
+	Store     string `long:"store" env:"THYME_STORE" description:"store to prune instead of --db: a sqlite database file, a .jsonl file or a postgres:// connection string"`
+	OlderThan string `long:"older-than" required:"true" description:"delete the snapshots taken longer ago than this (e.g. 90d)"`
+}
+
+var pruneCmd PruneCmd
+
+func (c *PruneCmd) Execute(args []string) error {
+	age, err := thyme.ParseDuration(c.OlderThan)
+	if err != nil {
+		return fmt.Errorf("--older-than: %w", err)
+	}
+	store, name, _, err := openStore(c.Store, c.DB)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	// Only sqlite stores are files whose size can be reported.
+	before, statErr := os.Stat(name)
+	cutoff := time.Now().Add(-age)
+	n, err := store.Prune(cutoff)
+	if err != nil {
+		return fmt.Errorf("prune: %w", err)
+	}
+	fmt.Printf("removed %d snapshots taken before %s\n", n, cutoff.Format(time.RFC3339))
+	if after, err := os.Stat(name); statErr == nil && err == nil {
+		fmt.Printf("%s went from %s to %s\n", name, formatBytes(before.Size()), formatBytes(after.Size()))
+	}
+	return nil
+}
+
+// formatBytes formats a number of bytes with a binary unit, e.g.
+// "1.5 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// prune deletes the snapshots in store that are older than
+// c.Retention, if it is set.
+func (c *TrackCmd) prune(store thyme.Store) error {
+	if c.Retention == "" {
+		return nil
+	}
+	age, err := thyme.ParseDuration(c.Retention)
+	if err != nil {
+		return fmt.Errorf("--retention: %w", err)
+	}
+	n, err := store.Prune(time.Now().Add(-age))
+	if err != nil {
+		return fmt.Errorf("prune: %w", err)
+	}
+	if n > 0 {
+		slog.Info("pruned snapshots", "count", n, "older_than", c.Retention)
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+// ReportCmd is the subcommand that writes a report on the last day,
+// week or month, and optionally mails it.
+type ReportCmd struct {
+	In            string        `long:"in" short:"i" description:"input file (default: read the database written by thyme track)"`
+	DB            string        `long:"db" env:"THYME_DB" description:"database to read if --in isn't set (default: the one thyme track records in)"`
+	Store         string        `long:"store" env:"THYME_STORE" description:"store to read instead of --db: a sqlite database file, a .jsonl file or a postgres:// connection string"`
+	Period        string        `long:"period" default:"week" choice:"day" choice:"week" choice:"month" description:"length of the period to report on"`
+	Current       bool          `long:"current" description:"report on the period still going on, rather than the last complete one"`
+	Format        string        `long:"format" default:"html" choice:"html" choice:"markdown" description:"write the stats page, as thyme show -w stats does, or the Markdown summary of thyme show -w markdown"`
+	Template      string        `long:"template" description:"with --format html, render the page with this html/template instead of ~/.thyme/report.tmpl, or the default one if that doesn't exist"`
+	IdleThreshold time.Duration `long:"idle-threshold" description:"don't count windows as active once the user has been idle this long (e.g. 5m)"`
+	IncludeLocked bool          `long:"include-locked" description:"count windows as active even while the screen was locked"`
+	SingleActive  bool          `long:"single-active" description:"attribute the time of snapshots recorded with thyme track --split-active to the focused window only, rather than splitting it among the windows active with it"`
+	MinDuration   time.Duration `long:"min-duration" description:"attribute the periods shorter than this (e.g. 30s) spent in an application, such as those of an alt-tab, to the application active before them"`
+	Gap           time.Duration `long:"gap" default:"15m" description:"the shortest break that ends a session in the Markdown summary"`
+	OutputDir     string        `long:"output-dir" description:"with --format html, write a report per day of the period to this directory, as YYYY-MM-DD.html, and an index.html linking to them, instead of printing a single report"`
+	MailTo        []string      `long:"mail-to" description:"mail the report to this address instead of printing it, with the SMTP server set in ~/.thyme/smtp.json or $THYME_SMTP_*; repeat for several recipients"`
+}
+
+var reportCmd ReportCmd
+
+// filter returns the filter of the snapshots set by the options of c.
+func (c *ReportCmd) filter() streamFilter {
+	return streamFilter{idleThreshold: c.IdleThreshold, includeLocked: c.IncludeLocked, singleActive: c.SingleActive, minDuration: c.MinDuration}
+}
+
+func (c *ReportCmd) Execute(args []string) error {
+	now := time.Now()
+	start, err := thyme.PeriodStart(now, c.Period)
+	if err != nil {
+		return err
+	}
+	end := time.Time{}
+	if !c.Current {
+		end = start
+		if start, err = thyme.PeriodStart(start.Add(-time.Nanosecond), c.Period); err != nil {
+			return err
+		}
+	}
+	if c.OutputDir != "" && c.Format != "html" {
+		return fmt.Errorf("--output-dir requires --format html")
+	}
+	if c.OutputDir != "" && len(c.MailTo) > 0 {
+		return fmt.Errorf("--output-dir and --mail-to are mutually exclusive")
+	}
+	var smtpConf *smtpConfig
+	if len(c.MailTo) > 0 {
+		// Fail before reading the snapshots if mail can't be sent.
+		if smtpConf, err = loadSMTPConfig(); err != nil {
+			return err
+		}
+	}
+
+	db, err := dbPath(c.DB)
+	if err != nil {
+		return err
+	}
+	stream, err := loadStream(c.In, c.Store, db)
+	if err != nil {
+		return err
+	}
+	stream = c.filter().apply(stream.Between(start, end))
+
+	var summary bytes.Buffer
+	if err := thyme.WriteMarkdown(&summary, stream, c.Gap); err != nil {
+		return err
+	}
+	report := summary.Bytes()
+	if c.Format == "html" {
+		cats, err := loadCategories()
+		if err != nil {
+			return err
+		}
+		goals, err := loadGoals()
+		if err != nil {
+			return err
+		}
+		colors, err := loadColors()
+		if err != nil {
+			return err
+		}
+		opts := thyme.StatsOptions{Categories: cats, Goals: goals, Colors: colors}
+		if opts.Template, err = loadStatsTemplate(c.Template); err != nil {
+			return err
+		}
+		if opts.Icons, err = iconResolver(); err != nil {
+			return err
+		}
+		if c.OutputDir != "" {
+			return writeDayReports(c.OutputDir, stream, func(w io.Writer, day *thyme.Stream) error {
+				return thyme.WriteStats(w, day, opts)
+			})
+		}
+		var page bytes.Buffer
+		if err := thyme.WriteStats(&page, stream, opts); err != nil {
+			return err
+		}
+		report = page.Bytes()
+	}
+
+	if len(c.MailTo) == 0 {
+		_, err := os.Stdout.Write(report)
+		return err
+	}
+	subject := "Thyme report for the " + periodName(start, c.Period)
+	var attachment *mailAttachment
+	if c.Format == "html" {
+		// Mail clients don't run the scripts that draw the charts, so
+		// the page goes along with the summary rather than in its
+		// place.
+		attachment = &mailAttachment{
+			name:        "thyme-" + c.Period + "-" + start.Format("2006-01-02") + ".html",
+			contentType: "text/html; charset=utf-8",
+			content:     report,
+		}
+	}
+	msg, err := mailMessage(smtpConf.From, c.MailTo, subject, summary.Bytes(), attachment, now)
+	if err != nil {
+		return err
+	}
+	if err := smtpConf.send(c.MailTo, msg); err != nil {
+		return fmt.Errorf("mail report: %w", err)
+	}
+	slog.Info("mailed report", "to", c.MailTo, "period", periodName(start, c.Period))
+	return nil
+}
+
+// writeDayReports writes the report of each day of stream, rendered
+// with render, to dir, creating it if needed, in the files named by
+// thyme.DayReportFile, and an index.html linking to them.
+func writeDayReports(dir string, stream *thyme.Stream, render func(w io.Writer, day *thyme.Stream) error) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create output directory: %w", err)
+	}
+	days := thyme.SplitDays(stream)
+	for _, d := range days {
+		var page bytes.Buffer
+		if err := render(&page, d.Stream); err != nil {
+			return fmt.Errorf("report of %s: %w", d.Date.Format("2006-01-02"), err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, thyme.DayReportFile(d.Date)), page.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	var index bytes.Buffer
+	if err := thyme.WriteDayIndex(&index, days); err != nil {
+		return fmt.Errorf("index: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), index.Bytes(), 0644); err != nil {
+		return err
+	}
+	slog.Info("wrote daily reports", "dir", dir, "days", len(days))
+	return nil
+}
+
+// smtpConfig is how to reach the SMTP server reports are mailed
+// through. It is read from ~/.thyme/smtp.json, e.g.
+//
+//	{"host": "smtp.example.com", "port": 587, "username": "me@example.com"}
+//
+// and the THYME_SMTP_HOST, THYME_SMTP_PORT, THYME_SMTP_USERNAME,
+// THYME_SMTP_PASSWORD and THYME_SMTP_FROM environment variables, which
+// take precedence. The password is best left to the environment.
+type smtpConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	// From is the sender of the reports, by default Username.
+	From string `json:"from"`
+}
+
+// loadSMTPConfig reads the smtpConfig, which must at least name the
+// server and, directly or through the username, the sender.
+func loadSMTPConfig() (*smtpConfig, error) {
+	path, err := configPath("smtp.json")
+	if err != nil {
+		return nil, err
+	}
+	conf := &smtpConfig{Port: 587}
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, conf); err != nil {
+			return nil, fmt.Errorf("could not parse SMTP file %s: %s", path, err)
+		}
+		if info, err := os.Stat(path); err == nil && conf.Password != "" && info.Mode().Perm()&0077 != 0 {
+			slog.Warn("SMTP file holds a password but others may read it; run chmod 600 on it, or set THYME_SMTP_PASSWORD instead", "path", path)
+		}
+	}
+	for _, env := range []struct {
+		name  string
+		value *string
+	}{
+		{"THYME_SMTP_HOST", &conf.Host},
+		{"THYME_SMTP_USERNAME", &conf.Username},
+		{"THYME_SMTP_PASSWORD", &conf.Password},
+		{"THYME_SMTP_FROM", &conf.From},
+	} {
+		if v := os.Getenv(env.name); v != "" {
+			*env.value = v
+		}
+	}
+	if v := os.Getenv("THYME_SMTP_PORT"); v != "" {
+		if conf.Port, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("THYME_SMTP_PORT: %w", err)
+		}
+	}
+	if conf.From == "" {
+		conf.From = conf.Username
+	}
+	if conf.Host == "" {
+		return nil, fmt.Errorf("--mail-to requires an SMTP server: set host in %s or THYME_SMTP_HOST", path)
+	}
+	if conf.From == "" {
+		return nil, fmt.Errorf("--mail-to requires a sender: set from or username in %s, or THYME_SMTP_FROM", path)
+	}
+	return conf, nil
+}
+
+// send mails msg to the recipients in to. Port 465 is spoken to over
+// TLS from the start; on other ports, the connection is upgraded with
+// STARTTLS if the server supports it, which it must for the password
+// to be sent.
+func (c *smtpConfig) send(to []string, msg []byte) error {
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	var auth smtp.Auth
+	if c.Username != "" {
+		auth = smtp.PlainAuth("", c.Username, c.Password, c.Host)
+	}
+	if c.Port != 465 {
+		return smtp.SendMail(addr, auth, c.From, to, msg)
+	}
+
+	conn, err := tls.Dial("tcp", addr, &tls.Config{ServerName: c.Host})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(c.From); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// mailAttachment is a file attached to a mail.
+type mailAttachment struct {
+	name, contentType string
+	content           []byte
+}
+
+// mailMessage returns a mail from from to the recipients in to, whose
+// body is the plain text body, with attachment attached if it isn't
+// nil.
+func mailMessage(from string, to []string, subject string, body []byte, attachment *mailAttachment, date time.Time) ([]byte, error) {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+
+	text := textproto.MIMEHeader{}
+	text.Set("Content-Type", "text/plain; charset=utf-8")
+	text.Set("Content-Transfer-Encoding", "base64")
+	if attachment == nil {
+		for k := range text {
+			fmt.Fprintf(&msg, "%s: %s\r\n", k, text.Get(k))
+		}
+		msg.WriteString("\r\n")
+		if err := writeBase64(&msg, body); err != nil {
+			return nil, err
+		}
+		return msg.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&msg)
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+	part, err := mw.CreatePart(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBase64(part, body); err != nil {
+		return nil, err
+	}
+	file := textproto.MIMEHeader{}
+	file.Set("Content-Type", attachment.contentType)
+	file.Set("Content-Transfer-Encoding", "base64")
+	file.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.name}))
+	if part, err = mw.CreatePart(file); err != nil {
+		return nil, err
+	}
+	if err := writeBase64(part, attachment.content); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return msg.Bytes(), nil
+}
+
+// writeBase64 writes b to w in base64, in lines of 76 characters as
+// MIME requires.
+func writeBase64(w io.Writer, b []byte) error {
+	s := base64.StdEncoding.EncodeToString(b)
+	for len(s) > 0 {
+		n := min(len(s), 76)
+		if _, err := io.WriteString(w, s[:n]+"\r\n"); err != nil {
+			return err
+		}
+		s = s[n:]
+	}
+	return nil
+}
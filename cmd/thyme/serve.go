@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+// ServeCmd is the subcommand that serves the stats report over HTTP.
+type ServeCmd struct {
+	In            string        `long:"in" short:"i" description:"input file (default: read the database written by thyme track)"`
+	DB            string        `long:"db" env:"THYME_DB" description:"database to read if --in isn't set (default: the one thyme track records in)"`
+	Store         string        `long:"store" env:"THYME_STORE" description:"store to read instead of --db: a sqlite database file, a .jsonl file or a postgres:// connection string"`
+	Addr          string        `long:"addr" default:"localhost:8080" description:"address to listen on; use :8080 to accept connections from other machines"`
+	IdleThreshold time.Duration `long:"idle-threshold" description:"default for the idle-threshold parameter: don't count windows as active once the user has been idle this long (e.g. 5m)"`
+	IncludeLocked bool          `long:"include-locked" description:"default for the include-locked parameter: count windows as active even while the screen was locked"`
+	SingleActive  bool          `long:"single-active" description:"default for the single-active parameter: attribute the time of snapshots recorded with thyme track --split-active to the focused window only"`
+	MinDuration   time.Duration `long:"min-duration" description:"default for the min-duration parameter: attribute the periods shorter than this (e.g. 30s) spent in an application to the application active before them"`
+	TZ            string        `long:"tz" description:"default for the tz parameter: time zone to group by hour and day in, e.g. Europe/Paris, or recorded for the zone each snapshot was taken in (default: the local time zone)"`
+	Template      string        `long:"template" description:"render the page with this html/template instead of ~/.thyme/report.tmpl, or the default one if that doesn't exist"`
+	RebuildCache  bool          `long:"rebuild-cache" description:"on startup, recompute the cached totals of past days that /days.json serves from the snapshots of the sqlite database, for the defaults of the parameters"`
+}
+
+var serveCmd ServeCmd
+
+// serveShutdownTimeout is how long requests in flight get to finish
+// once thyme serve is interrupted.
+const serveShutdownTimeout = 5 * time.Second
+
+func (c *ServeCmd) Execute(args []string) error {
+	db, err := dbPath(c.DB)
+	if err != nil {
+		return err
+	}
+	if c.RebuildCache {
+		loc, err := loadLocation(c.TZ)
+		if err != nil {
+			return fmt.Errorf("--tz: %w", err)
+		}
+		if _, err := readDayTotals(c.In, c.Store, db, thyme.DayTotalsOptions{
+			Location:      loc,
+			IdleThreshold: c.IdleThreshold,
+			IncludeLocked: c.IncludeLocked,
+			SingleActive:  c.SingleActive,
+			Rebuild:       true,
+		}); err != nil {
+			return err
+		}
+	}
+	ln, err := net.Listen("tcp", c.Addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", c.handle(db, serveStats))
+	mux.HandleFunc("GET /summary.json", c.handle(db, serveSummary))
+	mux.HandleFunc("GET /days.json", c.handleDays(db))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	fmt.Printf("Serving the report at http://%s/\n", ln.Addr())
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// serveQuery is what a request to thyme serve asks for.
+type serveQuery struct {
+	since, until time.Time
+	filter       streamFilter
+	loc          *time.Location
+	group        *thyme.Grouping
+	format       string
+	template     string
+}
+
+// parseQuery parses the query parameters of r, falling back to the
+// flags of thyme serve for those that aren't set.
+func (c *ServeCmd) parseQuery(r *http.Request, now time.Time) (*serveQuery, error) {
+	params := r.URL.Query()
+	q := &serveQuery{format: "html", template: c.Template}
+	q.filter = streamFilter{idleThreshold: c.IdleThreshold, includeLocked: c.IncludeLocked, singleActive: c.SingleActive, minDuration: c.MinDuration}
+	tz := c.TZ
+	if v := params.Get("tz"); v != "" {
+		tz = v
+	}
+	var err error
+	if q.loc, err = loadLocation(tz); err != nil {
+		return nil, fmt.Errorf("tz: %w", err)
+	}
+	if v := params.Get("since"); v != "" {
+		if q.since, err = thyme.ParseTime(v, now); err != nil {
+			return nil, fmt.Errorf("since: %w", err)
+		}
+	}
+	if v := params.Get("until"); v != "" {
+		if q.until, err = thyme.ParseTime(v, now); err != nil {
+			return nil, fmt.Errorf("until: %w", err)
+		}
+	}
+	if !q.since.IsZero() && !q.until.IsZero() && q.until.Before(q.since) {
+		return nil, fmt.Errorf("until (%s) is before since (%s)", q.until.Format(time.RFC3339), q.since.Format(time.RFC3339))
+	}
+	if v := params.Get("group-by"); v != "" {
+		if q.group, err = thyme.LookupGrouping(v); err != nil {
+			return nil, fmt.Errorf("group-by: %w", err)
+		}
+	}
+	if v := params.Get("idle-threshold"); v != "" {
+		if q.filter.idleThreshold, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("idle-threshold: %w", err)
+		}
+	}
+	if v := params.Get("include-locked"); v != "" {
+		if q.filter.includeLocked, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("include-locked: %w", err)
+		}
+	}
+	if v := params.Get("single-active"); v != "" {
+		if q.filter.singleActive, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("single-active: %w", err)
+		}
+	}
+	if v := params.Get("min-duration"); v != "" {
+		if q.filter.minDuration, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("min-duration: %w", err)
+		}
+	}
+	switch v := params.Get("format"); v {
+	case "":
+	case "html", "svg":
+		q.format = v
+	default:
+		return nil, fmt.Errorf("format: must be html or svg, not %q", v)
+	}
+	return q, nil
+}
+
+// handle returns a handler that reads the stream anew, so that
+// responses reflect the snapshots recorded since thyme serve started,
+// filters it according to the query parameters of the request and
+// passes it on to render. The parameters are:
+//
+//   - since and until, in the formats accepted by thyme show --since
+//   - group-by, as for thyme show --group-by
+//   - idle-threshold, a duration such as 5m
+//   - include-locked and single-active, true or false
+//   - min-duration, a duration such as 30s
+//   - tz, a time zone such as Europe/Paris, or recorded
+//   - format, html or svg, for the report
+//
+// Invalid parameters are answered with 400 Bad Request.
+func (c *ServeCmd) handle(db string, render func(http.ResponseWriter, *thyme.Stream, *thyme.Categories, *serveQuery) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := c.parseQuery(r, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		stream, err := loadStream(c.In, c.Store, db)
+		if err != nil {
+			slog.Error("could not load snapshots", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		cats, err := loadCategories()
+		if err != nil {
+			slog.Error("could not load categories", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		stream = q.filter.apply(inLocation(stream.Between(q.since, q.until), q.loc))
+		if err := render(w, stream, cats, q); err != nil {
+			slog.Error("could not render response", "path", r.URL.Path, "error", err)
+		}
+	}
+}
+
+// dayTotalsOptions returns the options to read the totals of each day
+// with for q.
+func (q *serveQuery) dayTotalsOptions() thyme.DayTotalsOptions {
+	return thyme.DayTotalsOptions{
+		Location:      q.loc,
+		IdleThreshold: q.filter.idleThreshold,
+		IncludeLocked: q.filter.includeLocked,
+		SingleActive:  q.filter.singleActive,
+	}
+}
+
+// handleDays returns a handler that serves the totals of each day as
+// JSON, as thyme show -w days prints them. It takes the parameters of
+// handle but group-by, min-duration and format, and serves whole days,
+// which the totals of past days are read for from the cache in the
+// database.
+func (c *ServeCmd) handleDays(db string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := c.parseQuery(r, time.Now())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		days, err := readDayTotals(c.In, c.Store, db, q.dayTotalsOptions())
+		if err != nil {
+			slog.Error("could not total days", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		days = daysBetween(days, q.since, q.until, q.loc)
+		if days == nil {
+			days = []*thyme.DayTotals{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(days); err != nil {
+			slog.Error("could not render response", "path", r.URL.Path, "error", err)
+		}
+	}
+}
+
+// serveStats renders the stats report, as thyme show -w stats does.
+func serveStats(w http.ResponseWriter, stream *thyme.Stream, cats *thyme.Categories, q *serveQuery) error {
+	colors, err := loadColors()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	opts := thyme.StatsOptions{Categories: cats, Group: q.group, Colors: colors}
+	if q.format == "svg" {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		return thyme.StatsSVG(w, stream, opts)
+	}
+	if opts.Goals, err = loadGoals(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	// Read the template anew too, so that changes to it show on reload.
+	if opts.Template, err = loadStatsTemplate(q.template); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	if opts.Icons, err = iconResolver(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return thyme.WriteStats(w, stream, opts)
+}
+
+// serveSummary renders the summary of active time as JSON, as thyme
+// show -w json does.
+func serveSummary(w http.ResponseWriter, stream *thyme.Stream, cats *thyme.Categories, q *serveQuery) error {
+	summary := thyme.Summarize(stream)
+	if q.group != nil {
+		summary.Group(stream, q.group, cats)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(summary)
+}
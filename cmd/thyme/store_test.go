@@ -0,0 +1,152 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+// memStore is a thyme.Store that keeps snapshots in memory.
+type memStore struct {
+	snapshots []*thyme.Snapshot
+}
+
+var _ thyme.Store = (*memStore)(nil)
+
+func (s *memStore) Append(snap *thyme.Snapshot) error {
+	if n := len(s.snapshots); n > 0 && s.snapshots[n-1].Time.Equal(snap.Time) {
+		return nil
+	}
+	c := *snap
+	s.snapshots = append(s.snapshots, &c)
+	return nil
+}
+
+func (s *memStore) Last() (*thyme.Snapshot, error) {
+	if len(s.snapshots) == 0 {
+		return nil, nil
+	}
+	c := *s.snapshots[len(s.snapshots)-1]
+	return &c, nil
+}
+
+func (s *memStore) UpdateLast(snap *thyme.Snapshot) error {
+	c := *snap
+	s.snapshots[len(s.snapshots)-1] = &c
+	return nil
+}
+
+func (s *memStore) Load() (*thyme.Stream, error) {
+	return &thyme.Stream{Snapshots: append([]*thyme.Snapshot{}, s.snapshots...)}, nil
+}
+
+func (s *memStore) Prune(before time.Time) (int64, error) {
+	var kept []*thyme.Snapshot
+	for _, snap := range s.snapshots {
+		if !snap.Time.Before(before) {
+			kept = append(kept, snap)
+		}
+	}
+	n := int64(len(s.snapshots) - len(kept))
+	s.snapshots = kept
+	return n, nil
+}
+
+func (s *memStore) Close() error {
+	return nil
+}
+
+// trackInto runs thyme track once per snapshot of snaps, as cron
+// would, with the options of c, recording them in store.
+func trackInto(t *testing.T, c TrackCmd, store thyme.Store, snaps ...*thyme.Snapshot) {
+	t.Helper()
+	tracker := &fakeTracker{snapshots: snaps}
+	for range snaps {
+		run := c
+		run.tracker, run.store = tracker, store
+		if err := run.Execute(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTrackStoreDedup(t *testing.T) {
+	useTempHome(t)
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	snaps := []*thyme.Snapshot{
+		testSnapshot(start, 1, "main.go - Code"),
+		testSnapshot(start.Add(time.Minute), 1, "main.go - Code"),
+		testSnapshot(start.Add(2*time.Minute), 1, "thyme.go - Code"),
+		testSnapshot(start.Add(3*time.Minute), 1, "thyme.go - Code"),
+		// Too long after the previous one to stand for the time
+		// in between.
+		testSnapshot(start.Add(time.Hour), 1, "thyme.go - Code"),
+	}
+
+	store := &memStore{}
+	trackInto(t, TrackCmd{}, store, snaps...)
+	checkSnapshots(t, store.snapshots, []*thyme.Snapshot{snaps[0], snaps[2], snaps[4]})
+	for i, end := range []time.Time{snaps[1].Time, snaps[3].Time, {}} {
+		if got := store.snapshots[i].EndTime; !got.Equal(end) {
+			t.Errorf("snapshot %d ends at %s, want %s", i, got, end)
+		}
+	}
+
+	store = &memStore{}
+	trackInto(t, TrackCmd{NoDedup: true}, store, snaps...)
+	checkSnapshots(t, store.snapshots, snaps)
+}
+
+func TestTrackStoreRetention(t *testing.T) {
+	useTempHome(t)
+	now := time.Now()
+	store := &memStore{snapshots: []*thyme.Snapshot{
+		testSnapshot(now.Add(-48*time.Hour), 1, "old - Code"),
+		testSnapshot(now.Add(-12*time.Hour), 1, "recent - Code"),
+	}}
+	kept := store.snapshots[1]
+	snap := testSnapshot(now, 1, "main.go - Code")
+	trackInto(t, TrackCmd{Retention: "1d"}, store, snap)
+	checkSnapshots(t, store.snapshots, []*thyme.Snapshot{kept, snap})
+}
+
+func TestTrackStoreExport(t *testing.T) {
+	home := useTempHome(t)
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	store := &memStore{snapshots: []*thyme.Snapshot{
+		testSnapshot(start, 1, "main.go - Code"),
+		testSnapshot(start.Add(time.Minute), 1, "thyme.go - Code"),
+	}}
+	out := home + "/export.json"
+	c := TrackCmd{Out: out, store: store, tracker: &fakeTracker{snapshots: []*thyme.Snapshot{
+		testSnapshot(start.Add(2*time.Minute), 1, "ignored - Code"),
+		testSnapshot(start.Add(3*time.Minute), 1, "ignored - Code"),
+	}}}
+	if err := c.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := loadStream(out, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSnapshots(t, stream.Snapshots, store.snapshots)
+	// The snapshots don't say which interval they were taken at,
+	// which mustn't be written down as if they did.
+	if stream.Interval != 0 {
+		t.Errorf("exported stream has interval %s, want none", stream.Interval)
+	}
+
+	for _, snap := range store.snapshots {
+		snap.Interval = 30 * time.Second
+	}
+	if err := c.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	if stream, err = loadStream(out, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if stream.Interval != 30*time.Second {
+		t.Errorf("exported stream has interval %s, want 30s", stream.Interval)
+	}
+}
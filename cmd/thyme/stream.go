@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// StreamCmd is the subcommand that prints snapshots to stdout as they
+// are taken, as JSON lines, without recording them.
+type StreamCmd struct {
+	Interval   time.Duration `long:"interval" short:"n" default:"30s" description:"how often to take a snapshot"`
+	Timeout    time.Duration `long:"timeout" default:"5s" description:"give up on a snapshot that takes longer than this (0 for no limit)"`
+	ActiveOnly bool          `long:"active-only" description:"only print the active window, rather than every open window"`
+	NoRedact   bool          `long:"no-redact" description:"print window titles as they are, even those matching the patterns in ~/.thyme/redact.json"`
+	HashTitles bool          `long:"hash-titles" description:"print a salted hash of each window title instead of the title itself, as with thyme track --hash-titles"`
+	NoIgnore   bool          `long:"no-ignore" description:"print the windows of every application, even those matching the patterns in ~/.thyme/ignore.json"`
+	Display    []string      `long:"display" description:"X display to track instead of $DISPLAY, as with thyme track (Linux only)"`
+}
+
+var streamCmd StreamCmd
+
+func (c *StreamCmd) Execute(args []string) error {
+	if c.Interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	t, err := getTracker(c.Display)
+	if err != nil {
+		return err
+	}
+	// The snapshots go through the same filters as those thyme track
+	// records, since whatever reads them may send them further.
+	track := &TrackCmd{Interval: c.Interval, Timeout: c.Timeout, ActiveOnly: c.ActiveOnly, NoRedact: c.NoRedact, HashTitles: c.HashTitles, NoIgnore: c.NoIgnore}
+	if err := track.loadPrivacy(); err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	out := bufio.NewWriter(os.Stdout)
+	enc := json.NewEncoder(out)
+	var paused bool
+	for {
+		if paused = pauseState(paused); !paused {
+			snap, err := track.snap(ctx, t)
+			switch {
+			case ctx.Err() != nil:
+				return nil
+			case err != nil:
+				slog.Error("could not take snapshot", "error", err)
+			default:
+				snap.Interval = c.Interval
+				// Flush every line, so that whatever reads them sees
+				// each snapshot as soon as it is taken.
+				if err := enc.Encode(snap); err != nil {
+					return fmt.Errorf("write snapshot: %w", err)
+				}
+				if err := out.Flush(); err != nil {
+					return fmt.Errorf("write snapshot: %w", err)
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			slog.Debug("stopped streaming", "cause", context.Cause(ctx))
+			return nil
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+// SummaryCmd is the subcommand that prints today's active time on one
+// line, e.g. for a shell prompt or a status bar.
+type SummaryCmd struct {
+	In            string        `long:"in" short:"i" description:"input file (default: read the database written by thyme track)"`
+	DB            string        `long:"db" env:"THYME_DB" description:"database to read if --in isn't set (default: the one thyme track records in)"`
+	Store         string        `long:"store" env:"THYME_STORE" description:"store to read instead of --db: a sqlite database file, a .jsonl file or a postgres:// connection string"`
+	Today         bool          `long:"today" description:"summarize today, since midnight local time, which is what is summarized anyway"`
+	Format        string        `long:"format" default:"{{hm .Active}}{{with .App}} · {{.}}{{end}}" description:"text/template to print, with .Active, the active time, .App, the application active the longest, .AppActive, how long it was, and .Date; hm formats a time as e.g. 2h14m"`
+	IdleThreshold time.Duration `long:"idle-threshold" description:"don't count windows as active once the user has been idle this long (e.g. 5m)"`
+	IncludeLocked bool          `long:"include-locked" description:"count windows as active even while the screen was locked"`
+	SingleActive  bool          `long:"single-active" description:"attribute the time of snapshots recorded with thyme track --split-active to the focused window only, rather than splitting it among the windows active with it"`
+}
+
+var summaryCmd SummaryCmd
+
+// summaryLine is what the --format template of thyme summary is
+// executed with.
+type summaryLine struct {
+	// Date is the day summarized, as midnight local time.
+	Date time.Time
+
+	// Active is how long any window was active on Date.
+	Active time.Duration
+
+	// App is the application that was active the longest on Date,
+	// for AppActive, or "" if none was.
+	App       string
+	AppActive time.Duration
+}
+
+func (c *SummaryCmd) Execute(args []string) error {
+	tmpl, err := template.New("format").Funcs(template.FuncMap{"hm": hm}).Parse(c.Format)
+	if err != nil {
+		return fmt.Errorf("--format: %w", err)
+	}
+	line := &summaryLine{Date: startOfDay(time.Now())}
+	totals, err := c.totals(line.Date)
+	if err != nil {
+		return err
+	}
+	for _, t := range totals {
+		d := time.Duration(t.ActiveSeconds * float64(time.Second))
+		line.Active += d
+		if d > line.AppActive {
+			line.App, line.AppActive = t.Label, d
+		}
+	}
+	if err := tmpl.Execute(os.Stdout, line); err != nil {
+		return fmt.Errorf("--format: %w", err)
+	}
+	fmt.Println()
+	return nil
+}
+
+// totals returns the totals of each application on day. As with thyme
+// top, they come from the per-day totals cached in the database when
+// reading one that caches them, so that only today's snapshots are
+// read and the command stays fast enough to be run every few seconds.
+func (c *SummaryCmd) totals(day time.Time) ([]*thyme.Total, error) {
+	if c.In == "" {
+		s, err := openRecordedStore(c.Store, c.DB)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			defer s.Close()
+		}
+		if cache, ok := s.(thyme.DayTotaler); ok {
+			days, err := cache.DayTotals(thyme.DayTotalsOptions{
+				IdleThreshold: c.IdleThreshold,
+				IncludeLocked: c.IncludeLocked,
+				SingleActive:  c.SingleActive,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("read cached totals: %w", err)
+			}
+			y, m, d := day.Date()
+			if n := len(days); n > 0 && days[n-1].Date.Equal(time.Date(y, m, d, 0, 0, 0, 0, time.UTC)) {
+				return days[n-1].Apps, nil
+			}
+			return nil, nil
+		}
+	}
+
+	db, err := dbPath(c.DB)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := loadStream(c.In, c.Store, db)
+	if err != nil {
+		return nil, err
+	}
+	return thyme.Aggregate(stream, thyme.AggregateOptions{
+		Since:         day,
+		IdleThreshold: c.IdleThreshold,
+		IncludeLocked: c.IncludeLocked,
+		SingleActive:  c.SingleActive,
+	}).Totals, nil
+}
+
+// hm formats d to the minute, e.g. as "2h14m" or "14m", for the
+// --format template of thyme summary.
+func hm(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if h := int(d.Hours()); h > 0 {
+		return fmt.Sprintf("%dh%02dm", h, int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%dm", int(d.Minutes()))
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// TagCmd is the subcommand that sets the project snapshots are
+// tagged with.
+type TagCmd struct {
+	Clear bool `long:"clear" description:"stop tagging snapshots with a project"`
+	Args  struct {
+		Project string `positional-arg-name:"project"`
+	} `positional-args:"true"`
+}
+
+var tagCmd TagCmd
+
+func (c *TagCmd) Execute(args []string) error {
+	path, err := configPath("project")
+	if err != nil {
+		return err
+	}
+	project := strings.TrimSpace(c.Args.Project)
+	switch {
+	case c.Clear:
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("clear project: %w", err)
+		}
+		return nil
+	case project == "":
+		current, err := currentProject()
+		if err != nil {
+			return err
+		}
+		if current == "" {
+			fmt.Println("no project set")
+		} else {
+			fmt.Println(current)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("set project: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(project+"\n"), 0644); err != nil {
+		return fmt.Errorf("set project: %w", err)
+	}
+	return nil
+}
+
+// currentProject returns the project set with thyme tag, which is
+// kept in ~/.thyme/project, or an empty string if there is none.
+func currentProject() (string, error) {
+	path, err := configPath("project")
+	if err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
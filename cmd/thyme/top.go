@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+// TopCmd is the subcommand that prints the applications or windows
+// with the most active time.
+type TopCmd struct {
+	In            string        `long:"in" short:"i" description:"input file (default: read the database written by thyme track)"`
+	DB            string        `long:"db" env:"THYME_DB" description:"database to read if --in isn't set (default: the one thyme track records in)"`
+	Store         string        `long:"store" env:"THYME_STORE" description:"store to read instead of --db: a sqlite database file, a .jsonl file or a postgres:// connection string"`
+	Limit         int           `long:"limit" short:"l" default:"10" description:"how many rows to print (0 for all)"`
+	By            string        `long:"by" default:"app" choice:"app" choice:"title" choice:"document" description:"group active time by application, window title, or document within an application (e.g. the file open in an editor)"`
+	IdleThreshold time.Duration `long:"idle-threshold" description:"don't count windows as active once the user has been idle this long (e.g. 5m)"`
+	IncludeLocked bool          `long:"include-locked" description:"count windows as active even while the screen was locked"`
+	SingleActive  bool          `long:"single-active" description:"attribute the time of snapshots recorded with thyme track --split-active to the focused window only, rather than splitting it among the windows active with it"`
+	RebuildCache  bool          `long:"rebuild-cache" description:"recompute the cached totals of past days in the sqlite database from its snapshots"`
+}
+
+var topCmd TopCmd
+
+// topBarWidth is the width, in characters, of the longest bar.
+const topBarWidth = 30
+
+// topLabelWidth is the width, in characters, beyond which labels are
+// truncated.
+const topLabelWidth = 48
+
+func (c *TopCmd) Execute(args []string) error {
+	totals, err := c.totals()
+	if err != nil {
+		return err
+	}
+
+	var sum float64
+	for _, t := range totals {
+		sum += t.ActiveSeconds
+	}
+	if c.Limit > 0 && len(totals) > c.Limit {
+		totals = totals[:c.Limit]
+	}
+	var longest float64
+	for _, t := range totals {
+		longest = max(longest, t.ActiveSeconds)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "#\t%s\tACTIVE\tSHARE\t\n", strings.ToUpper(c.By))
+	for i, t := range totals {
+		if t.ActiveSeconds == 0 {
+			break
+		}
+		bar := strings.Repeat("█", int(float64(topBarWidth)*t.ActiveSeconds/longest+0.5))
+		fmt.Fprintf(w, "%d\t%s\t%s\t%3.0f%%\t%s\n", i+1, truncate(t.Label, topLabelWidth), formatSeconds(t.ActiveSeconds), 100*t.ActiveSeconds/sum, bar)
+	}
+	return w.Flush()
+}
+
+// totals returns the totals to print. Applications are totaled from
+// the per-day totals cached in the database, when reading one that
+// caches them, so that only the snapshots taken since the start of the
+// last day are read.
+func (c *TopCmd) totals() ([]*thyme.Total, error) {
+	if c.In == "" && c.By == "app" {
+		s, err := openRecordedStore(c.Store, c.DB)
+		if err != nil {
+			return nil, err
+		}
+		if s != nil {
+			defer s.Close()
+		}
+		if cache, ok := s.(thyme.DayTotaler); ok {
+			days, err := cache.DayTotals(thyme.DayTotalsOptions{
+				IdleThreshold: c.IdleThreshold,
+				IncludeLocked: c.IncludeLocked,
+				SingleActive:  c.SingleActive,
+				Rebuild:       c.RebuildCache,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("read cached totals: %w", err)
+			}
+			return thyme.SumDayTotals(days), nil
+		}
+	}
+
+	db, err := dbPath(c.DB)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := loadStream(c.In, c.Store, db)
+	if err != nil {
+		return nil, err
+	}
+	group, err := thyme.LookupGrouping(c.By)
+	if err != nil {
+		return nil, err
+	}
+	return thyme.Aggregate(stream, thyme.AggregateOptions{
+		Group:         group,
+		IdleThreshold: c.IdleThreshold,
+		IncludeLocked: c.IncludeLocked,
+		SingleActive:  c.SingleActive,
+	}).Totals, nil
+}
+
+// formatSeconds formats a number of seconds as e.g. "2h05m" or "4m30s".
+func formatSeconds(s float64) string {
+	d := time.Duration(s * float64(time.Second)).Round(time.Second)
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%dh%02dm", h, m)
+	case m > 0:
+		return fmt.Sprintf("%dm%02ds", m, int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+}
+
+// truncate shortens s to at most n characters, marking the cut with
+// an ellipsis.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
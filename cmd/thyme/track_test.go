@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+// errNoSnapshots is returned by fakeTracker.Snap once it has returned
+// all its snapshots.
+var errNoSnapshots = errors.New("no more scripted snapshots")
+
+// fakeTracker is a Tracker that returns snapshots given in advance
+// rather than observing the window system.
+type fakeTracker struct {
+	snapshots []*thyme.Snapshot
+
+	// done, if set, is called once all the snapshots have been taken,
+	// e.g. to stop thyme track -n.
+	done func()
+
+	// taken, if set, is called after each snapshot is taken with how
+	// many have been.
+	taken func(n int)
+	n     int
+}
+
+var _ thyme.Tracker = (*fakeTracker)(nil)
+
+// Snap returns a copy of the next scripted snapshot, as thyme track
+// modifies the snapshots it takes, or errNoSnapshots.
+func (t *fakeTracker) Snap(ctx context.Context) (*thyme.Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(t.snapshots) == 0 {
+		if t.done != nil {
+			t.done()
+		}
+		return nil, errNoSnapshots
+	}
+	next := t.snapshots[0]
+	t.snapshots = t.snapshots[1:]
+	snap := *next
+	snap.Windows = make([]*thyme.Window, len(next.Windows))
+	for i, w := range next.Windows {
+		c := *w
+		snap.Windows[i] = &c
+	}
+	snap.Visible = append([]int64(nil), next.Visible...)
+	if t.n++; t.taken != nil {
+		t.taken(t.n)
+	}
+	return &snap, nil
+}
+
+func (t *fakeTracker) Deps() string {
+	return ""
+}
+
+// useTempHome points the configuration directory, and so the default
+// database, at a directory that is removed at the end of the test.
+func useTempHome(t *testing.T) string {
+	t.Helper()
+	home := globalOptions.Home
+	globalOptions.Home = t.TempDir()
+	t.Cleanup(func() { globalOptions.Home = home })
+	return globalOptions.Home
+}
+
+// testSnapshot returns a snapshot taken at at, of visible windows with
+// the given names and IDs from 1, the one with ID active being active.
+func testSnapshot(at time.Time, active int64, names ...string) *thyme.Snapshot {
+	snap := &thyme.Snapshot{Time: at, Active: active}
+	for i, name := range names {
+		id := int64(i + 1)
+		snap.Windows = append(snap.Windows, &thyme.Window{ID: id, Name: name})
+		snap.Visible = append(snap.Visible, id)
+	}
+	return snap
+}
+
+// loadDB returns the snapshots recorded in the database at path.
+func loadDB(t *testing.T, path string) []*thyme.Snapshot {
+	t.Helper()
+	store, _, err := thyme.OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	stream, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stream.Snapshots
+}
+
+// checkSnapshots reports how the snapshots got differ from want, in
+// their times, windows and active window.
+func checkSnapshots(t *testing.T, got, want []*thyme.Snapshot) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d snapshots, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		g := got[i]
+		if !g.Time.Equal(w.Time) {
+			t.Errorf("snapshot %d: time %s, want %s", i, g.Time, w.Time)
+		}
+		if g.Active != w.Active {
+			t.Errorf("snapshot %d: active %d, want %d", i, g.Active, w.Active)
+		}
+		if len(g.Windows) != len(w.Windows) {
+			t.Errorf("snapshot %d: %d windows, want %d", i, len(g.Windows), len(w.Windows))
+			continue
+		}
+		for j, win := range w.Windows {
+			if *g.Windows[j] != *win {
+				t.Errorf("snapshot %d: window %d is %+v, want %+v", i, j, *g.Windows[j], *win)
+			}
+		}
+	}
+}
+
+func TestTrackRecordsSnapshots(t *testing.T) {
+	home := useTempHome(t)
+	db := filepath.Join(home, "thyme.db")
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	want := []*thyme.Snapshot{
+		testSnapshot(start, 1, "main.go - Code", "~ - Terminal"),
+		testSnapshot(start.Add(time.Minute), 2, "main.go - Code", "~ - Terminal"),
+		testSnapshot(start.Add(2*time.Minute), 1, "thyme.go - Code"),
+	}
+	// As when thyme track is run by cron, once per snapshot.
+	tracker := &fakeTracker{snapshots: want}
+	for range want {
+		c := &TrackCmd{DB: db, tracker: tracker}
+		if err := c.Execute(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkSnapshots(t, loadDB(t, db), want)
+}
+
+func TestTrackExport(t *testing.T) {
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name  string
+		snaps []*thyme.Snapshot
+	}{
+		{name: "no snapshots"},
+		{name: "one snapshot", snaps: []*thyme.Snapshot{testSnapshot(start, 1, "main.go - Code", "~ - Terminal")}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			home := useTempHome(t)
+			db := filepath.Join(home, "thyme.db")
+			out := filepath.Join(home, "thyme.json")
+			tracker := &fakeTracker{snapshots: tt.snaps}
+			for range tt.snaps {
+				if err := (&TrackCmd{DB: db, tracker: tracker}).Execute(nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// -o takes a snapshot too, which it doesn't record.
+			export := &fakeTracker{snapshots: []*thyme.Snapshot{testSnapshot(start.Add(time.Minute), 1, "thyme.go - Code")}}
+			if err := (&TrackCmd{DB: db, Out: out, tracker: export}).Execute(nil); err != nil {
+				t.Fatal(err)
+			}
+			b, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var stream thyme.Stream
+			if err := json.Unmarshal(b, &stream); err != nil {
+				t.Fatalf("-o wrote invalid JSON: %s\n%s", err, b)
+			}
+			if stream.Snapshots == nil {
+				t.Errorf("-o wrote no list of snapshots:\n%s", b)
+			}
+			checkSnapshots(t, stream.Snapshots, tt.snaps)
+		})
+	}
+}
+
+// openFiles returns how many files the test process has open, and
+// skips the test where that can't be told.
+func openFiles(t *testing.T) int {
+	t.Helper()
+	fds, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("can't count open files: %s", err)
+	}
+	return len(fds)
+}
+
+// TestTrackClosesFiles runs thyme track many times in the same
+// process, recording a snapshot and then writing them all out with -o
+// each time, and checks that the database and the output file are
+// closed after each.
+func TestTrackClosesFiles(t *testing.T) {
+	home := useTempHome(t)
+	db := filepath.Join(home, "thyme.db")
+	out := filepath.Join(home, "thyme.json")
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	const runs = 50
+	var snaps []*thyme.Snapshot
+	for i := 0; i < runs+1; i++ {
+		snaps = append(snaps, testSnapshot(start.Add(time.Duration(i)*time.Minute), int64(i%2+1), "main.go - Code", "~ - Terminal"))
+	}
+	tracker := &fakeTracker{snapshots: snaps}
+	// -o takes a snapshot too, which it doesn't record.
+	run := func() {
+		t.Helper()
+		if err := (&TrackCmd{DB: db, tracker: tracker}).Execute(nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := (&TrackCmd{DB: db, Out: out, tracker: &fakeTracker{snapshots: snaps[:1]}}).Execute(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first run opens what stays open for good, e.g. the logs.
+	run()
+	before := openFiles(t)
+	for i := 0; i < runs; i++ {
+		run()
+	}
+	if after := openFiles(t); after > before {
+		t.Errorf("%d files open after %d more runs, %d before", after, runs, before)
+	}
+	checkSnapshots(t, loadDB(t, db), snaps)
+}
+
+func TestTrackMergesIdenticalSnapshots(t *testing.T) {
+	home := useTempHome(t)
+	db := filepath.Join(home, "thyme.db")
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	snaps := []*thyme.Snapshot{
+		testSnapshot(start, 1, "main.go - Code"),
+		testSnapshot(start.Add(time.Minute), 1, "main.go - Code"),
+		testSnapshot(start.Add(2*time.Minute), 1, "main.go - Code"),
+	}
+	tracker := &fakeTracker{snapshots: snaps}
+	for range snaps {
+		c := &TrackCmd{DB: db, tracker: tracker}
+		if err := c.Execute(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := loadDB(t, db)
+	checkSnapshots(t, got, snaps[:1])
+	if end := start.Add(2 * time.Minute); !got[0].EndTime.Equal(end) {
+		t.Errorf("merged snapshot ends at %s, want %s", got[0].EndTime, end)
+	}
+}
+
+func TestTrackLoop(t *testing.T) {
+	home := useTempHome(t)
+	db := filepath.Join(home, "thyme.db")
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	want := []*thyme.Snapshot{
+		testSnapshot(start, 1, "main.go - Code", "~ - Terminal"),
+		testSnapshot(start.Add(time.Minute), 2, "main.go - Code", "~ - Terminal"),
+		testSnapshot(start.Add(2*time.Minute), 2, "~ - Terminal"),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := &TrackCmd{DB: db, Interval: time.Millisecond, NoRedact: true, NoIgnore: true}
+	if err := c.loadIgnorer(); err != nil {
+		t.Fatal(err)
+	}
+	store, err := c.openStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.trackLoop(ctx, &fakeTracker{snapshots: want, done: cancel}, store); err != nil {
+		t.Fatal(err)
+	}
+	got := loadDB(t, db)
+	checkSnapshots(t, got, want)
+	for i, snap := range got {
+		if snap.Interval != c.Interval {
+			t.Errorf("snapshot %d: interval %s, want %s", i, snap.Interval, c.Interval)
+		}
+	}
+}
+
+func TestTrackLoopAfterPause(t *testing.T) {
+	home := useTempHome(t)
+	db := filepath.Join(home, "thyme.db")
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	// Close enough to be merged, were tracking not paused in between.
+	want := []*thyme.Snapshot{
+		testSnapshot(start, 1, "main.go - Code"),
+		testSnapshot(start.Add(time.Millisecond), 1, "main.go - Code"),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	paused := filepath.Join(home, "paused")
+	tracker := &fakeTracker{snapshots: want, done: cancel, taken: func(n int) {
+		if n == 1 {
+			if err := os.WriteFile(paused, nil, 0644); err != nil {
+				t.Error(err)
+			}
+			time.AfterFunc(20*time.Millisecond, func() { os.Remove(paused) })
+		}
+	}}
+	c := &TrackCmd{DB: db, Interval: time.Millisecond, NoRedact: true, NoIgnore: true}
+	if err := c.loadIgnorer(); err != nil {
+		t.Fatal(err)
+	}
+	store, err := c.openStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.trackLoop(ctx, tracker, store); err != nil {
+		t.Fatal(err)
+	}
+	checkSnapshots(t, loadDB(t, db), want)
+}
+
+func TestTrackActiveOnly(t *testing.T) {
+	home := useTempHome(t)
+	db := filepath.Join(home, "thyme.db")
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	c := &TrackCmd{DB: db, ActiveOnly: true, tracker: &fakeTracker{snapshots: []*thyme.Snapshot{
+		testSnapshot(start, 2, "main.go - Code", "~ - Terminal"),
+	}}}
+	if err := c.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := testSnapshot(start, 2, "~ - Terminal")
+	want.Windows[0].ID = 2
+	checkSnapshots(t, loadDB(t, db), []*thyme.Snapshot{want})
+}
+
+// TestTrackRedacts checks that the titles matching redact.json don't
+// end up anywhere in the files of the store, but for the application
+// part of the window names.
+func TestTrackRedacts(t *testing.T) {
+	const secret = "Statement 12345678"
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	for _, store := range []string{"thyme.db", "thyme.jsonl"} {
+		t.Run(store, func(t *testing.T) {
+			home := useTempHome(t)
+			if err := os.WriteFile(filepath.Join(home, "redact.json"), []byte(`["\\b\\d{8,}\\b"]`), 0644); err != nil {
+				t.Fatal(err)
+			}
+			snap := testSnapshot(start, 1, secret+" - Bank - Google Chrome", "~ - Terminal")
+			snap.Windows[0].URL = "https://bank.example.com/statements/12345678"
+			path := filepath.Join(home, store)
+			c := &TrackCmd{Store: path, tracker: &fakeTracker{snapshots: []*thyme.Snapshot{snap}}}
+			if err := c.Execute(nil); err != nil {
+				t.Fatal(err)
+			}
+			want := testSnapshot(start, 1, thyme.Redacted+" - Google Chrome", "~ - Terminal")
+			want.Windows[0].URL = "https://bank.example.com"
+			checkSnapshots(t, loadDB(t, path), []*thyme.Snapshot{want})
+
+			// Nor anywhere in the files of the store, e.g. the journal of the
+			// database.
+			files, err := os.ReadDir(home)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range files {
+				b, err := os.ReadFile(filepath.Join(home, f.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if bytes.Contains(b, []byte("12345678")) {
+					t.Errorf("%s holds the redacted title", f.Name())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mehdidc/thyme"
+)
+
+// ValidateCmd is the subcommand that checks that a file written by
+// `thyme track -o` can be read, and optionally repairs it.
+type ValidateCmd struct {
+	In     string `long:"in" short:"i" required:"true" description:"file to check"`
+	Repair string `long:"repair" description:"write the snapshots that could be read to this file, leaving out the malformed ones"`
+}
+
+var validateCmd ValidateCmd
+
+func (c *ValidateCmd) Execute(args []string) error {
+	f, err := os.Open(c.In)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	stream, problems, err := thyme.ValidateStream(f)
+	if err != nil {
+		return fmt.Errorf("could not parse stream file %s: %w", c.In, err)
+	}
+	for _, p := range problems {
+		fmt.Printf("%s: %s\n", c.In, p)
+	}
+	fmt.Printf("%s: %d valid snapshots, %d problems\n", c.In, len(stream.Snapshots), len(problems))
+	if c.Repair != "" {
+		if err := writeStream(c.Repair, stream); err != nil {
+			return fmt.Errorf("repair: %w", err)
+		}
+		return nil
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s is malformed; run with --repair <file> to write its valid snapshots to another file", c.In)
+	}
+	return nil
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+// WatchCmd is the subcommand that shows the active window and the
+// time spent in each application today, live, in the terminal.
+type WatchCmd struct {
+	Interval      time.Duration `long:"interval" short:"n" default:"1s" description:"how often to take a snapshot and redraw"`
+	Limit         int           `long:"limit" short:"l" default:"10" description:"how many applications to list (0 for all)"`
+	IdleThreshold time.Duration `long:"idle-threshold" default:"5m" description:"stop counting time once the user has been idle this long (0 to always count)"`
+	Record        bool          `long:"record" description:"also record the snapshots in the database, as thyme track does"`
+	DB            string        `long:"db" env:"THYME_DB" description:"database to read the time already recorded today from, and with --record to record snapshots in (default: the one thyme track records in)"`
+	Store         string        `long:"store" env:"THYME_STORE" description:"store to use instead of --db: a sqlite database file, a .jsonl file or a postgres:// connection string"`
+	HashTitles    bool          `long:"hash-titles" description:"with --record, record a salted hash of each window title instead of the title itself, as with thyme track --hash-titles"`
+	Timeout       time.Duration `long:"timeout" default:"5s" description:"give up on a snapshot that takes longer than this (0 for no limit)"`
+}
+
+var watchCmd WatchCmd
+
+// watchState is what thyme watch has seen so far.
+type watchState struct {
+	// day is the day the totals are for, as midnight local time.
+	day time.Time
+
+	// totals is the active time spent in each application during day.
+	totals map[string]time.Duration
+
+	// app is the active application, and since when it has been.
+	app   string
+	since time.Time
+
+	// last is the previous snapshot, if any.
+	last *thyme.Snapshot
+}
+
+func (c *WatchCmd) Execute(args []string) error {
+	if c.Interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	t, err := getTracker(nil)
+	if err != nil {
+		return err
+	}
+	track, err := c.newTrack()
+	if err != nil {
+		return err
+	}
+	state := &watchState{totals: make(map[string]time.Duration), day: startOfDay(time.Now())}
+	var store thyme.Store
+	if c.Record {
+		if store, _, _, err = openStore(c.Store, c.DB); err != nil {
+			return err
+		}
+		defer store.Close()
+		stream, err := store.Load()
+		if err != nil {
+			return fmt.Errorf("load: %w", err)
+		}
+		c.seed(state, stream)
+	} else if err := c.seedRecorded(state); err != nil {
+		return err
+	}
+
+	ctx, stop := interruptContext()
+	defer stop()
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	var paused bool
+	for {
+		snap, err := track.snap(ctx, t)
+		if err != nil {
+			slog.Error("could not take snapshot", "error", err)
+		} else {
+			if store != nil {
+				paused = pauseState(paused)
+			}
+			if store != nil && !paused {
+				if err := track.storeSnapshot(store, snap); err != nil {
+					slog.Error("could not store snapshot", "error", err)
+				}
+			}
+			c.observe(state, snap)
+			c.draw(state, snap)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			fmt.Println()
+			return nil
+		}
+	}
+}
+
+// newTrack returns the TrackCmd that takes the snapshots of c. With
+// --record, they go through the same filters as those thyme track
+// records.
+func (c *WatchCmd) newTrack() (*TrackCmd, error) {
+	track := &TrackCmd{Interval: c.Interval, Timeout: c.Timeout, HashTitles: c.HashTitles}
+	if c.Record {
+		if err := track.loadPrivacy(); err != nil {
+			return nil, err
+		}
+	}
+	return track, nil
+}
+
+// seed starts the totals of state from the time already recorded
+// today in stream.
+func (c *WatchCmd) seed(state *watchState, stream *thyme.Stream) {
+	for _, total := range thyme.Summarize(streamFilter{idleThreshold: c.IdleThreshold}.apply(stream.Between(state.day, time.Time{}))).Apps {
+		state.totals[total.Label] = time.Duration(total.ActiveSeconds * float64(time.Second))
+	}
+}
+
+// seedRecorded seeds state, without --record, from the snapshots thyme
+// track recorded today in the --store or --db store, if any. A missing
+// database, e.g. because thyme track never ran, leaves the totals at
+// zero rather than being created.
+func (c *WatchCmd) seedRecorded(state *watchState) error {
+	db, err := dbPath(c.DB)
+	if err != nil {
+		return err
+	}
+	if c.Store == "" {
+		if _, err := os.Stat(db); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	stream, err := loadStream("", c.Store, db)
+	if err != nil {
+		return fmt.Errorf("load: %w", err)
+	}
+	c.seed(state, stream)
+	return nil
+}
+
+// observe adds the time since the previous snapshot to the
+// application that was active in it, and notes which one is active
+// in snap. Time during which the user was idle or the screen locked
+// doesn't count, nor do gaps longer than two intervals, e.g. while the
+// computer was asleep.
+func (c *WatchCmd) observe(state *watchState, snap *thyme.Snapshot) {
+	if day := startOfDay(snap.Time); !day.Equal(state.day) {
+		state.day, state.totals = day, make(map[string]time.Duration)
+	}
+	if last := state.last; last != nil && state.app != "" && !last.Locked && (c.IdleThreshold <= 0 || last.Idle < c.IdleThreshold) {
+		if d := snap.Time.Sub(last.Time); d > 0 && d <= 2*c.Interval {
+			state.totals[state.app] += d
+		}
+	}
+	app := ""
+	if w := snap.ActiveWindow(); w != nil {
+		app = thyme.AppID(w)
+	}
+	if app != state.app {
+		state.app, state.since = app, snap.Time
+	}
+	state.last = snap
+}
+
+// draw clears the terminal and prints the active window of snap, how
+// long its application has been active, and the totals of the day.
+func (c *WatchCmd) draw(state *watchState, snap *thyme.Snapshot) {
+	var b bytes.Buffer
+	// Move the cursor to the top-left corner and clear the screen.
+	b.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&b, "thyme watch, %s (Ctrl-C to quit)\n\n", snap.Time.Format("Mon Jan 2 15:04:05"))
+	if w := snap.ActiveWindow(); w != nil {
+		fmt.Fprintf(&b, "Active:  %s\n", truncate(w.Name, topLabelWidth+topBarWidth))
+		fmt.Fprintf(&b, "App:     %s, for %s\n", state.app, formatSeconds(snap.Time.Sub(state.since).Seconds()))
+	} else {
+		fmt.Fprintf(&b, "Active:  (no active window)\n")
+	}
+	switch {
+	case snap.Locked:
+		fmt.Fprintf(&b, "Screen locked\n")
+	case c.IdleThreshold > 0 && snap.Idle >= c.IdleThreshold:
+		fmt.Fprintf(&b, "Idle for %s, not counting\n", formatSeconds(snap.Idle.Seconds()))
+	}
+
+	type appTotal struct {
+		app string
+		d   time.Duration
+	}
+	var totals []appTotal
+	var sum, longest time.Duration
+	for app, d := range state.totals {
+		totals = append(totals, appTotal{app, d})
+		sum += d
+		longest = max(longest, d)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].d != totals[j].d {
+			return totals[i].d > totals[j].d
+		}
+		return totals[i].app < totals[j].app
+	})
+	if c.Limit > 0 && len(totals) > c.Limit {
+		totals = totals[:c.Limit]
+	}
+	fmt.Fprintf(&b, "\nToday: %s active\n\n", formatSeconds(sum.Seconds()))
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, t := range totals {
+		var bar string
+		if longest > 0 {
+			bar = strings.Repeat("█", int(float64(topBarWidth)*float64(t.d)/float64(longest)+0.5))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", truncate(t.app, topLabelWidth), formatSeconds(t.d.Seconds()), bar)
+	}
+	w.Flush()
+	os.Stdout.Write(b.Bytes())
+}
+
+// startOfDay returns midnight, local time, on the day of t.
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Local().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mehdidc/thyme"
+)
+
+func TestWatchSeedsTodayWithoutRecord(t *testing.T) {
+	home := useTempHome(t)
+	db := filepath.Join(home, "thyme.db")
+	day := startOfDay(time.Now())
+	// Two minutes in the editor today, and some time yesterday, which
+	// doesn't count.
+	tracker := &fakeTracker{snapshots: []*thyme.Snapshot{
+		testSnapshot(day.Add(-time.Hour), 1, "~ - Terminal"),
+		testSnapshot(day.Add(time.Minute), 1, "main.go - Code"),
+		testSnapshot(day.Add(2*time.Minute), 1, "thyme.go - Code"),
+		testSnapshot(day.Add(3*time.Minute), 1, "~ - Terminal"),
+	}}
+	for range tracker.snapshots {
+		if err := (&TrackCmd{DB: db, NoDedup: true, tracker: tracker}).Execute(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := &WatchCmd{DB: db, IdleThreshold: 5 * time.Minute}
+	state := &watchState{totals: make(map[string]time.Duration), day: day}
+	if err := c.seedRecorded(state); err != nil {
+		t.Fatal(err)
+	}
+	if got := state.totals["Code"]; got != 2*time.Minute {
+		t.Errorf("Code active for %s today, want 2m", got)
+	}
+	if got := state.totals["Terminal"]; got > time.Minute {
+		t.Errorf("Terminal active for %s today, want at most the minute of its last snapshot", got)
+	}
+}
+
+func TestWatchSeedsNothingWithoutDatabase(t *testing.T) {
+	home := useTempHome(t)
+	db := filepath.Join(home, "thyme.db")
+	state := &watchState{totals: make(map[string]time.Duration), day: startOfDay(time.Now())}
+	if err := (&WatchCmd{DB: db}).seedRecorded(state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.totals) != 0 {
+		t.Errorf("got totals %v, want none", state.totals)
+	}
+	if _, err := os.Stat(db); !os.IsNotExist(err) {
+		t.Errorf("watch created the database %s it read: %v", db, err)
+	}
+}
+
+func TestWatchRecordHashesTitles(t *testing.T) {
+	home := useTempHome(t)
+	const title = "salary review.xlsx - Excel"
+	track, err := (&WatchCmd{Record: true, HashTitles: true}).newTrack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := &fakeTracker{snapshots: []*thyme.Snapshot{testSnapshot(time.Now(), 1, title)}}
+	snap, err := track.snap(context.Background(), tracker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := snap.Windows[0].Name; !strings.HasPrefix(name, "[hashed ") {
+		t.Errorf("watch --record --hash-titles kept the title %q", name)
+	}
+	if _, err := os.Stat(filepath.Join(home, "title-salt")); err != nil {
+		t.Errorf("no salt was generated: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package thyme
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// Colors map labels, such as application names or categories, to the
+// CSS colors charts draw them in. Labels that aren't listed get a
+// color of the palette picked from the label alone, so that a label
+// has the same color in every chart.
+type Colors map[string]string
+
+// colorPattern matches the colors Colors accept: hexadecimal colors
+// such as "#fa0" or "#ffaa00", and named colors such as "teal".
+var colorPattern = regexp.MustCompile(`^(#[0-9a-fA-F]{3,4}|#[0-9a-fA-F]{6}|#[0-9a-fA-F]{8}|[a-zA-Z]+)$`)
+
+// LoadColors reads the colors in the JSON file at path, an object
+// mapping labels to colors, e.g. {"Google Chrome": "#db4437"}. If the
+// file doesn't exist, no label has its color overridden.
+func LoadColors(path string) (Colors, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var colors Colors
+	if err := json.Unmarshal(b, &colors); err != nil {
+		return nil, fmt.Errorf("could not parse colors file %s: %s", path, err)
+	}
+	for label, color := range colors {
+		if !colorPattern.MatchString(color) {
+			return nil, fmt.Errorf("could not parse colors file %s: invalid color %q for %q", path, color, label)
+		}
+	}
+	return colors, nil
+}
+
+// Of returns the color of label: the one c lists for it, or the one
+// report.js gives it otherwise.
+func (c Colors) Of(label string) string {
+	if color, ok := c[label]; ok {
+		return color
+	}
+	return labelColor(label)
+}
@@ -0,0 +1,101 @@
+package thyme
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"regexp"
+	"time"
+)
+
+// commandWaitDelay is how long to wait for the output of a program
+// that was killed because its context was done. Without a limit,
+// programs that leave children behind (e.g. `bash -c "a | b"`) would
+// keep us waiting until the children exit.
+const commandWaitDelay = 100 * time.Millisecond
+
+// commandOutput runs the named program with args and returns its
+// standard output. The program is killed if ctx is done before it
+// exits.
+func commandOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return commandOutputEnv(ctx, nil, name, args...)
+}
+
+// commandOutputEnv is like commandOutput, but adds env, a list of
+// "key=value" pairs, to the environment of the program.
+func commandOutputEnv(ctx context.Context, env []string, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = commandWaitDelay
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	start := time.Now()
+	out, err := cmd.Output()
+	logCommand(name, args, out, err, time.Since(start))
+	if err != nil {
+		return nil, contextError(ctx, name, err)
+	}
+	return out, nil
+}
+
+// Retry says how to run a program again after it failed in a way that
+// may be transient.
+type Retry struct {
+	// Attempts is the number of times to run the program before giving
+	// up. Zero or one mean it is only run once.
+	Attempts int
+
+	// Backoff is how long to wait before running the program a second
+	// time. It doubles before each further attempt.
+	Backoff time.Duration
+}
+
+// DefaultRetry is how trackers retry the programs that query the X
+// server, such as wmctrl and xdotool, unless told otherwise.
+var DefaultRetry = Retry{Attempts: 3, Backoff: 100 * time.Millisecond}
+
+// transientRx matches what the programs that query the X server print
+// when they fail because it is busy or can't be reached for a moment,
+// as when it restarts or runs out of client slots, and which running
+// them again may get past.
+var transientRx = regexp.MustCompile(`(?i)(can(no|')t|unable to) open display|maximum number of clients|connection refused|resource temporarily unavailable|server (is )?busy`)
+
+// commandOutputRetry is like commandOutputEnv, but runs the program
+// again as retry says if it fails in a way that may be transient: with
+// a non-zero exit status, after printing an error transientRx matches.
+// Other errors, e.g. because a window has closed, the program isn't
+// installed, or ctx is done, end the attempts at once.
+func commandOutputRetry(ctx context.Context, retry Retry, env []string, name string, args ...string) ([]byte, error) {
+	backoff := retry.Backoff
+	for attempt := 1; ; attempt++ {
+		out, err := commandOutputEnv(ctx, env, name, args...)
+		var exitErr *exec.ExitError
+		if err == nil || attempt >= retry.Attempts || !errors.As(err, &exitErr) || !transientRx.Match(exitErr.Stderr) || ctx.Err() != nil {
+			return out, err
+		}
+		slog.Debug("retrying command", "name", name, "attempt", attempt+1, "backoff", backoff, "error", err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, contextError(ctx, name, ctx.Err())
+		}
+		backoff *= 2
+	}
+}
+
+// contextError returns an error that says the program called name
+// timed out or was interrupted if ctx is done, and err otherwise. It
+// is meant for the errors of programs run with exec.CommandContext,
+// which otherwise just report that they were killed.
+func contextError(ctx context.Context, name string, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return fmt.Errorf("%s timed out: %w", name, ctx.Err())
+	case ctx.Err() != nil:
+		return fmt.Errorf("%s was interrupted: %w", name, ctx.Err())
+	}
+	return err
+}
@@ -0,0 +1,127 @@
+package thyme
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCommand writes a shell script to a temporary directory that,
+// each time it is run, appends a line to the file "runs" next to it
+// and then runs script, and returns the path of the script and of the
+// file. The tests pass the latter to script as $RUNS.
+func fakeCommand(t *testing.T, script string) (path, runs string) {
+	t.Helper()
+	dir := t.TempDir()
+	path, runs = filepath.Join(dir, "fake"), filepath.Join(dir, "runs")
+	script = "#!/bin/sh\necho >>" + runs + "\n" + script + "\n"
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path, runs
+}
+
+// countRuns returns how many times the fake command that writes to
+// runs was run.
+func countRuns(t *testing.T, runs string) int {
+	t.Helper()
+	b, err := os.ReadFile(runs)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	return strings.Count(string(b), "\n")
+}
+
+func TestCommandOutputRetry(t *testing.T) {
+	retry := Retry{Attempts: 3, Backoff: time.Millisecond}
+	for _, tt := range []struct {
+		name   string
+		script string
+		out    string
+		fails  bool
+		runs   int
+	}{
+		{
+			name:   "succeeds",
+			script: "echo 42",
+			out:    "42\n",
+			runs:   1,
+		},
+		{
+			name:   "fails once then succeeds",
+			script: `[ "$(wc -l <"$RUNS")" -gt 1 ] && echo 42 && exit 0; echo "Can't open display :0" >&2; exit 1`,
+			out:    "42\n",
+			runs:   2,
+		},
+		{
+			name:   "server busy",
+			script: `echo "Error: unable to open display :0" >&2; exit 1`,
+			fails:  true,
+			runs:   3,
+		},
+		{
+			name:   "window closed",
+			script: `echo "X Error of failed request:  BadWindow (invalid Window parameter)" >&2; exit 1`,
+			fails:  true,
+			runs:   1,
+		},
+		{
+			name:   "no match",
+			script: "exit 1",
+			fails:  true,
+			runs:   1,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path, runs := fakeCommand(t, tt.script)
+			out, err := commandOutputRetry(context.Background(), retry, []string{"RUNS=" + runs}, path)
+			if tt.fails && err == nil {
+				t.Errorf("got output %q, want an error", out)
+			} else if !tt.fails && (err != nil || string(out) != tt.out) {
+				t.Errorf("got %q, %v, want %q", out, err, tt.out)
+			}
+			if n := countRuns(t, runs); n != tt.runs {
+				t.Errorf("ran %d times, want %d", n, tt.runs)
+			}
+		})
+	}
+}
+
+func TestCommandOutputRetryCanceled(t *testing.T) {
+	path, runs := fakeCommand(t, `echo "Can't open display :0" >&2; exit 1`)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := commandOutputRetry(ctx, Retry{Attempts: 100, Backoff: time.Second}, nil, path); err == nil {
+		t.Fatal("got no error, want the command to be interrupted")
+	}
+	if n := countRuns(t, runs); n != 1 {
+		t.Errorf("ran %d times, want 1", n)
+	}
+}
+
+func TestCommandOutputHidden(t *testing.T) {
+	var logs strings.Builder
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer hideOutput.Store(false)
+	HideCommandOutput()
+
+	fake, _ := fakeCommand(t, "echo 'Inbox - Gmail - Google Chrome'")
+	out, err := commandOutput(context.Background(), fake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "Inbox") {
+		t.Errorf("got output %q, want the title the command printed", out)
+	}
+	if strings.Contains(logs.String(), "Inbox") {
+		t.Errorf("the title the command printed was logged:\n%s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "ran command") {
+		t.Errorf("the command wasn't logged:\n%s", logs.String())
+	}
+}
@@ -0,0 +1,112 @@
+package thyme
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Change compares the active time of a group of windows during two
+// periods.
+type Change struct {
+	Label string
+
+	// Before and After are the active time of the group during the
+	// earlier and the later period.
+	Before time.Duration
+	After  time.Duration
+}
+
+// Delta returns how much more active time the group had during the
+// later period, which is negative if it had less.
+func (c *Change) Delta() time.Duration {
+	return c.After - c.Before
+}
+
+// Percent returns Delta as a percentage of Before, or false if the
+// group wasn't active during the earlier period, in which case there
+// is no percentage to speak of.
+func (c *Change) Percent() (float64, bool) {
+	if c.Before <= 0 {
+		return 0, false
+	}
+	return 100 * float64(c.Delta()) / float64(c.Before), true
+}
+
+// New reports whether the group was only active during the later
+// period.
+func (c *Change) New() bool {
+	return c.Before <= 0 && c.After > 0
+}
+
+// Dropped reports whether the group was only active during the
+// earlier period.
+func (c *Change) Dropped() bool {
+	return c.Before > 0 && c.After <= 0
+}
+
+// Compare returns the changes in active time of the groups of g
+// between before and after, two streams usually covering consecutive
+// periods, with cats determining the category of windows. Groups that
+// were active in only one of the periods are included. The changes
+// are ordered by decreasing active time during the later period, then
+// during the earlier one.
+func Compare(before, after *Stream, g *Grouping, cats *Categories) []*Change {
+	changes := make(map[string]*Change)
+	change := func(label string) *Change {
+		if c, exists := changes[label]; exists {
+			return c
+		}
+		c := &Change{Label: label}
+		changes[label] = c
+		return c
+	}
+	for _, t := range g.Totals(before, cats) {
+		if t.ActiveSamples > 0 {
+			change(t.Label).Before = secondsDuration(t.ActiveSeconds)
+		}
+	}
+	for _, t := range g.Totals(after, cats) {
+		if t.ActiveSamples > 0 {
+			change(t.Label).After = secondsDuration(t.ActiveSeconds)
+		}
+	}
+
+	list := make([]*Change, 0, len(changes))
+	for _, c := range changes {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if list[a].After != list[b].After {
+			return list[a].After > list[b].After
+		}
+		if list[a].Before != list[b].Before {
+			return list[a].Before > list[b].Before
+		}
+		return list[a].Label < list[b].Label
+	})
+	return list
+}
+
+func secondsDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Periods are the lengths of period accepted by PeriodStart.
+var Periods = []string{"day", "week", "month"}
+
+// PeriodStart returns the start of the period of the given length
+// ("day", "week" or "month") that t is in, in t's location. Weeks
+// start on Monday.
+func PeriodStart(t time.Time, period string) (time.Time, error) {
+	y, m, d := t.Date()
+	switch period {
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location()), nil
+	case "week":
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, t.Location()), nil
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location()), nil
+	}
+	return time.Time{}, fmt.Errorf("unknown period %q (expected day, week or month)", period)
+}
@@ -0,0 +1,40 @@
+package thyme
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// csvHeader is the header row written by WriteCSV.
+var csvHeader = []string{"time", "window_id", "app", "title", "active", "visible"}
+
+// WriteCSV writes stream to w as CSV, with a header row followed by
+// one row per window per snapshot.
+func WriteCSV(w io.Writer, stream *Stream) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, snap := range stream.Snapshots {
+		visible := make(map[int64]bool, len(snap.Visible))
+		for _, id := range snap.Visible {
+			visible[id] = true
+		}
+		for _, win := range snap.Windows {
+			if err := cw.Write([]string{
+				snap.Time.Format(time.RFC3339),
+				strconv.FormatInt(win.ID, 10),
+				appID(win),
+				win.Info().Title,
+				strconv.FormatBool(win.ID == snap.Active),
+				strconv.FormatBool(visible[win.ID]),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -2,10 +2,12 @@ package thyme
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"hash/fnv"
-	"log"
+	"log/slog"
 	"os/exec"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -15,10 +17,13 @@ func init() {
 	RegisterTracker("darwin", NewDarwinTracker)
 }
 
-// DarwinTracker tracks application usage using the "System Events" API in AppleScript. Due to the liminations of this
-// API, the DarwinTracker will not be able to detect individual windows of applications that are not scriptable (in the
-// AppleScript sense). For these applications, a single window is emitted with the name set to the application process
-// name and the ID set to the process ID.
+// DarwinTracker tracks application usage using the CoreGraphics window list, in builds with cgo, and otherwise using the
+// "System Events" API in AppleScript. CoreGraphics is much faster, but only tells the titles of windows once the user has
+// granted the Screen Recording permission; until then, the tracker falls back to AppleScript.
+//
+// Due to the liminations of the AppleScript API, the DarwinTracker will not be able to detect individual windows of
+// applications that are not scriptable (in the AppleScript sense) without CoreGraphics. For these applications, a single
+// window is emitted with the name set to the application process name and the ID set to the process ID.
 type DarwinTracker struct{}
 
 var _ Tracker = (*DarwinTracker)(nil)
@@ -30,8 +35,8 @@ func NewDarwinTracker() Tracker {
 // allWindowsScript fetches the windows of all scriptable applications.  It
 // iterates through each application process known to System Events and attempts
 // to script the application with the same name as the application process. If
-// such an application exists and is scriptable, it prints the name of every
-// window in the application. Otherwise, it just prints the name of every
+// such an application exists and is scriptable, it prints the name (and, where
+// available, the bounds) of every window in the application. Otherwise, it just prints the name of every
 // visible window in the application. If no visible windows exist, it will just
 // print the application name.  (System Events processes only have windows in
 // the current desktop/workspace.)
@@ -44,12 +49,16 @@ end tell
 repeat with proc in listOfProcesses
   set procName to (name of proc)
   set procID to (id of proc)
-  log "PROCESS " & procID & ":" & procName
+  log "PROCESS " & procID & "," & (unix id of proc) & ":" & procName
   -- Attempt to list windows if the process is scriptable
   try
     tell application procName
       repeat with i from 1 to (count windows)
         log "WINDOW " & (id of window i) & ":" & (name of window i) as string
+        try
+          set b to (bounds of window i)
+          log "BOUNDS " & (item 1 of b) & "," & (item 2 of b) & "," & (item 3 of b) & "," & (item 4 of b)
+        end try
       end repeat
     end tell
   end try
@@ -82,7 +91,7 @@ end tell
 repeat with proc in listOfProcesses
 	set procName to (name of proc)
 	set procID to (id of proc)
-	log "PROCESS " & procID & ":" & procName
+	log "PROCESS " & procID & "," & (unix id of proc) & ":" & procName
 	set app_windows to (every window of proc)
 	repeat with each_window in app_windows
 		log "WINDOW -1:" & (name of each_window) as string
@@ -93,24 +102,52 @@ end repeat
 
 func (t *DarwinTracker) Deps() string {
 	return `
-You will need to enable privileges for "Terminal" in System Preferences > Security & Privacy > Privacy > Accessibility.
+For Thyme to read window titles quickly, enable privileges for "Terminal" in System Preferences > Security & Privacy >
+Privacy > Screen Recording. Otherwise, Thyme falls back to AppleScript, which is slower and needs privileges for
+"Terminal" in System Preferences > Security & Privacy > Privacy > Accessibility instead.
 See https://support.apple.com/en-us/HT202802 for details.
 
 Note: this command prints out this message regardless of whether this has been done or not.
 `
 }
 
-func (t *DarwinTracker) Snap() (*Snapshot, error) {
+func (t *DarwinTracker) Programs() []Program {
+	return []Program{{Name: "osascript", Purpose: "list windows with AppleScript"}}
+}
+
+func (t *DarwinTracker) Snap(ctx context.Context) (*Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	snap, err := cgSnapshot()
+	if err != nil {
+		slog.Debug("falling back to AppleScript", "error", err)
+		if snap, err = snapAppleScript(ctx); err != nil {
+			return nil, err
+		}
+	}
+	snap.Idle = darwinIdle(ctx)
+	snap.Monitors = darwinMonitors(ctx)
+	snap.Locked = darwinLocked(ctx)
+	snap.AssignMonitors()
+	snap.DetectFullScreen()
+	snap.MarkPartial()
+	logSnapshot("darwin", snap)
+	return snap, nil
+}
+
+// snapAppleScript takes a snapshot of the windows of scriptable applications with AppleScript.
+func snapAppleScript(ctx context.Context) (*Snapshot, error) {
 	var allWindows []*Window
 	var allProcWins map[process][]*Window
 	{
-		procWins, err := runAS(allWindowsScript)
+		procWins, err := runAS(ctx, allWindowsScript)
 		if err != nil {
 			return nil, err
 		}
 		for proc, wins := range procWins {
 			if len(wins) == 0 {
-				allWindows = append(allWindows, &Window{ID: proc.id, Name: proc.name})
+				allWindows = append(allWindows, &Window{ID: proc.id, Name: proc.name, PID: proc.pid, Process: proc.name})
 			} else {
 				allWindows = append(allWindows, wins...)
 			}
@@ -120,7 +157,7 @@ func (t *DarwinTracker) Snap() (*Snapshot, error) {
 
 	var active int64
 	{
-		procWins, err := runAS(activeWindowsScript)
+		procWins, err := runAS(ctx, activeWindowsScript)
 		if err != nil {
 			return nil, err
 		}
@@ -140,7 +177,7 @@ func (t *DarwinTracker) Snap() (*Snapshot, error) {
 
 	var visible []int64
 	{
-		procWins, err := runAS(visibleWindowsScript)
+		procWins, err := runAS(ctx, visibleWindowsScript)
 		if err != nil {
 			return nil, err
 		}
@@ -159,35 +196,130 @@ func (t *DarwinTracker) Snap() (*Snapshot, error) {
 						}
 					}
 					if !found {
-						log.Printf("warning: window ID not found for visible window %q", visWin.Name)
+						slog.Warn("window ID not found for visible window", "name", visWin.Name)
 					}
 				}
 			}
 		}
 	}
 
-	return &Snapshot{
-		Time:    time.Now(),
-		Windows: allWindows,
-		Active:  active,
-		Visible: visible,
-	}, nil
+	return &Snapshot{Time: time.Now(), Windows: allWindows, Active: active, Visible: visible}, nil
+}
+
+// screensScript lists the frame and backing scale factor of every NSScreen as "name,x,y,width,height,scale" lines.
+// NSScreen frames have their origin at the bottom-left of the main screen, so y is converted to the top-left origin
+// used by window bounds. Frames and window bounds are both in points, which Retina screens show with more pixels.
+const screensScript = `
+ObjC.import("AppKit");
+var screens = $.NSScreen.screens;
+var mainHeight = screens.objectAtIndex(0).frame.size.height;
+var lines = [];
+for (var i = 0; i < screens.count; i++) {
+  var s = screens.objectAtIndex(i);
+  var f = s.frame;
+  var name = s.localizedName ? ObjC.unwrap(s.localizedName) : "Display " + (i + 1);
+  lines.push([name.replace(/,/g, " "), f.origin.x, mainHeight - f.origin.y - f.size.height, f.size.width, f.size.height, s.backingScaleFactor].join(","));
+}
+lines.join("\n");
+`
+
+// darwinMonitors returns the screens attached to the system, through CoreGraphics in builds with cgo, and otherwise
+// by running screensScript. It returns nil if they can't be determined, in which case windows are attributed to
+// DefaultMonitor.
+func darwinMonitors(ctx context.Context) []*Monitor {
+	if monitors, err := cgMonitors(); err == nil {
+		return monitors
+	}
+	cmd := exec.CommandContext(ctx, "osascript", "-l", "JavaScript")
+	cmd.WaitDelay = commandWaitDelay
+	cmd.Stdin = bytes.NewBuffer([]byte(screensScript))
+	start := time.Now()
+	out, err := cmd.Output()
+	logCommand("osascript", cmd.Args[1:], out, err, time.Since(start))
+	if err != nil {
+		return nil
+	}
+	var monitors []*Monitor
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 6 {
+			continue
+		}
+		var dims [4]int
+		for i := range dims {
+			n, err := strconv.ParseFloat(fields[i+1], 64)
+			if err != nil {
+				return nil
+			}
+			dims[i] = int(n)
+		}
+		// A scale that can't be read is left unknown rather than
+		// failing the whole list.
+		scale, _ := strconv.ParseFloat(fields[5], 64)
+		monitors = append(monitors, &Monitor{Name: fields[0], X: dims[0], Y: dims[1], Width: dims[2], Height: dims[3], Scale: scale})
+	}
+	return monitors
+}
+
+var hidIdleTimeRx = regexp.MustCompile(`"HIDIdleTime" = ([0-9]+)`)
+
+// darwinIdle returns how long the system has gone without user input, as reported by the HIDIdleTime property of
+// IOHIDSystem, read through IOKit in builds with cgo, and with ioreg otherwise. It returns zero if the property can't
+// be read.
+func darwinIdle(ctx context.Context) time.Duration {
+	if idle, err := cgIdle(); err == nil {
+		return idle
+	}
+	out, err := commandOutput(ctx, "ioreg", "-c", "IOHIDSystem", "-d", "4")
+	if err != nil {
+		return 0
+	}
+	matches := hidIdleTimeRx.FindStringSubmatch(string(out))
+	if len(matches) != 2 {
+		return 0
+	}
+	ns, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(ns)
 }
 
-// process is the {name, id} of a process
+var screenLockedRx = regexp.MustCompile(`"CGSSessionScreenIsLocked"\s*=\s*Yes`)
+
+// darwinLocked reports whether the screen is locked, as reported by the CGSSessionScreenIsLocked key of the current
+// session's dictionary, which CGSessionCopyCurrentDictionary returns in builds with cgo, and the registry's root
+// exposes otherwise. It returns false if neither can be read.
+func darwinLocked(ctx context.Context) bool {
+	if locked, err := cgLocked(); err == nil {
+		return locked
+	}
+	out, err := commandOutput(ctx, "ioreg", "-n", "Root", "-d", "1")
+	if err != nil {
+		return false
+	}
+	return screenLockedRx.Match(out)
+}
+
+// process is the {name, id, pid} of a process. pid, the Unix process
+// ID, is zero if it is unknown.
 type process struct {
 	name string
 	id   int64
+	pid  int
 }
 
 // runAS runs script as AppleScript and parses the output into a map of
 // processes to windows.
-func runAS(script string) (map[process][]*Window, error) {
-	cmd := exec.Command("osascript")
+func runAS(ctx context.Context, script string) (map[process][]*Window, error) {
+	cmd := exec.CommandContext(ctx, "osascript")
+	cmd.WaitDelay = commandWaitDelay
 	cmd.Stdin = bytes.NewBuffer([]byte(script))
+	start := time.Now()
 	b, err := cmd.CombinedOutput()
+	logCommand("osascript", nil, b, err, time.Since(start))
 	if err != nil {
-		return nil, fmt.Errorf("AppleScript error: %s, output was:\n%s", err, string(b))
+		return nil, fmt.Errorf("AppleScript error: %s, output was:\n%s", contextError(ctx, "osascript", err), string(b))
 	}
 	return parseASOutput(string(b))
 }
@@ -199,18 +331,28 @@ func parseASOutput(out string) (map[process][]*Window, error) {
 	for _, line := range strings.Split(out, "\n") {
 		if strings.HasPrefix(line, "PROCESS ") {
 			c := strings.Index(line, ":")
-			procID_f, err := strconv.ParseFloat(line[len("PROCESS "):c], 64)
+			ids := strings.SplitN(line[len("PROCESS "):c], ",", 2)
+			procID_f, err := strconv.ParseFloat(ids[0], 64)
 			procID := int64(procID_f)
 			if err != nil {
 				return nil, err
 			}
-			proc = process{line[c+1:], procID}
+			proc = process{line[c+1:], procID, 0}
+			if len(ids) == 2 {
+				if pid, err := strconv.ParseFloat(ids[1], 64); err == nil {
+					proc.pid = int(pid)
+				}
+			}
 			procWins[proc] = nil
 		} else if strings.HasPrefix(line, "WINDOW ") {
 			win, winID := parseWindowLine(line, proc.id)
 			procWins[proc] = append(procWins[proc],
-				&Window{ID: winID, Name: fmt.Sprintf("%s - %s", win, proc.name)},
+				&Window{ID: winID, Name: fmt.Sprintf("%s - %s", win, proc.name), PID: proc.pid, Process: proc.name},
 			)
+		} else if strings.HasPrefix(line, "BOUNDS ") {
+			if wins := procWins[proc]; len(wins) > 0 {
+				parseBoundsLine(line, wins[len(wins)-1])
+			}
 		}
 	}
 	return procWins, nil
@@ -235,6 +377,25 @@ func parseWindowLine(line string, procId int64) (string, int64) {
 	return win, winID
 }
 
+// parseBoundsLine sets the geometry of win from a line of the
+// AppleScript output listing the {left, top, right, bottom} bounds of
+// the window. Malformed lines leave the geometry unset.
+func parseBoundsLine(line string, win *Window) {
+	fields := strings.Split(strings.TrimPrefix(line, "BOUNDS "), ",")
+	if len(fields) != 4 {
+		return
+	}
+	var b [4]int
+	for i, f := range fields {
+		n, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return
+		}
+		b[i] = int(n)
+	}
+	win.X, win.Y, win.Width, win.Height = b[0], b[1], b[2]-b[0], b[3]-b[1]
+}
+
 // hash converts a string to an integer hash
 func hash(s string) int64 {
 	h := fnv.New32a()
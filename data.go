@@ -1,13 +1,68 @@
 package thyme
 
 import (
+	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"os/exec"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
+// Program is an external program that a Tracker runs.
+type Program struct {
+	// Name is the name of the executable, looked up in PATH.
+	Name string
+
+	// Optional programs only provide extra information, e.g. idle
+	// time; the tracker works without them.
+	Optional bool
+
+	// Purpose says what the tracker uses the program for.
+	Purpose string
+
+	// Fallback, if set, says what the tracker does without the
+	// program: it still works, but records less than it could.
+	Fallback string
+}
+
+// ProgramStatus describes which of programs are on PATH, one per line,
+// with what the tracker does without those that are missing.
+func ProgramStatus(programs []Program) string {
+	var b strings.Builder
+	for _, p := range programs {
+		path, err := exec.LookPath(p.Name)
+		switch {
+		case err == nil:
+			fmt.Fprintf(&b, "* %s: found at %s\n", p.Name, path)
+		case p.Fallback != "":
+			fmt.Fprintf(&b, "* %s: missing; %s\n", p.Name, p.Fallback)
+		case p.Optional:
+			fmt.Fprintf(&b, "* %s: missing (optional, used to %s)\n", p.Name, p.Purpose)
+		default:
+			fmt.Fprintf(&b, "* %s: missing, and needed to %s\n", p.Name, p.Purpose)
+		}
+	}
+	return b.String()
+}
+
+// ProgramTracker is implemented by Trackers that depend on external
+// programs, so that their presence can be checked ahead of time.
+type ProgramTracker interface {
+	Tracker
+
+	// Programs returns the programs the tracker runs.
+	Programs() []Program
+}
+
 // trackers is the list of Tracker constructors that are available on this system. Tracker implementations should call
 // the RegisterTracker function to make themselves available.
 var trackers = make(map[string]func() Tracker)
@@ -33,16 +88,33 @@ func NewTracker(name string) Tracker {
 // supports.
 type Tracker interface {
 	// Snap returns a Snapshot reflecting the currently in-use windows
-	// at the current time.
-	Snap() (*Snapshot, error)
+	// at the current time. It gives up and returns an error once ctx
+	// is done. A Tracker can take any number of snapshots, and Snap
+	// must be safe to call from several goroutines at once.
+	Snap(ctx context.Context) (*Snapshot, error)
 
 	// Deps returns a string listing the dependencies that still need
 	// to be installed with instructions for how to install them.
 	Deps() string
 }
 
+// StreamVersion is the version of the format of the JSON streams
+// written by this version of Thyme. It goes up whenever the format
+// changes in a way older versions can't read.
+const StreamVersion = 1
+
 // Stream represents all the sampling data gathered by Thyme.
 type Stream struct {
+	// Version is the version of the format the stream was written in
+	// (see StreamVersion). Streams written before the field existed
+	// have version 0.
+	Version int
+
+	// Interval is the time between snapshots thyme track was
+	// configured with when it wrote the stream, if it was (see
+	// SamplingInterval).
+	Interval time.Duration `json:",omitempty"`
+
 	// Snapshots is a list of window snapshots ordered by time.
 	Snapshots []*Snapshot
 }
@@ -56,6 +128,221 @@ func (s Stream) Print() string {
 	return string(b.Bytes())
 }
 
+// WithoutIdle returns a copy of the stream in which no window is
+// considered active in snapshots taken after the user had been idle
+// for at least threshold. A zero threshold returns the stream
+// unchanged.
+func (s *Stream) WithoutIdle(threshold time.Duration) *Stream {
+	if threshold <= 0 {
+		return s
+	}
+	filtered := *s
+	filtered.Snapshots = make([]*Snapshot, 0, len(s.Snapshots))
+	for _, snap := range s.Snapshots {
+		if snap.Idle >= threshold {
+			idle := *snap
+			idle.Active, idle.AlsoActive = 0, nil
+			snap = &idle
+		}
+		filtered.Snapshots = append(filtered.Snapshots, snap)
+	}
+	return &filtered
+}
+
+// WithoutLocked returns a copy of the stream in which no window is
+// considered active in snapshots taken while the screen was locked.
+func (s *Stream) WithoutLocked() *Stream {
+	filtered := *s
+	filtered.Snapshots = make([]*Snapshot, 0, len(s.Snapshots))
+	for _, snap := range s.Snapshots {
+		if snap.Locked {
+			locked := *snap
+			locked.Active, locked.AlsoActive = 0, nil
+			snap = &locked
+		}
+		filtered.Snapshots = append(filtered.Snapshots, snap)
+	}
+	return &filtered
+}
+
+// WithoutSplitActive returns a copy of the stream in which only the
+// focused window of each snapshot is active, as if AlsoActive had
+// never been recorded, so that its whole time goes to that window.
+func (s *Stream) WithoutSplitActive() *Stream {
+	filtered := *s
+	filtered.Snapshots = make([]*Snapshot, 0, len(s.Snapshots))
+	for _, snap := range s.Snapshots {
+		if len(snap.AlsoActive) > 0 {
+			single := *snap
+			single.AlsoActive = nil
+			snap = &single
+		}
+		filtered.Snapshots = append(filtered.Snapshots, snap)
+	}
+	return &filtered
+}
+
+// WithoutFlickers returns a copy of the stream in which the periods
+// shorter than minDuration during which a single application stayed
+// active, e.g. because the user alt-tabbed through it, are attributed
+// to the application active before them: its window is the active one
+// in their snapshots instead. Periods are measured as in Sessions, and
+// merged from the first to the last, so that a series of short periods
+// all goes to the application before the first. Short periods at the
+// start of the stream, or after a period without an active window, are
+// kept. A zero minDuration returns the stream unchanged.
+func (s *Stream) WithoutFlickers(minDuration time.Duration) *Stream {
+	if minDuration <= 0 {
+		return s
+	}
+	durations := sampleDurations(s)
+	filtered := *s
+	filtered.Snapshots = make([]*Snapshot, 0, len(s.Snapshots))
+	// previous is the window active at the end of the last period
+	// kept, or nil if no window was.
+	var previous *Window
+	for start := 0; start < len(s.Snapshots); {
+		active := s.Snapshots[start].ActiveWindow()
+		end, length := start, time.Duration(0)
+		for ; end < len(s.Snapshots); end++ {
+			w := s.Snapshots[end].ActiveWindow()
+			if (w == nil) != (active == nil) || (w != nil && appID(w) != appID(active)) {
+				break
+			}
+			length += durations[end]
+		}
+		period := s.Snapshots[start:end]
+		if active == nil || previous == nil || length >= minDuration || appID(previous) == appID(active) {
+			filtered.Snapshots = append(filtered.Snapshots, period...)
+			if len(period) > 0 {
+				previous = period[len(period)-1].ActiveWindow()
+			}
+		} else {
+			for _, snap := range period {
+				filtered.Snapshots = append(filtered.Snapshots, snap.withActive(previous))
+			}
+		}
+		start = end
+	}
+	return &filtered
+}
+
+// withActive returns a copy of the snapshot in which w is the only
+// active window, adding it to the windows if the snapshot doesn't have
+// it.
+func (s *Snapshot) withActive(w *Window) *Snapshot {
+	c := *s
+	c.Active, c.AlsoActive = w.ID, nil
+	for _, win := range s.Windows {
+		if win.ID == w.ID {
+			return &c
+		}
+	}
+	c.Windows = append(append(make([]*Window, 0, len(s.Windows)+1), s.Windows...), w)
+	return &c
+}
+
+// In returns a copy of the stream whose snapshot times are in loc, so
+// that grouping by hour or day happens in that time zone.
+func (s *Stream) In(loc *time.Location) *Stream {
+	converted := *s
+	converted.Snapshots = make([]*Snapshot, 0, len(s.Snapshots))
+	for _, snap := range s.Snapshots {
+		c := *snap
+		c.Time = c.Time.In(loc)
+		if !c.EndTime.IsZero() {
+			c.EndTime = c.EndTime.In(loc)
+		}
+		converted.Snapshots = append(converted.Snapshots, &c)
+	}
+	return &converted
+}
+
+// Between returns a copy of the stream containing only the
+// snapshots taken at or after since and before until. A zero since or
+// until leaves that end of the range open.
+func (s *Stream) Between(since, until time.Time) *Stream {
+	filtered := *s
+	filtered.Snapshots = make([]*Snapshot, 0, len(s.Snapshots))
+	for _, snap := range s.Snapshots {
+		if !since.IsZero() && snap.Time.Before(since) {
+			continue
+		}
+		if !until.IsZero() && !snap.Time.Before(until) {
+			continue
+		}
+		filtered.Snapshots = append(filtered.Snapshots, snap)
+	}
+	return &filtered
+}
+
+// ReadStream decodes a stream from the JSON written by `thyme track
+// -o` read from r, which may be compressed with gzip. A single
+// snapshot, rather than a stream, is read as a stream of one
+// snapshot, and JSON lines, one snapshot per line, as a stream of
+// them all.
+func ReadStream(r io.Reader) (*Stream, error) {
+	br := bufio.NewReader(r)
+	r = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+	dec := json.NewDecoder(r)
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	// Decoding a snapshot as a stream would succeed and yield an empty
+	// stream, so the fields tell which one the JSON is.
+	var probe struct {
+		Snapshots json.RawMessage
+		Time      json.RawMessage
+		Windows   json.RawMessage
+	}
+	if err := json.Unmarshal(raw, &probe); err != nil {
+		return nil, err
+	}
+	if probe.Snapshots == nil && (probe.Time != nil || probe.Windows != nil) {
+		snap := &Snapshot{}
+		if err := json.Unmarshal(raw, snap); err != nil {
+			return nil, err
+		}
+		return readJSONLines(snap, dec)
+	}
+	stream := &Stream{}
+	if err := json.Unmarshal(raw, stream); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+// MergeStreams returns a stream containing the snapshots of all the
+// streams, ordered by time. When several snapshots were taken at the
+// same time, only the first one is kept.
+func MergeStreams(streams ...*Stream) *Stream {
+	merged := &Stream{Snapshots: []*Snapshot{}}
+	for _, s := range streams {
+		merged.Snapshots = append(merged.Snapshots, s.Snapshots...)
+	}
+	sort.SliceStable(merged.Snapshots, func(a, b int) bool {
+		return merged.Snapshots[a].Time.Before(merged.Snapshots[b].Time)
+	})
+	deduped := merged.Snapshots[:0]
+	for _, snap := range merged.Snapshots {
+		if n := len(deduped); n > 0 && deduped[n-1].Time.Equal(snap.Time) {
+			continue
+		}
+		deduped = append(deduped, snap)
+	}
+	merged.Snapshots = deduped
+	return merged
+}
+
 // Snapshot represents the current state of all in-use application
 // windows at a moment in time.
 type Snapshot struct {
@@ -63,6 +350,225 @@ type Snapshot struct {
 	Windows []*Window
 	Active  int64
 	Visible []int64
+
+	// AlsoActive lists the other windows that counted as active along
+	// with the focused one, Active, such as those tiled next to it (see
+	// DetectSplitActive). The time of the snapshot is split evenly
+	// among all its active windows. It is ignored if Active is 0.
+	AlsoActive []int64 `json:",omitempty"`
+
+	// Idle is how long the user had gone without touching the
+	// keyboard or mouse when the snapshot was taken. It is zero if
+	// the tracker couldn't determine it.
+	Idle time.Duration
+
+	// Monitors lists the displays connected when the snapshot was
+	// taken, if the tracker could detect them.
+	Monitors []*Monitor
+
+	// Locked is whether the screen was locked when the snapshot was
+	// taken. It is false if the tracker couldn't determine it.
+	Locked bool `json:",omitempty"`
+
+	// InMeeting is whether a camera or microphone was in use when the
+	// snapshot was taken, as detected by DetectMeeting. It is false if
+	// meetings weren't detected.
+	InMeeting bool `json:",omitempty"`
+
+	// EndTime is set when later, equivalent snapshots were merged
+	// into this one as it was stored, and is the time of the last of
+	// them. The snapshot then stands for the whole period from Time
+	// to EndTime.
+	EndTime time.Time `json:",omitzero"`
+
+	// Project is the project the user said they were working on when
+	// the snapshot was taken, with `thyme tag` or `thyme track
+	// --project`, if any.
+	Project string `json:",omitempty"`
+
+	// Zone is the time zone the snapshot was taken in, as returned by
+	// LocalZone. Time keeps the offset from UTC, but not which zone
+	// it is from, and loses it once converted, e.g. by `thyme import`.
+	Zone string `json:",omitempty"`
+
+	// Interval is how long thyme track was going to wait until the
+	// next snapshot when it took this one. It is zero in snapshots
+	// that weren't taken at regular intervals, and in those recorded
+	// before it was stored.
+	Interval time.Duration `json:",omitempty"`
+
+	// InputRate is how many keys, mouse buttons and scroll steps the
+	// user pressed per minute since the previous snapshot, as measured
+	// by an InputRater, or zero if input wasn't tracked. Which keys
+	// were pressed is never recorded.
+	InputRate float64 `json:",omitempty"`
+}
+
+// End returns the time of the last observation the snapshot stands
+// for: EndTime if it was merged with later snapshots, Time otherwise.
+func (s *Snapshot) End() time.Time {
+	if s.EndTime.IsZero() {
+		return s.Time
+	}
+	return s.EndTime
+}
+
+// Equivalent reports whether s and o record the same windows, active
+// windows, visible windows, monitors, project, lock state, meeting
+// state and time zone. Their times, idle times and intervals are
+// ignored.
+func (s *Snapshot) Equivalent(o *Snapshot) bool {
+	if s.Active != o.Active || s.Project != o.Project || s.Locked != o.Locked || s.InMeeting != o.InMeeting || s.Zone != o.Zone || len(s.Windows) != len(o.Windows) || len(s.Visible) != len(o.Visible) || len(s.Monitors) != len(o.Monitors) {
+		return false
+	}
+	for i, w := range s.Windows {
+		if *w != *o.Windows[i] {
+			return false
+		}
+	}
+	for i, v := range s.Visible {
+		if v != o.Visible[i] {
+			return false
+		}
+	}
+	if !slices.Equal(s.AlsoActive, o.AlsoActive) {
+		return false
+	}
+	for i, m := range s.Monitors {
+		if *m != *o.Monitors[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Mergeable reports whether o, taken after s, can be merged into s
+// without losing information: the two must be Equivalent, o must have
+// been taken within maxGap of the end of s, and the user must have
+// been busy, or idle, both before s ended and since, and typing, or
+// not, in both.
+func (s *Snapshot) Mergeable(o *Snapshot, maxGap time.Duration) bool {
+	gap := o.Time.Sub(s.End())
+	if gap < 0 || gap > maxGap {
+		return false
+	}
+	if (s.Idle < gap) != (o.Idle < gap) || s.InputActive() != o.InputActive() {
+		return false
+	}
+	return s.Equivalent(o)
+}
+
+// Merge extends s to also stand for o, which must be Mergeable into
+// it.
+func (s *Snapshot) Merge(o *Snapshot) {
+	// The input rate of o was measured since s ended, and that of s
+	// over about as long before it was taken, plus its own duration.
+	if gap := o.Time.Sub(s.End()); gap > 0 {
+		span := s.End().Sub(s.Time) + gap
+		s.InputRate = (s.InputRate*span.Minutes() + o.InputRate*gap.Minutes()) / (span + gap).Minutes()
+	}
+	s.EndTime = o.End()
+	s.Idle = o.Idle
+	s.Interval = o.Interval
+}
+
+// ActiveWindow returns the window that was active when the snapshot
+// was taken, or nil if there was none.
+func (s *Snapshot) ActiveWindow() *Window {
+	for _, w := range s.Windows {
+		if w.ID == s.Active {
+			return w
+		}
+	}
+	return nil
+}
+
+// activeIDs returns the IDs of the windows that were active when the
+// snapshot was taken, Active then those of AlsoActive, each once, or
+// none if no window was focused.
+func (s *Snapshot) activeIDs() []int64 {
+	if s.Active == 0 {
+		return nil
+	}
+	ids := []int64{s.Active}
+	for _, id := range s.AlsoActive {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// ActiveWindows returns the windows that were active when the snapshot
+// was taken: the focused one first, then the others of AlsoActive. It
+// is empty if no window was focused.
+func (s *Snapshot) ActiveWindows() []*Window {
+	var windows []*Window
+	for _, id := range s.activeIDs() {
+		for _, w := range s.Windows {
+			if w.ID == id {
+				windows = append(windows, w)
+				break
+			}
+		}
+	}
+	return windows
+}
+
+// ActiveShare returns the share of the time of the snapshot that each
+// of its active windows is attributed: one over how many windows were
+// active, or 0 if none was. Windows left out of the snapshot since, as
+// by Stream.matchingApps, keep their share, so that the others aren't
+// attributed more than they were.
+func (s *Snapshot) ActiveShare() float64 {
+	if n := len(s.activeIDs()); n > 0 {
+		return 1 / float64(n)
+	}
+	return 0
+}
+
+// KeepActiveOnly drops every window but the active one from the
+// snapshot, for when only the focused window matters, leaving it the
+// only active one. The active window stays visible if it was; a
+// snapshot without an active window is left without windows.
+func (s *Snapshot) KeepActiveOnly() {
+	s.AlsoActive = nil
+	active := s.ActiveWindow()
+	if active == nil {
+		s.Windows, s.Visible = nil, nil
+		return
+	}
+	visible := slices.Contains(s.Visible, active.ID)
+	s.Windows, s.Visible = []*Window{active}, nil
+	if visible {
+		s.Visible = []int64{active.ID}
+	}
+}
+
+// MarkPartial sets Partial on each window of s that has no title or
+// no PID, which trackers call once they have filled in what they could.
+func (s *Snapshot) MarkPartial() {
+	for _, w := range s.Windows {
+		w.Partial = strings.TrimSpace(w.Name) == "" || w.PID == 0
+	}
+}
+
+// PartialShare returns the share of the snapshots of s with an active
+// window in which that window is Partial, between 0 and 1.
+func (s *Stream) PartialShare() float64 {
+	var active, partial int
+	for _, snap := range s.Snapshots {
+		if w := snap.ActiveWindow(); w != nil {
+			active++
+			if w.Partial {
+				partial++
+			}
+		}
+	}
+	if active == 0 {
+		return 0
+	}
+	return float64(partial) / float64(active)
 }
 
 // Print returns a pretty-printed representation of the snapshot.
@@ -88,6 +594,21 @@ s_Windows:
 	}
 
 	fmt.Fprintf(&b, "%s\n", s.Time.Format("Mon Jan 2 15:04:05 -0700 MST 2006"))
+	if s.Idle > 0 {
+		fmt.Fprintf(&b, "\tIdle: %s\n", s.Idle)
+	}
+	if s.Locked {
+		fmt.Fprintf(&b, "\tLocked\n")
+	}
+	if s.InMeeting {
+		fmt.Fprintf(&b, "\tIn a meeting\n")
+	}
+	if s.Interval > 0 {
+		fmt.Fprintf(&b, "\tInterval: %s\n", s.Interval)
+	}
+	if s.InputRate > 0 {
+		fmt.Fprintf(&b, "\tInput: %.0f/min\n", s.InputRate)
+	}
 	if active != nil {
 		fmt.Fprintf(&b, "\tActive: %s\n", active.Info().Print())
 	}
@@ -113,13 +634,54 @@ type Window struct {
 	// ID is the numerical identifier of the window.
 	ID int64
 
-	// Desktop is the numerical identifier of the desktop the
-	// window belongs to.  Equal to -1 if the window is sticky.
+	// Desktop is the numerical identifier of the desktop, or
+	// workspace, the window belongs to.  Equal to -1 if the window is
+	// sticky, and 0 if the window system doesn't expose desktops.
 	Desktop int64
 
 	// Name is the display name of the window (typically what the
 	// windowing system shows in the top bar of the window).
 	Name string
+
+	// X and Y are the screen coordinates of the top-left corner of
+	// the window, and Width and Height its size, in pixels. All four
+	// are zero if the tracker couldn't determine the geometry.
+	X, Y          int
+	Width, Height int
+
+	// Monitor is the name of the monitor that shows the largest part
+	// of the window (see Snapshot.AssignMonitors).
+	Monitor string
+
+	// Display is the X display the window is on (e.g. ":1"), when the
+	// tracker was asked to track several (see LinuxTracker.Displays).
+	Display string `json:",omitempty"`
+
+	// PID is the ID of the process that owns the window, and Process
+	// the name of its executable (e.g. "firefox"). Unlike the window
+	// name, the process name doesn't change as the user works in the
+	// window. Both are empty if the tracker couldn't determine them.
+	PID     int    `json:",omitempty"`
+	Process string `json:",omitempty"`
+
+	// FullScreen is whether the window covers its whole monitor, as
+	// determined by Snapshot.DetectFullScreen.
+	FullScreen bool `json:",omitempty"`
+
+	// URL is the address of the page shown by the window, if it is
+	// the active window of a browser and URLs are being captured (see
+	// CaptureURL).
+	URL string `json:",omitempty"`
+
+	// Partial is whether the tracker couldn't fully identify the
+	// window: it has no title, or the process that owns it is unknown.
+	Partial bool `json:",omitempty"`
+
+	// App is the application that owns the window, if the tracker can
+	// tell it regardless of the title, as for UWP apps on Windows,
+	// whose windows all belong to ApplicationFrameHost.exe. Info
+	// prefers it to the application named in the title.
+	App string `json:",omitempty"`
 }
 
 // systemNames is a set of blacklisted window names that are known to
@@ -149,71 +711,116 @@ func (w *Window) IsSticky() bool {
 	return w.Desktop == -1
 }
 
+// DesktopLabel returns a label for the desktop w is on: its number, or
+// "all" if w is sticky.
+func (w *Window) DesktopLabel() string {
+	if w.IsSticky() {
+		return "all"
+	}
+	return strconv.FormatInt(w.Desktop, 10)
+}
+
 // IsOnDesktop returns true if the window is present on the specified
 // desktop
 func (w *Window) IsOnDesktop(desktop int64) bool {
 	return w.IsSticky() || w.Desktop == desktop
 }
 
-const (
-	defaultWindowTitleSeparator       = " - "
-	microsoftEdgeWindowTitleSeparator = "\u200e- "
-)
-
 // Info returns more structured metadata about a window. The metadata
 // is extracted using heuristics.
 //
-// Assumptions:
-//     1) Most windows use " - " to separate their window names from their content
-//     2) Most windows use the " - " with the application name at the end.
-//     3) The few programs that reverse this convention only reverse it.
+// The title is split into parts wherever it contains one of the usual
+// separators (" - ", " — ", " – "). If the first or last part is
+// the name of an application with a known title pattern (see
+// RegisterTitlePattern), that pattern decides which parts hold the
+// application, the sub-application and the title. Otherwise, the
+// application name is assumed to be the last part.
+//
+// If the tracker set App, it is the application. The title is then
+// parsed as above, unless that finds another application, in which
+// case the whole name is the title.
+//
+// For applications with a document pattern (see
+// RegisterDocumentPattern), such as editors, the document the window
+// shows is then extracted from the title.
 func (w *Window) Info() *Winfo {
-	// Special Cases
-	fields := strings.Split(w.Name, defaultWindowTitleSeparator)
-	if len(fields) > 1 {
-		last := strings.TrimSpace(fields[len(fields)-1])
-		if last == "Google Chrome" {
-			return &Winfo{
-				App:    "Google Chrome",
-				SubApp: strings.TrimSpace(fields[len(fields)-2]),
-				Title:  strings.Join(fields[0:len(fields)-2], defaultWindowTitleSeparator),
-			}
+	key := titleInfoKey{name: w.Name, app: w.App}
+	titleInfos.Lock()
+	defer titleInfos.Unlock()
+	info, ok := titleInfos.m[key]
+	if !ok {
+		if len(titleInfos.m) >= titleInfosSize {
+			clear(titleInfos.m)
 		}
+		info = w.parseInfo()
+		titleInfos.m[key] = info
+	}
+	return &info
+}
+
+// parseInfo returns the metadata of w as Info describes it, without
+// looking in titleInfos.
+func (w *Window) parseInfo() Winfo {
+	info := parseTitle(w.Name)
+	if w.App != "" && !strings.EqualFold(info.App, w.App) {
+		info = Winfo{Title: strings.TrimSpace(w.Name)}
+	}
+	if w.App != "" {
+		info.App = w.App
 	}
+	info.Document = matchDocument(info.App, info.Title)
+	return info
+}
 
-	if strings.Contains(w.Name, microsoftEdgeWindowTitleSeparator) {
-		// App Name Last
-		beforeSep := strings.LastIndex(w.Name, microsoftEdgeWindowTitleSeparator)
-		afterSep := beforeSep + len(microsoftEdgeWindowTitleSeparator)
-		return &Winfo{
-			App:   strings.TrimSpace(w.Name[afterSep:]),
-			Title: strings.TrimSpace(w.Name[:beforeSep]),
+// parseTitle extracts the metadata of a window from its title, name.
+func parseTitle(name string) Winfo {
+	parts, bounds := splitTitle(name)
+	n := len(parts)
+	if n < 2 {
+		// No Application name separator
+		return Winfo{
+			Title: name,
 		}
 	}
+	// title returns the original text of parts i through j-1.
+	title := func(i, j int) string {
+		return strings.TrimSpace(name[bounds[i][0]:bounds[j-1][1]])
+	}
 
-	// Normal Cases
-	if beforeSep := strings.Index(w.Name, defaultWindowTitleSeparator); beforeSep > -1 {
-		// App Name First
-		if w.Name[:beforeSep] == "Slack" {
-			afterSep := beforeSep + len(defaultWindowTitleSeparator)
-			return &Winfo{
-				App:   strings.TrimSpace(w.Name[:beforeSep]),
-				Title: strings.TrimSpace(w.Name[afterSep:]),
+	// App Name Last
+	if p := matchTitlePattern(parts[n-1], false); p != nil {
+		if p.SubApps && n > 2 {
+			return Winfo{
+				App:    p.App,
+				SubApp: parts[n-2],
+				Title:  title(0, n-2),
 			}
 		}
+		return Winfo{
+			App:   p.App,
+			Title: title(0, n-1),
+		}
+	}
 
-		// App Name Last
-		beforeSep := strings.LastIndex(w.Name, defaultWindowTitleSeparator)
-		afterSep := beforeSep + len(defaultWindowTitleSeparator)
-		return &Winfo{
-			App:   strings.TrimSpace(w.Name[afterSep:]),
-			Title: strings.TrimSpace(w.Name[:beforeSep]),
+	// App Name First
+	if p := matchTitlePattern(parts[0], true); p != nil {
+		return Winfo{
+			App:   p.App,
+			Title: title(1, n),
 		}
 	}
 
-	// No Application name separator
-	return &Winfo{
-		Title: w.Name,
+	// Unknown application: assume the name is last, unless the title
+	// ends with a separator and so names no application.
+	if parts[n-1] == "" {
+		if t := title(0, n-1); t != "" {
+			return Winfo{Title: t}
+		}
+		return Winfo{Title: strings.TrimSpace(name)}
+	}
+	return Winfo{
+		App:   parts[n-1],
+		Title: title(0, n-1),
 	}
 }
 
@@ -228,6 +835,10 @@ type Winfo struct {
 	// "Google Chrome" and the SubApp field would be "Sourcegraph".
 	SubApp string
 
+	// Document is the document the window shows, e.g. the file open
+	// in an editor, for applications whose titles name one.
+	Document string
+
 	// Title is the title of the window after the App and SubApp name
 	// have been stripped.
 	Title string
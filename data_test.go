@@ -0,0 +1,108 @@
+package thyme
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestReadStreamWithoutGeometry reads a stream written before windows
+// had a geometry, whose windows get a zero one.
+func TestReadStreamWithoutGeometry(t *testing.T) {
+	const old = `{"Snapshots":[{"Time":"2016-05-03T09:00:00Z","Windows":[{"ID":1,"Desktop":0,"Name":"main.go - Code"},{"ID":2,"Desktop":0,"Name":"~ - Terminal"}],"Active":2,"Visible":[1,2]}]}`
+	stream, err := ReadStream(strings.NewReader(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.Snapshots) != 1 {
+		t.Fatalf("got %d snapshots, want 1", len(stream.Snapshots))
+	}
+	snap := stream.Snapshots[0]
+	want := []*Window{{ID: 1, Name: "main.go - Code"}, {ID: 2, Name: "~ - Terminal"}}
+	if len(snap.Windows) != len(want) {
+		t.Fatalf("got %d windows, want %d", len(snap.Windows), len(want))
+	}
+	for i, w := range want {
+		if *snap.Windows[i] != *w {
+			t.Errorf("window %d is %+v, want %+v", i, *snap.Windows[i], *w)
+		}
+	}
+	if snap.Active != 2 {
+		t.Errorf("active window %d, want 2", snap.Active)
+	}
+
+	// Written again, the geometry reads back the same.
+	snap.Windows[0].X, snap.Windows[0].Y, snap.Windows[0].Width, snap.Windows[0].Height = 10, 20, 960, 1080
+	b, err := json.Marshal(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := ReadStream(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := *again.Snapshots[0].Windows[0]; got != *snap.Windows[0] {
+		t.Errorf("window read back as %+v, want %+v", got, *snap.Windows[0])
+	}
+}
+
+func TestMergeStreams(t *testing.T) {
+	at := func(minutes int) time.Time {
+		return time.Date(2024, 3, 4, 9, minutes, 0, 0, time.UTC)
+	}
+	snap := func(minutes int, active int64) *Snapshot {
+		return &Snapshot{Time: at(minutes), Windows: []*Window{{ID: active, Name: "main.go - Code"}}, Active: active}
+	}
+	paris := time.FixedZone("CET", 3600)
+	for _, tt := range []struct {
+		name    string
+		streams []*Stream
+		want    []*Snapshot
+	}{
+		{
+			name: "none",
+		},
+		{
+			name:    "interleaved",
+			streams: []*Stream{{Snapshots: []*Snapshot{snap(0, 1), snap(2, 1)}}, {Snapshots: []*Snapshot{snap(1, 2), snap(3, 2)}}},
+			want:    []*Snapshot{snap(0, 1), snap(1, 2), snap(2, 1), snap(3, 2)},
+		},
+		{
+			name:    "out of order",
+			streams: []*Stream{{Snapshots: []*Snapshot{snap(3, 1), snap(1, 1)}}, {Snapshots: []*Snapshot{snap(2, 2)}}},
+			want:    []*Snapshot{snap(1, 1), snap(2, 2), snap(3, 1)},
+		},
+		{
+			name:    "same time",
+			streams: []*Stream{{Snapshots: []*Snapshot{snap(0, 1), snap(1, 1)}}, {Snapshots: []*Snapshot{snap(1, 2), snap(2, 2)}}},
+			want:    []*Snapshot{snap(0, 1), snap(1, 1), snap(2, 2)},
+		},
+		{
+			name:    "same time in another zone",
+			streams: []*Stream{{Snapshots: []*Snapshot{snap(0, 1)}}, {Snapshots: []*Snapshot{{Time: at(0).In(paris), Active: 2}}}},
+			want:    []*Snapshot{snap(0, 1)},
+		},
+		{
+			name:    "same file twice",
+			streams: []*Stream{{Snapshots: []*Snapshot{snap(0, 1), snap(1, 2)}}, {Snapshots: []*Snapshot{snap(0, 1), snap(1, 2)}}},
+			want:    []*Snapshot{snap(0, 1), snap(1, 2)},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MergeStreams(tt.streams...).Snapshots
+			if got == nil {
+				t.Error("got a nil list of snapshots, which would be written as null")
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d snapshots, want %d", len(got), len(tt.want))
+			}
+			for i, w := range tt.want {
+				if !got[i].Time.Equal(w.Time) || got[i].Active != w.Active {
+					t.Errorf("snapshot %d taken at %s with window %d active, want %s and %d", i, got[i].Time, got[i].Active, w.Time, w.Active)
+				}
+			}
+		})
+	}
+}
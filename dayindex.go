@@ -0,0 +1,56 @@
+package thyme
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"time"
+)
+
+// The index of the daily reports is styled like them.
+var (
+	//go:embed assets/report/index.tmpl
+	dayIndexTmpl string
+
+	dayIndexTemplate = template.Must(template.New("index.tmpl").Funcs(statsFuncs).Parse(dayIndexTmpl))
+)
+
+// DayReportFile returns the name of the file the daily report of date
+// is written to, e.g. 2024-03-01.html.
+func DayReportFile(date time.Time) string {
+	return date.Format("2006-01-02") + ".html"
+}
+
+// dayIndexEntry is a line of the index of the daily reports.
+type dayIndexEntry struct {
+	Date time.Time
+	File string
+
+	// Active is the time spent active that day, and Top the
+	// application most of it went to.
+	Active time.Duration
+	Top    string
+}
+
+// WriteDayIndex writes to w an HTML page that links to the daily
+// report of each of days, in the files named by DayReportFile next to
+// it, along with how long the user was active that day and the
+// application they spent most of that time in.
+func WriteDayIndex(w io.Writer, days []*DayStream) error {
+	apps, err := LookupGrouping("app")
+	if err != nil {
+		return err
+	}
+	entries := make([]*dayIndexEntry, 0, len(days))
+	for _, d := range days {
+		e := &dayIndexEntry{Date: d.Date, File: DayReportFile(d.Date)}
+		for _, t := range apps.Totals(d.Stream, nil) {
+			if t.ActiveSeconds > 0 && e.Top == "" {
+				e.Top = t.Label
+			}
+			e.Active += time.Duration(t.ActiveSeconds * float64(time.Second))
+		}
+		entries = append(entries, e)
+	}
+	return dayIndexTemplate.Execute(w, entries)
+}
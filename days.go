@@ -0,0 +1,166 @@
+package thyme
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// DaySpan is the part of a day between the user's first and last
+// activity.
+type DaySpan struct {
+	// Date is the day, as midnight UTC.
+	Date time.Time
+
+	// First is when the user first had an active window that day, and
+	// Last when they last had one.
+	First time.Time
+	Last  time.Time
+}
+
+// Span returns the time between the first and last activity of the
+// day.
+func (d *DaySpan) Span() time.Duration {
+	return d.Last.Sub(d.First)
+}
+
+// NewDaySpans returns the span of activity of each day of stream, in
+// chronological order. Days are those of the location of the
+// snapshots' times, and only snapshots with an active window count, so
+// days without any are left out.
+func NewDaySpans(stream *Stream) []*DaySpan {
+	spans := make(map[int]*DaySpan)
+	for _, snap := range stream.Snapshots {
+		if snap.ActiveWindow() == nil {
+			continue
+		}
+		day := civilDay(snap.Time)
+		s, exists := spans[day]
+		if !exists {
+			s = &DaySpan{Date: civilDate(day), First: snap.Time, Last: snap.End()}
+			spans[day] = s
+		}
+		if snap.Time.Before(s.First) {
+			s.First = snap.Time
+		}
+		if end := snap.End(); end.After(s.Last) {
+			s.Last = end
+		}
+	}
+	days := make([]*DaySpan, 0, len(spans))
+	for _, s := range spans {
+		days = append(days, s)
+	}
+	sort.Slice(days, func(a, b int) bool { return days[a].Date.Before(days[b].Date) })
+	return days
+}
+
+// DayStream holds the snapshots of a stream that were taken on one
+// day.
+type DayStream struct {
+	// Date is the day, as midnight UTC.
+	Date time.Time
+
+	Stream *Stream
+}
+
+// SplitDays splits stream, whose snapshots must be in chronological
+// order, into the snapshots taken on each day, in chronological order.
+// Days are those of the location of the snapshots' times, as in
+// NewDaySpans, and days without snapshots are left out. Each part
+// keeps the other fields of stream, such as its Interval.
+func SplitDays(stream *Stream) []*DayStream {
+	var days []*DayStream
+	for start := 0; start < len(stream.Snapshots); {
+		day := civilDay(stream.Snapshots[start].Time)
+		end := start
+		for end < len(stream.Snapshots) && civilDay(stream.Snapshots[end].Time) == day {
+			end++
+		}
+		part := *stream
+		part.Snapshots = stream.Snapshots[start:end]
+		days = append(days, &DayStream{Date: civilDate(day), Stream: &part})
+		start = end
+	}
+	return days
+}
+
+// workdayMinActive is how long the user must have been active on a
+// day for it to count towards the working hours of its weekday, so
+// that e.g. a laptop left open for a few minutes on a Sunday doesn't
+// skew them.
+const workdayMinActive = 30 * time.Minute
+
+// WeekdayHours are the typical working hours of a day of the week:
+// when the user usually starts and ends the day.
+type WeekdayHours struct {
+	Weekday time.Weekday
+
+	// Days is the number of days the hours are averaged over.
+	Days int
+
+	// Start and End are the average times of the first and last
+	// activity on those days, as the time since midnight, and
+	// StartDev and EndDev their standard deviations.
+	Start, End       time.Duration
+	StartDev, EndDev time.Duration
+}
+
+// NewWeekdayHours returns the working hours of each day of the week on
+// which the user was active, from Monday to Sunday. They are averaged
+// over the days of stream on which the user was active for at least
+// workdayMinActive, from the first to the last activity of each, as
+// in NewDaySpans.
+func NewWeekdayHours(stream *Stream) []*WeekdayHours {
+	durations := sampleDurations(stream)
+	active := make(map[int]time.Duration)
+	for i, snap := range stream.Snapshots {
+		if snap.ActiveWindow() != nil {
+			active[civilDay(snap.Time)] += durations[i]
+		}
+	}
+
+	var starts, ends [7][]float64
+	for _, d := range NewDaySpans(stream) {
+		if active[civilDay(d.First)] < workdayMinActive {
+			continue
+		}
+		h, m, sec := d.First.Clock()
+		start := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec)*time.Second
+		// Weeks start on Monday.
+		day := (int(d.First.Weekday()) + 6) % 7
+		starts[day] = append(starts[day], start.Seconds())
+		ends[day] = append(ends[day], (start + d.Span()).Seconds())
+	}
+
+	var hours []*WeekdayHours
+	for day := range starts {
+		if len(starts[day]) == 0 {
+			continue
+		}
+		start, startDev := meanDev(starts[day])
+		end, endDev := meanDev(ends[day])
+		hours = append(hours, &WeekdayHours{
+			Weekday:  time.Weekday((day + 1) % 7),
+			Days:     len(starts[day]),
+			Start:    secondsDuration(start),
+			End:      secondsDuration(end),
+			StartDev: secondsDuration(startDev),
+			EndDev:   secondsDuration(endDev),
+		})
+	}
+	return hours
+}
+
+// meanDev returns the mean and standard deviation of values, which
+// must not be empty.
+func meanDev(values []float64) (mean, dev float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		dev += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(dev / float64(len(values)))
+}
@@ -0,0 +1,125 @@
+package thyme
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteBusyTimeout is how long a connection to a sqlite database
+// waits for another one to release its lock, e.g. while `thyme show`
+// reads the database `thyme track` is writing to, before failing with
+// "database is locked".
+const sqliteBusyTimeout = 5 * time.Second
+
+// openSQLite opens the sqlite database at path with a busy timeout.
+// If write is set, the database is also switched to WAL mode, in which
+// readers and the writer don't block each other, and transactions
+// take the write lock as soon as they begin, so that two of them
+// can't deadlock trying to upgrade their read locks.
+func openSQLite(path string, write bool) (*sql.DB, error) {
+	params := url.Values{}
+	params.Set("_busy_timeout", strconv.FormatInt(sqliteBusyTimeout.Milliseconds(), 10))
+	if write {
+		params.Set("_journal_mode", "WAL")
+		params.Set("_txlock", "immediate")
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	return sql.Open("sqlite3", path+sep+params.Encode())
+}
+
+// sqliteRetries is how many more times a write that failed because the
+// database was locked is tried, sqliteRetryDelay apart, doubling each
+// time, once the busy timeout wasn't enough.
+const (
+	sqliteRetries    = 3
+	sqliteRetryDelay = 100 * time.Millisecond
+)
+
+// retryLocked calls f until it succeeds, fails with an error other
+// than the database being locked, or sqliteRetries retries have
+// failed.
+func retryLocked(f func() error) error {
+	delay := sqliteRetryDelay
+	for i := 0; ; i++ {
+		err := f()
+		if i == sqliteRetries || !isLocked(err) {
+			return err
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// isLocked reports whether err is a sqlite error saying the database,
+// or a table in it, is locked: SQLITE_BUSY or SQLITE_LOCKED. The
+// driver only defines their codes when built with cgo, so their
+// messages tell them apart.
+func isLocked(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "database is locked") || strings.Contains(msg, "database table is locked")
+}
+
+// LoadStream reads every snapshot stored by `thyme track` in the
+// sqlite database at dbPath, ordered by time. A database that
+// doesn't have a data table yet yields an empty Stream.
+func LoadStream(dbPath string) (*Stream, error) {
+	db, err := openSQLite(dbPath, false)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	return loadSQLiteStream(db)
+}
+
+// loadSQLiteStream reads every snapshot in the data table of the
+// sqlite database db, if it has one.
+func loadSQLiteStream(db *sql.DB) (*Stream, error) {
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'data'").Scan(&n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return &Stream{Snapshots: []*Snapshot{}}, nil
+	}
+
+	rows, err := db.Query("SELECT value FROM data ORDER BY time")
+	if err != nil {
+		return nil, err
+	}
+	return scanStream(rows)
+}
+
+// scanStream decodes the snapshots in the value column of rows, and
+// closes rows.
+func scanStream(rows *sql.Rows) (*Stream, error) {
+	defer rows.Close()
+	stream := &Stream{Snapshots: []*Snapshot{}}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		var snap Snapshot
+		if err := json.Unmarshal([]byte(value), &snap); err != nil {
+			return nil, fmt.Errorf("could not decode snapshot %q: %s", value, err)
+		}
+		stream.Snapshots = append(stream.Snapshots, &snap)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
@@ -0,0 +1,82 @@
+package thyme
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestSQLiteConcurrentAccess writes to a database from two stores, as
+// two thyme track processes would, while others read it, as thyme show
+// and thyme serve would, and checks that none of them fails because
+// the database is locked.
+func TestSQLiteConcurrentAccess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "thyme.db")
+	const writers, readers, snapshots = 2, 4, 50
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	errs := make(chan error, writers*snapshots+readers*snapshots)
+	var wg sync.WaitGroup
+	for w := range writers {
+		s, _, err := openSQLiteStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range snapshots {
+				at := start.Add(time.Duration(i*writers+w) * time.Second)
+				snap := &Snapshot{Time: at, Windows: []*Window{{ID: 1, Name: "main.go - Code"}}, Active: 1}
+				if err := s.Append(snap); err != nil {
+					errs <- err
+					continue
+				}
+				snap.EndTime = at.Add(time.Second / 2)
+				if err := s.UpdateLast(snap); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	for r := range readers {
+		s, _, err := openSQLiteStore(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range snapshots {
+				var err error
+				switch r % 3 {
+				case 0:
+					_, err = LoadStream(path)
+				case 1:
+					_, err = s.Last()
+				default:
+					// DayTotals writes to the cache too.
+					_, err = s.DayTotals(DayTotalsOptions{Location: time.UTC})
+				}
+				if err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	stream, err := LoadStream(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(stream.Snapshots); n != writers*snapshots {
+		t.Errorf("got %d snapshots, want %d", n, writers*snapshots)
+	}
+}
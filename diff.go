@@ -0,0 +1,110 @@
+package thyme
+
+import (
+	"fmt"
+	"sort"
+)
+
+// WindowChangeKind says how a window changed from one snapshot to the
+// next.
+type WindowChangeKind int
+
+const (
+	// WindowAdded is a window that only the second snapshot has.
+	WindowAdded WindowChangeKind = iota
+
+	// WindowRemoved is a window that only the first snapshot has.
+	WindowRemoved
+
+	// WindowRenamed is a window whose name changed.
+	WindowRenamed
+
+	// WindowActivated is a window that became the active one.
+	WindowActivated
+
+	// WindowDeactivated is a window that stopped being the active one.
+	WindowDeactivated
+)
+
+// WindowChange is a change to a window between two snapshots.
+type WindowChange struct {
+	Kind WindowChangeKind
+
+	// Old is the window in the first snapshot, and New the window in
+	// the second. Old is nil for WindowAdded, and New for
+	// WindowRemoved.
+	Old, New *Window
+}
+
+// String returns the change as a line of `thyme diff`: the window ID
+// and name after "+" for added windows, "-" for removed ones, and "~"
+// for those that changed.
+func (c *WindowChange) String() string {
+	switch c.Kind {
+	case WindowAdded:
+		return fmt.Sprintf("+ %d %s", c.New.ID, c.New.Info().Print())
+	case WindowRemoved:
+		return fmt.Sprintf("- %d %s", c.Old.ID, c.Old.Info().Print())
+	case WindowRenamed:
+		return fmt.Sprintf("~ %d %s -> %s", c.New.ID, c.Old.Info().Print(), c.New.Info().Print())
+	case WindowActivated:
+		return fmt.Sprintf("~ %d %s became active", c.New.ID, c.New.Info().Print())
+	case WindowDeactivated:
+		return fmt.Sprintf("~ %d %s is no longer active", c.New.ID, c.New.Info().Print())
+	}
+	return fmt.Sprintf("? %d", c.id())
+}
+
+// DiffSnapshots returns the windows that appeared, disappeared, were
+// renamed, or became or stopped being the active one from a to b,
+// matched by ID. The changes are ordered by window ID, and a window
+// that was both renamed and activated or deactivated has one change of
+// each kind.
+func DiffSnapshots(a, b *Snapshot) []*WindowChange {
+	old := make(map[int64]*Window, len(a.Windows))
+	for _, w := range a.Windows {
+		old[w.ID] = w
+	}
+	current := make(map[int64]*Window, len(b.Windows))
+	for _, w := range b.Windows {
+		current[w.ID] = w
+	}
+
+	var changes []*WindowChange
+	for _, w := range a.Windows {
+		if current[w.ID] == nil {
+			changes = append(changes, &WindowChange{Kind: WindowRemoved, Old: w})
+		}
+	}
+	for _, w := range b.Windows {
+		o := old[w.ID]
+		if o == nil {
+			changes = append(changes, &WindowChange{Kind: WindowAdded, New: w})
+			continue
+		}
+		if o.Name != w.Name {
+			changes = append(changes, &WindowChange{Kind: WindowRenamed, Old: o, New: w})
+		}
+		switch wasActive, isActive := a.Active == w.ID, b.Active == w.ID; {
+		case isActive && !wasActive:
+			changes = append(changes, &WindowChange{Kind: WindowActivated, Old: o, New: w})
+		case wasActive && !isActive:
+			changes = append(changes, &WindowChange{Kind: WindowDeactivated, Old: o, New: w})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool {
+		if id, jd := changes[i].id(), changes[j].id(); id != jd {
+			return id < jd
+		}
+		return changes[i].Kind < changes[j].Kind
+	})
+	return changes
+}
+
+// id returns the ID of the window that changed.
+func (c *WindowChange) id() int64 {
+	if c.New != nil {
+		return c.New.ID
+	}
+	return c.Old.ID
+}
@@ -0,0 +1,110 @@
+package thyme
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// documentPatterns extract the document a window shows, e.g. the file
+// open in an editor, from its title once Window.Info took the name of
+// the application out of it. They are keyed by application, as
+// returned by Window.Info, and the first group of a pattern that
+// matches the title is the document. Applications that aren't listed
+// have no documents.
+var documentPatterns = map[string]*regexp.Regexp{
+	// "● main.go - thyme", where the dot marks unsaved changes and
+	// thyme is the folder open.
+	"Visual Studio Code": regexp.MustCompile(`^(?:● )?(.*?)(?: - .*)?$`),
+	"Code":               regexp.MustCompile(`^(?:● )?(.*?)(?: [-—] .*)?$`),
+	// "~/src/thyme/main.go (thyme) •"
+	"Sublime Text": regexp.MustCompile(`^(.*?)(?: \([^)]*\))?(?: •)?$`),
+	// "*main.go (~/src/thyme)"
+	"gedit":       regexp.MustCompile(`^\*?(.*?)(?: \([^)]*\))?$`),
+	"Text Editor": regexp.MustCompile(`^\*?(.*?)(?: \([^)]*\))?$`),
+	"Notepad":     regexp.MustCompile(`^\*?(.*)$`),
+	"Notepad++":   regexp.MustCompile(`^\*?(.*)$`),
+	"Kate":        regexp.MustCompile(`^(.*?)(?: \[\*\])?$`),
+	// "main.go + (~/src/thyme)", where the plus marks unsaved changes.
+	"VIM":            regexp.MustCompile(`^(.*?)(?: [-+=]+)?(?: \([^)]*\))?$`),
+	"Word":           regexp.MustCompile(`^(.*)$`),
+	"Microsoft Word": regexp.MustCompile(`^(.*)$`),
+	"Excel":          regexp.MustCompile(`^(.*)$`),
+	"PowerPoint":     regexp.MustCompile(`^(.*)$`),
+	// "report.odt", the application going by its component.
+	"LibreOffice Writer":  regexp.MustCompile(`^(.*)$`),
+	"LibreOffice Calc":    regexp.MustCompile(`^(.*)$`),
+	"LibreOffice Impress": regexp.MustCompile(`^(.*)$`),
+}
+
+// RegisterDocumentPattern sets the pattern that extracts the documents
+// of app from window titles, as documentPatterns do, overriding the
+// built-in one if there is one. A nil pattern leaves app without
+// documents.
+func RegisterDocumentPattern(app string, rx *regexp.Regexp) {
+	titleInfos.Lock()
+	defer titleInfos.Unlock()
+	if rx == nil {
+		delete(documentPatterns, app)
+	} else {
+		documentPatterns[app] = rx
+	}
+	clear(titleInfos.m)
+}
+
+// LoadDocumentPatterns registers the document patterns in the JSON
+// file at path, an object mapping applications to regexes whose first
+// group matches the document in the titles of their windows, once the
+// application name is taken out, e.g.
+//
+//	{"My Editor": "^(\\S+)", "Visual Studio Code": ""}
+//
+// where an empty pattern leaves an application without documents. If
+// the file doesn't exist, the built-in patterns are kept as they are.
+func LoadDocumentPatterns(path string) error {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return fmt.Errorf("could not parse documents file %s: %s", path, err)
+	}
+	patterns := make(map[string]*regexp.Regexp, len(raw))
+	for app, pattern := range raw {
+		if pattern == "" {
+			patterns[app] = nil
+			continue
+		}
+		rx, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid document pattern %q for %q in %s: %s", pattern, app, path, err)
+		}
+		if rx.NumSubexp() < 1 {
+			return fmt.Errorf("invalid document pattern %q for %q in %s: it has no group to match the document", pattern, app, path)
+		}
+		patterns[app] = rx
+	}
+	for app, rx := range patterns {
+		RegisterDocumentPattern(app, rx)
+	}
+	return nil
+}
+
+// matchDocument returns the document of a window of app whose title,
+// without the application name, is title, or "" if the pattern of app
+// doesn't find one. The caller must hold the lock of titleInfos.
+func matchDocument(app, title string) string {
+	rx := documentPatterns[app]
+	if rx == nil {
+		return ""
+	}
+	m := rx.FindStringSubmatch(title)
+	if len(m) < 2 {
+		return ""
+	}
+	return m[1]
+}
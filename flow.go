@@ -0,0 +1,96 @@
+package thyme
+
+import (
+	"sort"
+	"time"
+)
+
+// FlowSlot is the length of the slots into which NewDayFlow divides
+// the day.
+const FlowSlot = 15 * time.Minute
+
+// flowApps is the number of applications that get their own band in
+// the flow of the day; the others are lumped together.
+const flowApps = 8
+
+// FlowOther stands for the applications lumped together in a DayFlow.
+const FlowOther = "Other"
+
+// DayFlow is how the user's activity typically flows over the course
+// of a day: which applications are active at each time of the day, on
+// average over the days of a stream.
+type DayFlow struct {
+	// Apps are the applications with the most active time, in
+	// decreasing order, followed by FlowOther if there are more.
+	Apps []string
+
+	// Shares[i][j] is the share of slot j of the day, the jth period
+	// of FlowSlot after midnight, during which Apps[i] was active on
+	// average, between 0 and 1.
+	Shares [][]float64
+}
+
+// NewDayFlow returns the flow of the day of stream, which must be in
+// chronological order. The average is over the days with activity.
+// Slots without snapshots, e.g. while the computer was asleep, have no
+// share, rather than that of the application active before them.
+func NewDayFlow(stream *Stream) *DayFlow {
+	const slots = int(24 * time.Hour / FlowSlot)
+	perApp := make(map[string][]time.Duration)
+	totals := make(map[string]time.Duration)
+	days := make(map[int]bool)
+	durations := sampleDurations(stream)
+	for i, snap := range stream.Snapshots {
+		win := snap.ActiveWindow()
+		if win == nil {
+			continue
+		}
+		app := appID(win)
+		if perApp[app] == nil {
+			perApp[app] = make([]time.Duration, slots)
+		}
+		days[civilDay(snap.Time)] = true
+		totals[app] += durations[i]
+
+		// Spread the snapshot over the slots it overlaps.
+		h, m, s := snap.Time.Clock()
+		tod := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second + time.Duration(snap.Time.Nanosecond())
+		for d := durations[i]; d > 0; {
+			slot := int(tod / FlowSlot)
+			chunk := min(d, time.Duration(slot+1)*FlowSlot-tod)
+			perApp[app][slot] += chunk
+			d -= chunk
+			tod = (tod + chunk) % (24 * time.Hour)
+		}
+	}
+
+	flow := &DayFlow{}
+	for app := range totals {
+		flow.Apps = append(flow.Apps, app)
+	}
+	sort.Slice(flow.Apps, func(a, b int) bool {
+		ta, tb := totals[flow.Apps[a]], totals[flow.Apps[b]]
+		if ta != tb {
+			return ta > tb
+		}
+		return flow.Apps[a] < flow.Apps[b]
+	})
+	if len(flow.Apps) > flowApps {
+		other := make([]time.Duration, slots)
+		for _, app := range flow.Apps[flowApps-1:] {
+			for j, d := range perApp[app] {
+				other[j] += d
+			}
+		}
+		flow.Apps = append(flow.Apps[:flowApps-1], FlowOther)
+		perApp[FlowOther] = other
+	}
+	for _, app := range flow.Apps {
+		shares := make([]float64, slots)
+		for j, d := range perApp[app] {
+			shares[j] = min(1, float64(d)/float64(time.Duration(len(days))*FlowSlot))
+		}
+		flow.Shares = append(flow.Shares, shares)
+	}
+	return flow
+}
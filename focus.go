@@ -0,0 +1,85 @@
+package thyme
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// FocusStretch is how long an uninterrupted stretch in one
+// application must last to count fully towards the focus score.
+const FocusStretch = 25 * time.Minute
+
+// FocusSwitchRate is the rate of switches between applications, per
+// active hour, that halves the focus score.
+const FocusSwitchRate = 20.0
+
+// DayFocus is the focus score of a day.
+type DayFocus struct {
+	// Date is the day, as midnight UTC.
+	Date time.Time
+
+	// Score is the focus score, from 0 to 100.
+	Score int
+
+	// Active is the active time during the day, and Switches the
+	// number of switches between applications.
+	Active   time.Duration
+	Switches int
+}
+
+// NewDayFocus returns the focus score of each day of stream, which
+// must be in chronological order, in chronological order. Days
+// without active time are left out.
+//
+// The score rewards long uninterrupted stretches in productive
+// applications and penalizes frequent switching. Each stretch of
+// duration d in an application of category c counts for
+//
+//	weight(c) × d × min(1, d / FocusStretch)
+//
+// where weight(c) comes from cats (see Categories.Weight). The sum of
+// these over the day, divided by the day's active time, is the share
+// F of the day spent focused, between 0 and 1. With R the number of
+// switches per active hour, the score is then
+//
+//	100 × F / (1 + R / FocusSwitchRate)
+//
+// rounded to the nearest integer. Stretches count towards the day on
+// which they started.
+func NewDayFocus(stream *Stream, cats *Categories) []*DayFocus {
+	type day struct {
+		focus    *DayFocus
+		weighted float64
+	}
+	days := make(map[int]*day)
+	all, switched := stretches(stream)
+	for i, s := range all {
+		k := civilDay(s.Start)
+		d, exists := days[k]
+		if !exists {
+			d = &day{focus: &DayFocus{Date: civilDate(k)}}
+			days[k] = d
+		}
+		dur := s.Duration()
+		d.focus.Active += dur
+		if switched[i] {
+			d.focus.Switches++
+		}
+		weight := cats.Weight(cats.categorizeApp(s.App))
+		d.weighted += weight * dur.Seconds() * math.Min(1, dur.Seconds()/FocusStretch.Seconds())
+	}
+
+	list := make([]*DayFocus, 0, len(days))
+	for _, d := range days {
+		if d.focus.Active <= 0 {
+			continue
+		}
+		f := d.weighted / d.focus.Active.Seconds()
+		rate := float64(d.focus.Switches) / d.focus.Active.Hours()
+		d.focus.Score = int(math.Round(100 * f / (1 + rate/FocusSwitchRate)))
+		list = append(list, d.focus)
+	}
+	sort.Slice(list, func(a, b int) bool { return list[a].Date.Before(list[b].Date) })
+	return list
+}
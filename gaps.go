@@ -0,0 +1,36 @@
+package thyme
+
+import (
+	"sort"
+	"time"
+)
+
+// Gap is a period during which no snapshots were recorded, e.g.
+// because the computer was asleep or thyme wasn't running.
+type Gap struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Duration returns the length of the gap.
+func (g *Gap) Duration() time.Duration {
+	return g.End.Sub(g.Start)
+}
+
+// Gaps returns, in chronological order, the periods between
+// consecutive snapshots of stream that are further apart than
+// interval, the expected time between snapshots. Snapshots merged
+// with later ones count as recorded until their EndTime.
+func Gaps(stream *Stream, interval time.Duration) []*Gap {
+	snaps := make([]*Snapshot, len(stream.Snapshots))
+	copy(snaps, stream.Snapshots)
+	sort.Slice(snaps, func(a, b int) bool { return snaps[a].Time.Before(snaps[b].Time) })
+
+	var gaps []*Gap
+	for i := 1; i < len(snaps); i++ {
+		if end := snaps[i-1].End(); snaps[i].Time.Sub(end) > interval {
+			gaps = append(gaps, &Gap{Start: end, End: snaps[i].Time})
+		}
+	}
+	return gaps
+}
@@ -0,0 +1,168 @@
+package thyme
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// Goal is a daily target for the active time spent in applications of
+// a category: at least Min, at most Max, or both. A zero Min or Max
+// sets no bound.
+type Goal struct {
+	Category string
+	Min, Max time.Duration
+}
+
+// String describes the goal, e.g. "Development: at least 2h00m".
+func (g *Goal) String() string {
+	switch {
+	case g.Min > 0 && g.Max > 0:
+		return fmt.Sprintf("%s: between %s and %s", g.Category, hoursMinutes(g.Min), hoursMinutes(g.Max))
+	case g.Max > 0:
+		return fmt.Sprintf("%s: at most %s", g.Category, hoursMinutes(g.Max))
+	default:
+		return fmt.Sprintf("%s: at least %s", g.Category, hoursMinutes(g.Min))
+	}
+}
+
+// Met reports whether active time spent in the goal's category in a
+// day meets the goal.
+func (g *Goal) Met(active time.Duration) bool {
+	return (g.Min <= 0 || active >= g.Min) && (g.Max <= 0 || active <= g.Max)
+}
+
+// LoadGoals reads goals from the JSON file at path, which maps
+// categories (see LoadCategories) to their minimum and maximum daily
+// active time, written as Go durations, e.g.
+//
+//	{"Development": {"min": "2h"}, "Social": {"max": "30m"}}
+//
+// A missing file yields no goals rather than an error.
+func LoadGoals(path string) ([]*Goal, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var raw map[string]struct {
+		Min string `json:"min"`
+		Max string `json:"max"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("could not parse goals file %s: %s", path, err)
+	}
+
+	var goals []*Goal
+	for category, bounds := range raw {
+		g := &Goal{Category: category}
+		for _, b := range []struct {
+			name, value string
+			d           *time.Duration
+		}{{"min", bounds.Min, &g.Min}, {"max", bounds.Max, &g.Max}} {
+			if b.value == "" {
+				continue
+			}
+			if *b.d, err = time.ParseDuration(b.value); err != nil {
+				return nil, fmt.Errorf("invalid %s %q for goal %q in %s: %s", b.name, b.value, category, path, err)
+			}
+		}
+		if g.Min <= 0 && g.Max <= 0 {
+			return nil, fmt.Errorf("goal %q in %s has neither a min nor a max", category, path)
+		}
+		if g.Max > 0 && g.Max < g.Min {
+			return nil, fmt.Errorf("goal %q in %s has a max shorter than its min", category, path)
+		}
+		goals = append(goals, g)
+	}
+	sort.Slice(goals, func(a, b int) bool { return goals[a].Category < goals[b].Category })
+	return goals, nil
+}
+
+// GoalProgress is how a goal was met over the days of a stream.
+type GoalProgress struct {
+	Goal *Goal
+
+	// Days are the days from the first to the last of the stream, in
+	// chronological order, including those without activity.
+	Days []*GoalDay
+
+	// Streak is the number of consecutive days, up to the last one,
+	// on which the goal was met. Today doesn't count, as whether it
+	// is met can still change.
+	Streak int
+}
+
+// GoalDay is the progress towards a goal during a day.
+type GoalDay struct {
+	// Date is the day, as midnight UTC.
+	Date time.Time
+
+	// Active is the active time spent in the goal's category.
+	Active time.Duration
+
+	// Met is whether the goal was met.
+	Met bool
+}
+
+// Progress returns the share of the goal's bound reached by the
+// active time of the day, between 0 and 1: of the minimum if the goal
+// has one, and of the maximum otherwise.
+func (d *GoalDay) Progress(g *Goal) float64 {
+	bound := g.Min
+	if bound <= 0 {
+		bound = g.Max
+	}
+	return min(1, float64(d.Active)/float64(bound))
+}
+
+// NewGoalProgress returns the progress towards each of goals during
+// each day of stream, which must be in chronological order, with cats
+// determining the category of windows.
+func NewGoalProgress(stream *Stream, cats *Categories, goals []*Goal) []*GoalProgress {
+	daily := make(map[int]map[string]time.Duration)
+	first, last := 0, -1
+	durations := sampleDurations(stream)
+	for i, snap := range stream.Snapshots {
+		win := snap.ActiveWindow()
+		if win == nil {
+			continue
+		}
+		day := civilDay(snap.Time)
+		if last < first {
+			first, last = day, day
+		}
+		first, last = min(first, day), max(last, day)
+		if daily[day] == nil {
+			daily[day] = make(map[string]time.Duration)
+		}
+		daily[day][cats.Categorize(win)] += durations[i]
+	}
+
+	today := -1
+	if n := len(stream.Snapshots); n > 0 {
+		today = civilDay(time.Now().In(stream.Snapshots[n-1].Time.Location()))
+	}
+	list := make([]*GoalProgress, 0, len(goals))
+	for _, g := range goals {
+		p := &GoalProgress{Goal: g}
+		for day := first; day <= last; day++ {
+			active := daily[day][g.Category]
+			met := g.Met(active)
+			p.Days = append(p.Days, &GoalDay{Date: civilDate(day), Active: active, Met: met})
+			if day == today {
+				continue
+			}
+			if met {
+				p.Streak++
+			} else {
+				p.Streak = 0
+			}
+		}
+		list = append(list, p)
+	}
+	return list
+}
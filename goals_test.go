@@ -0,0 +1,82 @@
+package thyme
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGoalStreak(t *testing.T) {
+	now := time.Now()
+	// day returns a snapshot of the editor active for active, days
+	// days after today.
+	day := func(days int, active time.Duration) *Snapshot {
+		start := now.AddDate(0, 0, days)
+		return &Snapshot{
+			Time:     start,
+			EndTime:  start.Add(active),
+			Interval: time.Minute,
+			Windows:  []*Window{{ID: 1, Name: "main.go - Code"}},
+			Active:   1,
+			Visible:  []int64{1},
+		}
+	}
+	goal := &Goal{Category: Uncategorized, Min: 2 * time.Hour}
+	for _, tt := range []struct {
+		name   string
+		snaps  []*Snapshot
+		streak int
+	}{
+		{
+			name:   "met every day",
+			snaps:  []*Snapshot{day(-3, 3*time.Hour), day(-2, 3*time.Hour), day(-1, 3*time.Hour)},
+			streak: 3,
+		},
+		{
+			name:   "missed yesterday",
+			snaps:  []*Snapshot{day(-3, 3*time.Hour), day(-2, 3*time.Hour), day(-1, time.Hour)},
+			streak: 0,
+		},
+		{
+			name:   "not yet met today",
+			snaps:  []*Snapshot{day(-2, 3*time.Hour), day(-1, 3*time.Hour), day(0, 0)},
+			streak: 2,
+		},
+		{
+			name:   "already met today",
+			snaps:  []*Snapshot{day(-2, 3*time.Hour), day(-1, 3*time.Hour), day(0, 3*time.Hour)},
+			streak: 2,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			progress := NewGoalProgress(&Stream{Snapshots: tt.snaps}, nil, []*Goal{goal})
+			if got := progress[0].Streak; got != tt.streak {
+				t.Errorf("streak of %d days, want %d", got, tt.streak)
+			}
+			if got := len(progress[0].Days); got != len(tt.snaps) {
+				t.Errorf("%d days, want %d", got, len(tt.snaps))
+			}
+		})
+	}
+}
+
+// TestWriteStatsEscapesGoals checks that goals, whose categories are
+// named in ~/.thyme/categories.json, are escaped on the stats page.
+func TestWriteStatsEscapesGoals(t *testing.T) {
+	goal := &Goal{Category: `<b>R&D</b>`, Min: time.Hour}
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	stream := &Stream{Interval: time.Minute, Snapshots: []*Snapshot{
+		{Time: start, Windows: []*Window{{ID: 1, Name: "main.go - Code"}}, Active: 1, Visible: []int64{1}},
+	}}
+	var b strings.Builder
+	if err := WriteStats(&b, stream, StatsOptions{Goals: []*Goal{goal}}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), goal.Category) {
+		t.Errorf("the page holds the category %s of the goal unescaped", goal.Category)
+	}
+	if !strings.Contains(b.String(), template.HTMLEscapeString(goal.String())) {
+		t.Errorf("the page doesn't show the goal %s", goal)
+	}
+}
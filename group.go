@@ -0,0 +1,245 @@
+package thyme
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NoProject is the project of snapshots taken while no project was
+// set, when grouping by project.
+const NoProject = "(no project)"
+
+// InMeeting and NotInMeeting are the groups of snapshots taken during
+// meetings and outside them, when grouping by meeting.
+const (
+	InMeeting    = "In a meeting"
+	NotInMeeting = "Not in a meeting"
+)
+
+// Grouping is a way of grouping the time spent in windows, e.g. by
+// application or by hour of the day.
+type Grouping struct {
+	// Name selects the grouping, e.g. in `thyme show --group-by`.
+	Name string
+
+	// Label describes a group, e.g. "Application".
+	Label string
+
+	// Chronological groupings list their groups in the order of time
+	// rather than by decreasing active time.
+	Chronological bool
+
+	// group returns the group of the window w of snap, and for
+	// chronological groupings, the position of the group in time.
+	group func(snap *Snapshot, w *Window, cats *Categories) (label string, order int)
+}
+
+// Groupings are the available groupings.
+var Groupings = []*Grouping{
+	{Name: "app", Label: "Application", group: func(snap *Snapshot, w *Window, cats *Categories) (string, int) {
+		return appID(w), 0
+	}},
+	{Name: "title", Label: "Window", group: func(snap *Snapshot, w *Window, cats *Categories) (string, int) {
+		return w.Name, 0
+	}},
+	{Name: "document", Label: "Document", group: func(snap *Snapshot, w *Window, cats *Categories) (string, int) {
+		return documentID(w), 0
+	}},
+	{Name: "category", Label: "Category", group: func(snap *Snapshot, w *Window, cats *Categories) (string, int) {
+		return cats.Categorize(w), 0
+	}},
+	{Name: "project", Label: "Project", group: func(snap *Snapshot, w *Window, cats *Categories) (string, int) {
+		if snap.Project == "" {
+			return NoProject, 0
+		}
+		return snap.Project, 0
+	}},
+	{Name: "meeting", Label: "Meeting", group: func(snap *Snapshot, w *Window, cats *Categories) (string, int) {
+		if snap.InMeeting {
+			return InMeeting, 0
+		}
+		return NotInMeeting, 0
+	}},
+	{Name: "input", Label: "Input", group: func(snap *Snapshot, w *Window, cats *Categories) (string, int) {
+		if snap.InputActive() {
+			return InputActive, 0
+		}
+		return InputPassive, 0
+	}},
+	{Name: "desktop", Label: "Desktop", group: func(snap *Snapshot, w *Window, cats *Categories) (string, int) {
+		return w.DesktopLabel(), 0
+	}},
+	{Name: "hour", Label: "Hour", Chronological: true, group: func(snap *Snapshot, w *Window, cats *Categories) (string, int) {
+		h := snap.Time.Hour()
+		return hourLabel(h), h
+	}},
+	{Name: "day", Label: "Day", Chronological: true, group: func(snap *Snapshot, w *Window, cats *Categories) (string, int) {
+		return snap.Time.Format("Mon Jan 2, 2006"), civilDay(snap.Time)
+	}},
+	{Name: "weekday", Label: "Weekday", Chronological: true, group: func(snap *Snapshot, w *Window, cats *Categories) (string, int) {
+		// Weeks start on Monday.
+		return snap.Time.Weekday().String(), (int(snap.Time.Weekday()) + 6) % 7
+	}},
+}
+
+// documentID labels the document of the window w with its
+// application, e.g. "Visual Studio Code: main.go", so that the time
+// spent in an application is broken down by document. Windows without
+// a document are labeled with their application alone.
+func documentID(w *Window) string {
+	if doc := w.Info().Document; doc != "" {
+		return appID(w) + ": " + doc
+	}
+	return appID(w)
+}
+
+// hourLabels are the labels of the hours of the day, made once rather
+// than for every snapshot.
+var hourLabels = func() (labels [24]string) {
+	for h := range labels {
+		labels[h] = fmt.Sprintf("%02d:00", h)
+	}
+	return labels
+}()
+
+// hourLabel labels the hour of the day h, e.g. "09:00".
+func hourLabel(h int) string {
+	return hourLabels[h]
+}
+
+// LookupGrouping returns the grouping called name.
+func LookupGrouping(name string) (*Grouping, error) {
+	var names []string
+	for _, g := range Groupings {
+		if g.Name == name {
+			return g, nil
+		}
+		names = append(names, g.Name)
+	}
+	return nil, fmt.Errorf("unknown grouping %q (expected one of %s)", name, strings.Join(names, ", "))
+}
+
+// Totals returns the time spent in each group of the windows of stream,
+// which must be in chronological order, with cats determining the
+// category of windows. A window that was active, visible or open
+// during a snapshot counts for as long as the snapshot stands for, as
+// in Sessions, and for as many samples (see sampleCounts). Windows of
+// the same group count once per snapshot, except that the active time
+// of a snapshot is split evenly among its active windows (see
+// Snapshot.ActiveWindows). The totals are ordered by decreasing active
+// time, or in the order of time for chronological groupings.
+//
+// They are computed by Aggregate, with all the snapshots of stream
+// counted, locked ones included, so that callers filter stream as they
+// see fit.
+func (g *Grouping) Totals(stream *Stream, cats *Categories) []*Total {
+	return Aggregate(stream, AggregateOptions{Group: g, Categories: cats, IncludeLocked: true}).Totals
+}
+
+// totals is like Totals, but with durations saying how long each
+// snapshot of stream stands for.
+func (g *Grouping) totals(stream *Stream, cats *Categories, durations []time.Duration) []*Total {
+	totals := make(map[string]*Total)
+	orders := make(map[string]int)
+	total := func(snap *Snapshot, w *Window) *Total {
+		label, order := g.group(snap, w, cats)
+		if t, exists := totals[label]; exists {
+			return t
+		}
+		t := &Total{Label: label}
+		totals[label] = t
+		orders[label] = order
+		return t
+	}
+
+	counts := sampleCounts(stream)
+	for i, snap := range stream.Snapshots {
+		d, n := durations[i].Seconds(), counts[i]
+		windows := make(map[int64]*Window)
+		for _, win := range snap.Windows {
+			windows[win.ID] = win
+		}
+		// The time of the snapshot is split evenly among its active
+		// windows, and a group counts the samples of the snapshot
+		// however many of them it has.
+		ids := snap.activeIDs()
+		active := make(map[*Total]bool, len(ids))
+		for _, id := range ids {
+			if win := windows[id]; win != nil {
+				t := total(snap, win)
+				t.ActiveSeconds += d / float64(len(ids))
+				active[t] = true
+			}
+		}
+		for t := range active {
+			t.ActiveSamples += n
+		}
+		visible := make(map[*Total]bool)
+		for _, v := range snap.Visible {
+			if win := windows[v]; win != nil {
+				visible[total(snap, win)] = true
+			}
+		}
+		for t := range visible {
+			t.VisibleSeconds += d
+			t.VisibleSamples += n
+		}
+		open := make(map[*Total]bool)
+		for _, win := range snap.Windows {
+			open[total(snap, win)] = true
+		}
+		for t := range open {
+			t.OpenSeconds += d
+			t.OpenSamples += n
+		}
+	}
+
+	list := make([]*Total, 0, len(totals))
+	for _, t := range totals {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(a, b int) bool {
+		if g.Chronological {
+			return orders[list[a].Label] < orders[list[b].Label]
+		}
+		if list[a].ActiveSeconds != list[b].ActiveSeconds {
+			return list[a].ActiveSeconds > list[b].ActiveSeconds
+		}
+		return list[a].Label < list[b].Label
+	})
+	return list
+}
+
+// NewGroupChart returns a bar chart of the active time, in minutes, of
+// each group of the windows of stream.
+func NewGroupChart(stream *Stream, g *Grouping, cats *Categories) *BarChart {
+	chart := NewBarChart("Group", g.Label, "Minutes", "Active time by "+strings.ToLower(g.Label))
+	chart.Apps = g.Name == "app"
+	for _, t := range g.Totals(stream, cats) {
+		if t.ActiveSamples == 0 {
+			continue
+		}
+		chart.Plus(t.Label, int((time.Duration(t.ActiveSeconds * float64(time.Second))).Round(time.Minute).Minutes()))
+		if g.Chronological {
+			chart.Order = append(chart.Order, t.Label)
+		}
+	}
+	return chart
+}
+
+// NewHourChart returns a bar chart of the active time in each hour of
+// the day, summed over all the days of stream. Hours are those of the
+// location of the snapshot times (see Stream.In), and all 24 of them
+// have a bar.
+func NewHourChart(stream *Stream) *BarChart {
+	g, _ := LookupGrouping("hour")
+	chart := NewGroupChart(stream, g, nil)
+	chart.ID, chart.Title = "Hours", "Active time by hour of the day"
+	chart.Order = nil
+	for h := 0; h < 24; h++ {
+		chart.Order = append(chart.Order, hourLabel(h))
+	}
+	return chart
+}
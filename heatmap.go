@@ -0,0 +1,290 @@
+package thyme
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Heatmap is a grid of cells, each colored by how much time the user
+// was active during the period it stands for.
+type Heatmap struct {
+	ID    string
+	Title string
+
+	// RowLabels and ColLabels label the rows and columns of the
+	// grid. A column label may be empty.
+	RowLabels []string
+	ColLabels []string
+
+	// Cells are the cells of the grid during which the user was
+	// active. The others are empty.
+	Cells []*HeatmapCell
+}
+
+// HeatmapCell is one cell of a Heatmap.
+type HeatmapCell struct {
+	Row, Col int
+
+	// Label describes the period the cell stands for.
+	Label string
+
+	// Active is how long the user was active during the period, and
+	// TopApp the application they were active in the longest.
+	Active time.Duration
+	TopApp string
+
+	apps map[string]time.Duration
+}
+
+// add attributes d of activity in app to the cell.
+func (c *HeatmapCell) add(app string, d time.Duration) {
+	c.Active += d
+	c.apps[app] += d
+	if c.TopApp == "" || c.apps[app] > c.apps[c.TopApp] || (c.apps[app] == c.apps[c.TopApp] && app < c.TopApp) {
+		c.TopApp = app
+	}
+}
+
+// heatmapGrid accumulates the cells of a Heatmap.
+type heatmapGrid map[[2]int]*HeatmapCell
+
+func (g heatmapGrid) cell(row, col int, label string) *HeatmapCell {
+	k := [2]int{row, col}
+	if c, exists := g[k]; exists {
+		return c
+	}
+	c := &HeatmapCell{Row: row, Col: col, Label: label, apps: make(map[string]time.Duration)}
+	g[k] = c
+	return c
+}
+
+func (g heatmapGrid) cells() []*HeatmapCell {
+	cells := make([]*HeatmapCell, 0, len(g))
+	for _, c := range g {
+		cells = append(cells, c)
+	}
+	sort.Slice(cells, func(a, b int) bool {
+		if cells[a].Row != cells[b].Row {
+			return cells[a].Row < cells[b].Row
+		}
+		return cells[a].Col < cells[b].Col
+	})
+	return cells
+}
+
+var weekdayLabels = []string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"}
+
+// NewCalendarHeatmap returns a heatmap with one cell per day of the
+// period covered by stream, laid out like a calendar: one column per
+// week and one row per day of the week.
+func NewCalendarHeatmap(stream *Stream) *Heatmap {
+	hm := &Heatmap{ID: "Calendar", Title: "Active time by day", RowLabels: weekdayLabels}
+	if len(stream.Snapshots) == 0 {
+		return hm
+	}
+	first := stream.Snapshots[0].Time
+	for _, snap := range stream.Snapshots {
+		if snap.Time.Before(first) {
+			first = snap.Time
+		}
+	}
+	// Weeks start on the Sunday on or before the first day.
+	start := civilDay(first) - int(first.Weekday())
+
+	grid := make(heatmapGrid)
+	weeks := 0
+	durations := sampleDurations(stream)
+	for i, snap := range stream.Snapshots {
+		win := snap.ActiveWindow()
+		if win == nil {
+			continue
+		}
+		col := (civilDay(snap.Time) - start) / 7
+		if col+1 > weeks {
+			weeks = col + 1
+		}
+		grid.cell(int(snap.Time.Weekday()), col, snap.Time.Format("Mon Jan 2, 2006")).add(appID(win), durations[i])
+	}
+	hm.Cells = grid.cells()
+
+	// Label the first week and those in which a month starts.
+	hm.ColLabels = make([]string, weeks)
+	for col := range hm.ColLabels {
+		weekStart, weekEnd := civilDate(start+7*col), civilDate(start+7*col+6)
+		if col == 0 {
+			hm.ColLabels[col] = weekStart.Format("Jan")
+		} else if weekEnd.Day() <= 7 {
+			hm.ColLabels[col] = weekEnd.Format("Jan")
+		}
+	}
+	return hm
+}
+
+// NewWeekHeatmap returns a heatmap with one cell per hour of the week,
+// showing when the user is most often active.
+func NewWeekHeatmap(stream *Stream) *Heatmap {
+	hm := &Heatmap{ID: "Week", Title: "Active time by hour of the week", RowLabels: weekdayLabels}
+	for h := 0; h < 24; h++ {
+		hm.ColLabels = append(hm.ColLabels, fmt.Sprintf("%02d", h))
+	}
+	grid := make(heatmapGrid)
+	durations := sampleDurations(stream)
+	for i, snap := range stream.Snapshots {
+		win := snap.ActiveWindow()
+		if win == nil {
+			continue
+		}
+		day, hour := snap.Time.Weekday(), snap.Time.Hour()
+		label := fmt.Sprintf("%ss, %02d:00–%02d:00", day, hour, (hour+1)%24)
+		grid.cell(int(day), hour, label).add(appID(win), durations[i])
+	}
+	hm.Cells = grid.cells()
+	return hm
+}
+
+// NewCategoryHourHeatmap returns a heatmap with one row per category
+// of cats, by decreasing active time, and one column per hour of the
+// day, showing when the user is active in each category.
+func NewCategoryHourHeatmap(stream *Stream, cats *Categories) *Heatmap {
+	hm := &Heatmap{ID: "CategoryHours", Title: "Active time by category and hour of the day"}
+	for h := 0; h < 24; h++ {
+		hm.ColLabels = append(hm.ColLabels, fmt.Sprintf("%02d", h))
+	}
+	g, _ := LookupGrouping("category")
+	rows := make(map[string]int)
+	for _, t := range g.Totals(stream, cats) {
+		if t.ActiveSamples > 0 {
+			rows[t.Label] = len(hm.RowLabels)
+			hm.RowLabels = append(hm.RowLabels, t.Label)
+		}
+	}
+	grid := make(heatmapGrid)
+	durations := sampleDurations(stream)
+	for i, snap := range stream.Snapshots {
+		win := snap.ActiveWindow()
+		if win == nil {
+			continue
+		}
+		category, hour := cats.Categorize(win), snap.Time.Hour()
+		label := fmt.Sprintf("%s, %02d:00–%02d:00", category, hour, (hour+1)%24)
+		grid.cell(rows[category], hour, label).add(appID(win), durations[i])
+	}
+	hm.Cells = grid.cells()
+	return hm
+}
+
+// civilDay returns the number of days between the Unix epoch and the
+// date of t in its own location, regardless of daylight saving time.
+func civilDay(t time.Time) int {
+	y, m, d := t.Date()
+	return int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// civilDate returns the date that is day days after the Unix epoch,
+// as midnight UTC.
+func civilDate(day int) time.Time {
+	return time.Unix(int64(day)*86400, 0).UTC()
+}
+
+// sampleDurations returns how long each snapshot of stream, which must
+// be in chronological order, stands for: its own duration, if it was
+// merged with later snapshots, plus the time until the next one. The
+// latter is capped at the interval the snapshot was taken at, or at
+// the SamplingInterval of the stream if it doesn't say, so that
+// periods without snapshots, e.g. while the computer was asleep, don't
+// count.
+func sampleDurations(stream *Stream) []time.Duration {
+	fallback, _ := stream.SamplingInterval()
+	snaps := stream.Snapshots
+	durations := make([]time.Duration, len(snaps))
+	for i, snap := range snaps {
+		interval := snap.Interval
+		if interval <= 0 {
+			interval = fallback
+		}
+		step := interval
+		if i+1 < len(snaps) {
+			step = min(max(snaps[i+1].Time.Sub(snap.End()), 0), interval)
+		}
+		durations[i] = snap.End().Sub(snap.Time) + step
+	}
+	return durations
+}
+
+// sampleCounts returns how many samples each snapshot of stream stands
+// for: one, plus those merged into it when it was stored, going by how
+// long it lasted and the interval it was taken at. Charts and totals
+// that count samples weigh snapshots by it, so that a snapshot merged
+// with an hour of identical ones counts for as many.
+func sampleCounts(stream *Stream) []int {
+	fallback, _ := stream.SamplingInterval()
+	counts := make([]int, len(stream.Snapshots))
+	for i, snap := range stream.Snapshots {
+		counts[i] = 1
+		interval := snap.Interval
+		if interval <= 0 {
+			interval = fallback
+		}
+		if span := snap.End().Sub(snap.Time); span > 0 && interval > 0 {
+			counts[i] += int(math.Round(float64(span) / float64(interval)))
+		}
+	}
+	return counts
+}
+
+// SamplingInterval returns the time between the snapshots of s that
+// thyme track was configured with: the Interval of the stream if it is
+// set, or else the one most of its snapshots were taken at. Streams
+// recorded before intervals were stored don't say; the interval is
+// then estimated as the median time between snapshots, and estimated
+// is true.
+func (s *Stream) SamplingInterval() (interval time.Duration, estimated bool) {
+	if s.Interval > 0 {
+		return s.Interval, false
+	}
+	counts := make(map[time.Duration]int)
+	for _, snap := range s.Snapshots {
+		if snap.Interval > 0 {
+			counts[snap.Interval]++
+		}
+	}
+	for d, n := range counts {
+		if n > counts[interval] || n == counts[interval] && d < interval {
+			interval = d
+		}
+	}
+	if interval > 0 {
+		return interval, false
+	}
+
+	snaps := s.Snapshots
+	var steps []time.Duration
+	for i := 1; i < len(snaps); i++ {
+		if d := snaps[i].Time.Sub(snaps[i-1].End()); d > 0 {
+			steps = append(steps, d)
+		}
+	}
+	if len(steps) == 0 {
+		return 0, true
+	}
+	sort.Slice(steps, func(a, b int) bool { return steps[a] < steps[b] })
+	return steps[len(steps)/2], true
+}
+
+// intervalDurations is like sampleDurations, but caps the time until
+// the next snapshot at interval rather than at the median time between
+// snapshots.
+func intervalDurations(stream *Stream, interval time.Duration) []time.Duration {
+	snaps := stream.Snapshots
+	durations := make([]time.Duration, len(snaps))
+	for i, snap := range snaps {
+		step := interval
+		if i+1 < len(snaps) {
+			step = min(max(snaps[i+1].Time.Sub(snap.End()), 0), interval)
+		}
+		durations[i] = snap.End().Sub(snap.Time) + step
+	}
+	return durations
+}
@@ -0,0 +1,274 @@
+package thyme
+
+import (
+	"bufio"
+	"encoding/base64"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Icons map application names, as returned by AppID, to their icons as
+// data URIs, e.g. "data:image/png;base64,...", so that the report
+// embeds them. Applications whose icon wasn't found are left out; the
+// report shows their initial on a background of their color instead.
+type Icons map[string]string
+
+// iconFinders find the icon of an application on each system they
+// support, given its name and the names of the processes its windows
+// belonged to, and return it with its media type, or nil if there is
+// none. Systems add theirs in init functions.
+var iconFinders = map[string]func(app string, processes []string) ([]byte, string, error){
+	"linux": desktopIcon,
+}
+
+// maxIconSize is the size, in bytes, of the largest icon embedded in a
+// report.
+const maxIconSize = 256 << 10
+
+// iconMissRetry is how long an application whose icon wasn't found
+// goes before it is looked for again, e.g. in case it was installed
+// since.
+const iconMissRetry = 7 * 24 * time.Hour
+
+// IconResolver finds the icons of applications, keeping them in a
+// cache directory so that they needn't be looked for on every report.
+type IconResolver struct {
+	// CacheDir is the directory icons are cached in, e.g.
+	// ~/.thyme/icons. Icons aren't cached if it is empty.
+	CacheDir string
+
+	find func(app string, processes []string) ([]byte, string, error)
+}
+
+// NewIconResolver returns an IconResolver that caches icons in
+// cacheDir. On Linux, icons are those of the .desktop files of
+// applications, looked up in the freedesktop icon theme; on macOS,
+// those of their application bundles, converted to PNG with sips.
+// Elsewhere, no icon is found.
+func NewIconResolver(cacheDir string) *IconResolver {
+	return &IconResolver{CacheDir: cacheDir, find: iconFinders[runtime.GOOS]}
+}
+
+// Icons returns the icons of apps, which map the names of applications
+// to the processes their windows belonged to. Failures to find or
+// cache an icon are logged at the debug level, and the application
+// left out.
+func (r *IconResolver) Icons(apps map[string][]string) Icons {
+	icons := make(Icons)
+	for app, processes := range apps {
+		data, mediaType := r.icon(app, processes)
+		if data != nil {
+			icons[app] = "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data)
+		}
+	}
+	return icons
+}
+
+// iconExtensions are the extensions of cached icons, with their media
+// types.
+var iconExtensions = map[string]string{
+	".png": "image/png",
+	".svg": "image/svg+xml",
+}
+
+// icon returns the icon of app, from the cache if it is there, and its
+// media type.
+func (r *IconResolver) icon(app string, processes []string) ([]byte, string) {
+	var base string
+	if r.CacheDir != "" {
+		base = filepath.Join(r.CacheDir, url.PathEscape(app))
+		for ext, mediaType := range iconExtensions {
+			if data, err := os.ReadFile(base + ext); err == nil {
+				return data, mediaType
+			}
+		}
+		if fi, err := os.Stat(base + ".none"); err == nil && time.Since(fi.ModTime()) < iconMissRetry {
+			return nil, ""
+		}
+	}
+	if r.find == nil {
+		return nil, ""
+	}
+	data, mediaType, err := r.find(app, processes)
+	if err != nil {
+		slog.Debug("could not find icon", "app", app, "error", err)
+		return nil, ""
+	}
+	if len(data) > maxIconSize {
+		slog.Debug("icon too large to embed", "app", app, "size", len(data))
+		data = nil
+	}
+	if base != "" {
+		if err := r.store(base, data, mediaType); err != nil {
+			slog.Debug("could not cache icon", "app", app, "error", err)
+		}
+	}
+	return data, mediaType
+}
+
+// store caches data, the icon whose media type is mediaType, at base
+// plus its extension, or records that there is none if data is nil.
+func (r *IconResolver) store(base string, data []byte, mediaType string) error {
+	if err := os.MkdirAll(r.CacheDir, 0755); err != nil {
+		return err
+	}
+	if data == nil {
+		return os.WriteFile(base+".none", nil, 0644)
+	}
+	for ext, t := range iconExtensions {
+		if t == mediaType {
+			os.Remove(base + ".none")
+			return os.WriteFile(base+ext, data, 0644)
+		}
+	}
+	return nil
+}
+
+// desktopEntry is what matters for icons in a freedesktop .desktop
+// file.
+type desktopEntry struct {
+	name, exec, wmClass, icon string
+}
+
+// xdgDataDirs returns the directories applications and icons are
+// installed in, as the freedesktop base directory specification
+// defines them, the user's first.
+func xdgDataDirs() []string {
+	var dirs []string
+	if home := os.Getenv("XDG_DATA_HOME"); home != "" {
+		dirs = append(dirs, home)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".local", "share"), filepath.Join(home, ".local", "share", "flatpak", "exports", "share"))
+	}
+	system := os.Getenv("XDG_DATA_DIRS")
+	if system == "" {
+		system = "/usr/local/share:/usr/share"
+	}
+	dirs = append(dirs, filepath.SplitList(system)...)
+	return append(dirs, "/var/lib/flatpak/exports/share", "/var/lib/snapd/desktop")
+}
+
+// desktopEntries are the .desktop files of the installed applications,
+// read once.
+var desktopEntries = sync.OnceValue(func() []*desktopEntry {
+	var entries []*desktopEntry
+	for _, dir := range xdgDataDirs() {
+		paths, _ := filepath.Glob(filepath.Join(dir, "applications", "*.desktop"))
+		for _, path := range paths {
+			if e := readDesktopEntry(path); e != nil && e.icon != "" {
+				entries = append(entries, e)
+			}
+		}
+	}
+	return entries
+})
+
+// readDesktopEntry reads the [Desktop Entry] group of the .desktop
+// file at path, or returns nil if it can't be read.
+func readDesktopEntry(path string) *desktopEntry {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	e := &desktopEntry{}
+	var inEntry bool
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inEntry = line == "[Desktop Entry]"
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !inEntry || !ok {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "Name":
+			e.name = strings.TrimSpace(value)
+		case "Exec":
+			if fields := strings.Fields(value); len(fields) > 0 {
+				e.exec = filepath.Base(strings.Trim(fields[0], `"`))
+			}
+		case "StartupWMClass":
+			e.wmClass = strings.TrimSpace(value)
+		case "Icon":
+			e.icon = strings.TrimSpace(value)
+		}
+	}
+	return e
+}
+
+// desktopIcon returns the icon of the .desktop file whose name is app,
+// or otherwise whose window class or executable is one of processes.
+func desktopIcon(app string, processes []string) ([]byte, string, error) {
+	var match *desktopEntry
+	for _, e := range desktopEntries() {
+		if strings.EqualFold(e.name, app) {
+			match = e
+			break
+		}
+		for _, p := range processes {
+			if match == nil && (strings.EqualFold(e.wmClass, p) || strings.EqualFold(e.exec, p)) {
+				match = e
+			}
+		}
+	}
+	if match == nil {
+		return nil, "", nil
+	}
+	path := themeIcon(match.icon)
+	if path == "" {
+		return nil, "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, iconExtensions[filepath.Ext(path)], nil
+}
+
+// iconSizes are the directories of the hicolor icon theme icons are
+// looked for in, in order of preference: sizes that look sharp next to
+// a label first, then the scalable ones.
+var iconSizes = []string{"64x64", "48x48", "128x128", "96x96", "32x32", "256x256", "scalable"}
+
+// themeIcon returns the path of the icon file called icon in the
+// hicolor icon theme, or in /usr/share/pixmaps, or icon itself if it
+// is a path, or "" if there is no such PNG or SVG file.
+func themeIcon(icon string) string {
+	if filepath.IsAbs(icon) {
+		if _, ok := iconExtensions[filepath.Ext(icon)]; ok && fileExists(icon) {
+			return icon
+		}
+		return ""
+	}
+	var candidates []string
+	for _, dir := range xdgDataDirs() {
+		for _, size := range iconSizes {
+			candidates = append(candidates, filepath.Join(dir, "icons", "hicolor", size, "apps", icon))
+		}
+	}
+	candidates = append(candidates, filepath.Join("/usr/share/pixmaps", icon))
+	for _, c := range candidates {
+		for _, ext := range []string{".png", ".svg"} {
+			if fileExists(c + ext) {
+				return c + ext
+			}
+		}
+	}
+	return ""
+}
+
+// fileExists reports whether there is a regular file at path.
+func fileExists(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular()
+}
@@ -0,0 +1,67 @@
+//go:build darwin
+// +build darwin
+
+package thyme
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	iconFinders["darwin"] = bundleIcon
+}
+
+// bundleIcon returns the icon of the application bundle called app, or
+// one of processes, in the usual application folders, converted to a
+// 64-pixel PNG with sips.
+func bundleIcon(app string, processes []string) ([]byte, string, error) {
+	var dirs []string
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, "Applications"))
+	}
+	dirs = append(dirs, "/Applications", "/Applications/Utilities", "/System/Applications", "/System/Applications/Utilities")
+	var bundle string
+	for _, name := range append([]string{app}, processes...) {
+		for _, dir := range dirs {
+			if fi, err := os.Stat(filepath.Join(dir, name+".app")); err == nil && fi.IsDir() {
+				bundle = filepath.Join(dir, name+".app")
+				break
+			}
+		}
+		if bundle != "" {
+			break
+		}
+	}
+	if bundle == "" {
+		return nil, "", nil
+	}
+
+	// Info.plist may be binary; plutil reads both formats.
+	out, err := exec.Command("plutil", "-extract", "CFBundleIconFile", "raw", "-o", "-", filepath.Join(bundle, "Contents", "Info.plist")).Output()
+	if err != nil {
+		// Bundles without CFBundleIconFile, e.g. those using asset
+		// catalogs only, have no icon we can read.
+		return nil, "", nil
+	}
+	icon := strings.TrimSpace(string(out))
+	if filepath.Ext(icon) == "" {
+		icon += ".icns"
+	}
+	tmp, err := os.CreateTemp("", "thyme-icon-*.png")
+	if err != nil {
+		return nil, "", err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	if err := exec.Command("sips", "-s", "format", "png", "-Z", "64", filepath.Join(bundle, "Contents", "Resources", icon), "--out", tmp.Name()).Run(); err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return nil, "", err
+	}
+	return data, "image/png", nil
+}
@@ -0,0 +1,78 @@
+package thyme
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icsTimeFormat is the format of UTC date-times in iCalendar.
+const icsTimeFormat = "20060102T150405Z"
+
+// icsLineLength is the longest a line of iCalendar may be, in bytes,
+// before it must be folded.
+const icsLineLength = 75
+
+// WriteICS writes sessions to w as an iCalendar (RFC 5545) calendar
+// with one event per session, named after the application used most
+// during it. Events have stable UIDs derived from the start of their
+// session, so that importing the calendar again updates them rather
+// than duplicating them.
+func WriteICS(w io.Writer, sessions []Session) error {
+	bw := bufio.NewWriter(w)
+	stamp := time.Now().UTC().Format(icsTimeFormat)
+	writeICSLine(bw, "BEGIN:VCALENDAR")
+	writeICSLine(bw, "VERSION:2.0")
+	writeICSLine(bw, "PRODID:-//thyme//thyme//EN")
+	writeICSLine(bw, "CALSCALE:GREGORIAN")
+	for _, s := range sessions {
+		summary := "Session"
+		if len(s.Apps) > 0 {
+			summary = s.Apps[0]
+		}
+		description := fmt.Sprintf("%s active", s.Duration().Round(time.Second))
+		if len(s.Apps) > 0 {
+			description += " in " + strings.Join(s.Apps, ", ")
+		}
+		writeICSLine(bw, "BEGIN:VEVENT")
+		writeICSLine(bw, fmt.Sprintf("UID:session-%d@thyme", s.Start.Unix()))
+		writeICSLine(bw, "DTSTAMP:"+stamp)
+		writeICSLine(bw, "DTSTART:"+s.Start.UTC().Format(icsTimeFormat))
+		writeICSLine(bw, "DTEND:"+s.End.UTC().Format(icsTimeFormat))
+		writeICSLine(bw, "SUMMARY:"+escapeICSText(summary))
+		writeICSLine(bw, "DESCRIPTION:"+escapeICSText(description))
+		writeICSLine(bw, "TRANSP:TRANSPARENT")
+		writeICSLine(bw, "END:VEVENT")
+	}
+	writeICSLine(bw, "END:VCALENDAR")
+	return bw.Flush()
+}
+
+// escapeICSText escapes s for use as an iCalendar TEXT value.
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// writeICSLine writes line to w followed by CRLF, folding it into
+// lines of at most icsLineLength bytes, each continuation starting
+// with a space. Lines are only folded between characters.
+func writeICSLine(w *bufio.Writer, line string) {
+	limit := icsLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		// The leading space counts towards the length of
+		// continuation lines.
+		limit = icsLineLength - 1
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
@@ -0,0 +1,92 @@
+package thyme
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+)
+
+// Ignorer drops the windows of applications that must not be recorded
+// at all, such as password managers, from snapshots before they are
+// stored. Unlike a Redactor, which keeps the application but hides
+// the title, it leaves no trace of the windows it drops.
+//
+// With Only set, it works the other way around too: only the windows
+// of the applications it lists are kept. The two can be combined, in
+// which case windows are kept if they match Only but not Patterns.
+type Ignorer struct {
+	Patterns []*regexp.Regexp
+
+	// Only, if not empty, lists the applications whose windows are
+	// the only ones kept.
+	Only []*regexp.Regexp
+}
+
+// LoadIgnorer reads the patterns of an Ignorer from the JSON file at
+// path, a list of regexes matched against application names as
+// extracted by Window.Info and against process names, e.g.
+//
+//	["KeePassXC", "^1Password$"]
+//
+// A missing file yields an Ignorer that drops nothing rather than an
+// error.
+func LoadIgnorer(path string) (*Ignorer, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Ignorer{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var raw []string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("could not parse ignore file %s: %s", path, err)
+	}
+	ig := &Ignorer{}
+	for _, pattern := range raw {
+		rx, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q in %s: %s", pattern, path, err)
+		}
+		ig.Patterns = append(ig.Patterns, rx)
+	}
+	return ig, nil
+}
+
+// Ignore removes, in place, the windows of snap whose application or
+// process matches one of the patterns, or, if Only is set, matches
+// none of Only. If the focused window is one of them, snap is left
+// without an active window.
+func (ig *Ignorer) Ignore(snap *Snapshot) {
+	if ig == nil || (len(ig.Patterns) == 0 && len(ig.Only) == 0) {
+		return
+	}
+	var dropped []int64
+	snap.Windows = slices.DeleteFunc(snap.Windows, func(w *Window) bool {
+		if (len(ig.Only) > 0 && !windowMatches(ig.Only, w)) || windowMatches(ig.Patterns, w) {
+			dropped = append(dropped, w.ID)
+			return true
+		}
+		return false
+	})
+	if len(dropped) == 0 {
+		return
+	}
+	snap.Visible = slices.DeleteFunc(snap.Visible, func(id int64) bool { return slices.Contains(dropped, id) })
+	snap.AlsoActive = slices.DeleteFunc(snap.AlsoActive, func(id int64) bool { return slices.Contains(dropped, id) })
+	if slices.Contains(dropped, snap.Active) {
+		snap.Active, snap.AlsoActive = 0, nil
+	}
+}
+
+// windowMatches reports whether the application or process of w
+// matches one of patterns.
+func windowMatches(patterns []*regexp.Regexp, w *Window) bool {
+	for _, rx := range patterns {
+		if rx.MatchString(appID(w)) || (w.Process != "" && rx.MatchString(w.Process)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package thyme
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// inputCounters start counting, on each system they support, the key
+// presses, mouse button presses and scroll steps of the user, and
+// return a function that returns how many there have been since.
+// Which keys were pressed is never read. Systems add theirs in init
+// functions.
+var inputCounters = map[string]func() (func() (uint64, error), error){}
+
+// InputActiveRate is the rate of input events, per minute, from which
+// the user is considered to be typing, clicking or scrolling rather
+// than reading or watching.
+const InputActiveRate = 10
+
+// InputActive and InputPassive are the groups of snapshots taken while
+// the user was and wasn't typing, clicking or scrolling, when grouping
+// by input.
+const (
+	InputActive  = "Typing, clicking or scrolling"
+	InputPassive = "Reading or watching"
+)
+
+// InputRater measures how often the user types, clicks and scrolls
+// between snapshots.
+type InputRater struct {
+	count    func() (uint64, error)
+	last     uint64
+	lastTime time.Time
+}
+
+// NewInputRater starts counting the user's input events. On Linux,
+// they are read from the evdev devices in /dev/input, which requires
+// being in the input group; on macOS, from the event counters of the
+// window server, which requires a build with cgo. Elsewhere, it fails.
+func NewInputRater() (*InputRater, error) {
+	start := inputCounters[runtime.GOOS]
+	if start == nil {
+		return nil, fmt.Errorf("input events can't be counted on %s", runtime.GOOS)
+	}
+	count, err := start()
+	if err != nil {
+		return nil, fmt.Errorf("could not count input events: %s", err)
+	}
+	r := &InputRater{count: count, lastTime: time.Now()}
+	if r.last, err = count(); err != nil {
+		return nil, fmt.Errorf("could not count input events: %s", err)
+	}
+	return r, nil
+}
+
+// Observe sets the InputRate of snap to the rate of input events since
+// the previous snapshot Observe was called with, or since the
+// InputRater was created.
+func (r *InputRater) Observe(snap *Snapshot) error {
+	n, err := r.count()
+	if err != nil {
+		return fmt.Errorf("could not count input events: %s", err)
+	}
+	if minutes := snap.Time.Sub(r.lastTime).Minutes(); minutes > 0 && n >= r.last {
+		snap.InputRate = float64(n-r.last) / minutes
+	}
+	r.last, r.lastTime = n, snap.Time
+	return nil
+}
+
+// InputActive reports whether the user was typing, clicking or
+// scrolling when s was taken, according to its InputRate.
+func (s *Snapshot) InputActive() bool {
+	return s.InputRate >= InputActiveRate
+}
+
+// withInput returns a copy of s with only the snapshots taken while the
+// user was typing, clicking or scrolling.
+func (s *Stream) withInput() *Stream {
+	filtered := *s
+	filtered.Snapshots = make([]*Snapshot, 0, len(s.Snapshots))
+	for _, snap := range s.Snapshots {
+		if snap.InputActive() {
+			filtered.Snapshots = append(filtered.Snapshots, snap)
+		}
+	}
+	return &filtered
+}
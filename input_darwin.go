@@ -0,0 +1,30 @@
+//go:build darwin && cgo
+// +build darwin,cgo
+
+package thyme
+
+/*
+#cgo LDFLAGS: -framework ApplicationServices
+#include <ApplicationServices/ApplicationServices.h>
+
+// thyme_input_events returns how many key presses, mouse button presses
+// and scroll events the window server has seen since it started.
+static unsigned long long thyme_input_events(void) {
+	CGEventType types[] = {
+		kCGEventKeyDown, kCGEventLeftMouseDown, kCGEventRightMouseDown,
+		kCGEventOtherMouseDown, kCGEventScrollWheel,
+	};
+	unsigned long long n = 0;
+	for (size_t i = 0; i < sizeof(types) / sizeof(types[0]); i++) {
+		n += CGEventSourceCounterForEventType(kCGEventSourceStateHIDSystemState, types[i]);
+	}
+	return n;
+}
+*/
+import "C"
+
+func init() {
+	inputCounters["darwin"] = func() (func() (uint64, error), error) {
+		return func() (uint64, error) { return uint64(C.thyme_input_events()), nil }, nil
+	}
+}
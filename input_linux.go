@@ -0,0 +1,86 @@
+//go:build linux
+// +build linux
+
+package thyme
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+func init() {
+	inputCounters["linux"] = evdevCounter
+}
+
+// Types and codes of the evdev events that are counted, from
+// linux/input-event-codes.h.
+const (
+	evKey    = 0x01
+	evRel    = 0x02
+	relWheel = 0x08
+)
+
+// evdevEventSize is the size of a struct input_event: a struct
+// timeval followed by the type, code and value of the event.
+var evdevEventSize = int(unsafe.Sizeof(syscall.Timeval{})) + 8
+
+// evdevCounter counts the presses of keys and buttons, and the scroll
+// steps, read from every evdev device that can be opened, until the
+// program exits. Only the types of events and whether keys went down
+// are read, never which keys they were.
+func evdevCounter() (func() (uint64, error), error) {
+	paths, err := filepath.Glob("/dev/input/event*")
+	if err != nil {
+		return nil, err
+	}
+	var count atomic.Uint64
+	var opened int
+	var openErr error
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			openErr = err
+			continue
+		}
+		opened++
+		go readEvdev(f, &count)
+	}
+	switch {
+	case opened > 0:
+	case openErr != nil && errors.Is(openErr, os.ErrPermission):
+		return nil, fmt.Errorf("%s (add yourself to the input group to let thyme read input devices)", openErr)
+	case openErr != nil:
+		return nil, openErr
+	default:
+		return nil, errors.New("no input device in /dev/input")
+	}
+	return func() (uint64, error) { return count.Load(), nil }, nil
+}
+
+// readEvdev adds the events of the evdev device f that count to count,
+// until f can't be read anymore.
+func readEvdev(f *os.File, count *atomic.Uint64) {
+	defer f.Close()
+	buf := make([]byte, evdevEventSize*64)
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			return
+		}
+		for off := 0; off+evdevEventSize <= n; off += evdevEventSize {
+			ev := buf[off+evdevEventSize-8 : off+evdevEventSize]
+			typ := binary.NativeEndian.Uint16(ev[0:2])
+			code := binary.NativeEndian.Uint16(ev[2:4])
+			value := int32(binary.NativeEndian.Uint32(ev[4:8]))
+			if (typ == evKey && value == 1) || (typ == evRel && code == relWheel) {
+				count.Add(1)
+			}
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package thyme
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IsJSONLinesPath reports whether the file at path holds JSON lines,
+// one snapshot per line, going by its extension: .jsonl, or .jsonl.gz
+// for compressed ones.
+func IsJSONLinesPath(path string) bool {
+	return strings.HasSuffix(path, ".jsonl") || strings.HasSuffix(path, ".jsonl.gz")
+}
+
+// WriteJSONLines writes the snapshots of stream to w as JSON lines,
+// one snapshot per line, which ReadStream reads back.
+func WriteJSONLines(w io.Writer, stream *Stream) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, snap := range stream.Snapshots {
+		if err := enc.Encode(snap); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// readJSONLines decodes the snapshots that follow first, the snapshot
+// at the start of JSON lines, from dec. A last line cut short, as when
+// it is being appended to while it is read, is left out.
+func readJSONLines(first *Snapshot, dec *json.Decoder) (*Stream, error) {
+	stream := &Stream{Snapshots: []*Snapshot{first}}
+	for line := 2; ; line++ {
+		snap := &Snapshot{}
+		switch err := dec.Decode(snap); {
+		case err == nil:
+			stream.Snapshots = append(stream.Snapshots, snap)
+		case err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF):
+			return stream, nil
+		default:
+			return nil, fmt.Errorf("snapshot %d: %s", line, err)
+		}
+	}
+}
+
+// jsonLinesStore is a Store backed by a file of JSON lines, one
+// snapshot per line, which new snapshots are appended to without
+// rewriting the rest of the file.
+type jsonLinesStore struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenJSONLinesStore opens the file of JSON lines at path as a Store,
+// creating it once the first snapshot is appended.
+func OpenJSONLinesStore(path string) (Store, error) {
+	if strings.HasSuffix(path, ".gz") {
+		return nil, fmt.Errorf("can't append to compressed file %s", path)
+	}
+	return &jsonLinesStore{path: path}, nil
+}
+
+// file returns the store's file, opened for appending.
+func (s *jsonLinesStore) file() (*os.File, error) {
+	if s.f == nil {
+		f, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		s.f = f
+	}
+	return s.f, nil
+}
+
+func (s *jsonLinesStore) Append(snap *Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, _, end, err := s.last()
+	if err != nil {
+		return err
+	}
+	if last != nil && last.Time.Equal(snap.Time) {
+		return nil
+	}
+	return s.write(snap, end)
+}
+
+// write writes snap to the file as a line of its own, starting at off,
+// where the file is cut off if it goes further.
+func (s *jsonLinesStore) write(snap *Snapshot, off int64) error {
+	out, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	f, err := s.file()
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	// The file is opened for appending, so the line goes wherever it
+	// ends.
+	if fi.Size() > off {
+		if err := f.Truncate(off); err != nil {
+			return err
+		}
+	}
+	_, err = f.Write(append(out, '\n'))
+	return err
+}
+
+func (s *jsonLinesStore) Last() (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last, _, _, err := s.last()
+	return last, err
+}
+
+// jsonLinesChunk is how much of the end of the file is read at a time
+// while looking for the start of its last line.
+const jsonLinesChunk = 64 << 10
+
+// last returns the last snapshot in the file, or nil if it is empty,
+// the offset of the line it is on, and that of the end of that line. A
+// last line cut short, e.g. by a crash while it was written, is left
+// out, so that the next snapshot is written over it.
+func (s *jsonLinesStore) last() (snap *Snapshot, start, end int64, err error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, 0, 0, nil
+	} else if err != nil {
+		return nil, 0, 0, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	// tail holds the end of the file, from off, up to end once the end
+	// of the last complete line has been found.
+	var tail []byte
+	end = -1
+	for off := fi.Size(); off > 0; {
+		n := min(int64(jsonLinesChunk), off)
+		off -= n
+		chunk := make([]byte, n)
+		if _, err := f.ReadAt(chunk, off); err != nil {
+			return nil, 0, 0, err
+		}
+		tail = append(chunk, tail...)
+		if end < 0 {
+			i := bytes.LastIndexByte(tail, '\n')
+			if i < 0 {
+				continue
+			}
+			end = off + int64(i) + 1
+			tail = tail[:i]
+		}
+		if i := bytes.LastIndexByte(tail, '\n'); i >= 0 {
+			return decodeLastLine(tail[i+1:], off+int64(i)+1, end)
+		} else if off == 0 {
+			return decodeLastLine(tail, 0, end)
+		}
+	}
+	return nil, 0, 0, nil
+}
+
+// decodeLastLine decodes line, the last line of a file of JSON lines,
+// which goes from start to end.
+func decodeLastLine(line []byte, start, end int64) (*Snapshot, int64, int64, error) {
+	snap := &Snapshot{}
+	if err := json.Unmarshal(line, snap); err != nil {
+		return nil, 0, 0, fmt.Errorf("could not decode last snapshot: %s", err)
+	}
+	return snap, start, end, nil
+}
+
+func (s *jsonLinesStore) UpdateLast(snap *Snapshot) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, start, _, err := s.last()
+	if err != nil {
+		return err
+	}
+	return s.write(snap, start)
+}
+
+func (s *jsonLinesStore) Load() (*Stream, error) {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return &Stream{Snapshots: []*Snapshot{}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stream, err := ReadStream(f)
+	if err == io.EOF {
+		return &Stream{Snapshots: []*Snapshot{}}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("could not read %s: %s", s.path, err)
+	}
+	return stream, nil
+}
+
+func (s *jsonLinesStore) Prune(before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stream, err := s.Load()
+	if err != nil {
+		return 0, err
+	}
+	kept := &Stream{}
+	for _, snap := range stream.Snapshots {
+		if !snap.Time.Before(before) {
+			kept.Snapshots = append(kept.Snapshots, snap)
+		}
+	}
+	n := int64(len(stream.Snapshots) - len(kept.Snapshots))
+	if n == 0 {
+		return 0, nil
+	}
+	// The kept snapshots are written to a new file which then replaces
+	// the old one, so that the file is never left half written.
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	// CreateTemp makes files only their owner can read, which the
+	// rename would keep: give the new file the mode of the old one.
+	fi, err := os.Stat(s.path)
+	if err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Chmod(fi.Mode().Perm()); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := WriteJSONLines(tmp, kept); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if s.f != nil {
+		s.f.Close()
+		s.f = nil
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (s *jsonLinesStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
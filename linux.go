@@ -1,8 +1,13 @@
 package thyme
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
@@ -14,7 +19,17 @@ func init() {
 }
 
 // LinuxTracker tracks application usage on Linux via a few standard command-line utilities.
-type LinuxTracker struct{}
+type LinuxTracker struct {
+	// Displays are the X displays to track, e.g. ":0" and ":1" on a
+	// multi-seat machine. If empty, the tracker tracks the display
+	// named by the DISPLAY environment variable.
+	Displays []string
+
+	// Retry says how to run the programs that query the X server
+	// again when they fail because it is busy or unreachable, which
+	// happens now and then. The zero value stands for DefaultRetry.
+	Retry Retry
+}
 
 var _ Tracker = (*LinuxTracker)(nil)
 
@@ -22,6 +37,22 @@ func NewLinuxTracker() Tracker {
 	return &LinuxTracker{}
 }
 
+// ErrNoDisplay is returned by the Linux and Wayland trackers when
+// there is no display server to ask for windows, e.g. in an SSH
+// session.
+var ErrNoDisplay = errors.New("no X display found; set DISPLAY or run in a graphical session")
+
+// CheckDisplay returns ErrNoDisplay if neither an X display (DISPLAY)
+// nor a Wayland compositor (WAYLAND_DISPLAY) is set in the
+// environment. It doesn't run any programs, so it is cheap enough to
+// call before every snapshot.
+func CheckDisplay() error {
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return ErrNoDisplay
+	}
+	return nil
+}
+
 func (t *LinuxTracker) Deps() string {
 	return `
 Install the following command-line utilities via your package manager of choice:
@@ -29,18 +60,146 @@ Install the following command-line utilities via your package manager of choice:
 * xwininfo
 * xdotool
 * wmctrl
+* xprop (used to find the active window if xdotool isn't installed)
+* xprintidle (optional, used to detect when you're away from the keyboard)
+* xrandr (optional, used to tell which monitor each window is on)
+* xrdb (optional, used to read the scale of HiDPI displays)
 
 For example:
-* Debian: apt-get install x11-utils xdotool wmctrl
+* Debian: apt-get install x11-utils xdotool wmctrl xprintidle x11-xserver-utils
+
+Thyme works with only one of xdotool and wmctrl, but records less: without wmctrl, only the active window is recorded,
+and without xdotool, the active window is found with xprop.
+
+These utilities talk to the X server named by the DISPLAY environment variable, so Thyme can only track a graphical
+session. Over SSH, either pass the session's display along (e.g. DISPLAY=:0 thyme track) or run Thyme from within the
+session. To track several displays at once, e.g. on a multi-seat machine, repeat --display (thyme track --display :0
+--display :1), or pass --display auto to track every running X server.
+
+On this machine:
+` + ProgramStatus(linuxPrograms)
+}
+
+// linuxPrograms are the X11 utilities the LinuxTracker runs.
+var linuxPrograms = []Program{
+	{Name: "bash", Purpose: "run xdpyinfo"},
+	{Name: "xdpyinfo", Purpose: "find the size of the screen"},
+	{Name: "xwininfo", Purpose: "find the geometry of windows"},
+	{Name: "xdotool", Purpose: "find the active window", Fallback: "the active window is found with xprop instead"},
+	{Name: "wmctrl", Purpose: "list windows", Fallback: "only the active window is recorded, found with xdotool, without the other windows"},
+	{Name: "xprop", Optional: true, Purpose: "find the active window if xdotool isn't installed"},
+	{Name: "xprintidle", Optional: true, Purpose: "detect when you're away from the keyboard"},
+	{Name: "xrandr", Optional: true, Purpose: "tell which monitor each window is on"},
+	{Name: "xrdb", Optional: true, Purpose: "read the scale of HiDPI displays (Xft.dpi)"},
+	{Name: "loginctl", Optional: true, Purpose: "detect when the screen is locked"},
+}
+
+func (t *LinuxTracker) Programs() []Program {
+	return linuxPrograms
+}
+
+func (t *LinuxTracker) Snap(ctx context.Context) (*Snapshot, error) {
+	retry := t.Retry
+	if retry.Attempts == 0 {
+		retry = DefaultRetry
+	}
+	if len(t.Displays) == 0 {
+		if err := CheckDisplay(); err != nil {
+			return nil, err
+		}
+		snap, err := snapX(ctx, xDisplay{retry: retry})
+		if err != nil {
+			return nil, err
+		}
+		snap.Locked = sessionLocked(ctx)
+		logSnapshot("linux", snap)
+		return snap, nil
+	}
+	snap, err := snapXDisplays(ctx, t.Displays, retry)
+	if err != nil {
+		return nil, err
+	}
+	logSnapshot("linux", snap)
+	return snap, nil
+}
 
-Note: this command prints out this message regardless of whether the dependencies are already installed.
-`
+// XDisplays returns the X displays of the X servers running on this
+// machine, as found from their sockets in /tmp/.X11-unix, e.g. ":0"
+// and ":1".
+func XDisplays() ([]string, error) {
+	entries, err := os.ReadDir("/tmp/.X11-unix")
+	if err != nil {
+		return nil, fmt.Errorf("could not list X servers: %s", err)
+	}
+	var displays []string
+	for _, e := range entries {
+		name, ok := strings.CutPrefix(e.Name(), "X")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(name); err == nil {
+			displays = append(displays, fmt.Sprintf(":%d", n))
+		}
+	}
+	return displays, nil
+}
+
+// snapXDisplays takes a snapshot of each of displays and merges them
+// into one. Windows are tagged with their display, and their IDs and
+// monitor names are made unique across displays. The active window
+// is the one of the display that received user input last. Displays
+// that can't be snapshotted, e.g. because their X server stopped,
+// are left out, unless none of them can be.
+func snapXDisplays(ctx context.Context, displays []string, retry Retry) (*Snapshot, error) {
+	merged := &Snapshot{Time: time.Now()}
+	var errs []string
+	var activeIdle time.Duration
+	for _, display := range displays {
+		snap, err := snapX(ctx, xDisplay{name: display, retry: retry})
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			slog.Debug("could not snapshot display", "display", display, "error", err)
+			errs = append(errs, fmt.Sprintf("display %s: %s", display, err))
+			continue
+		}
+		// X window IDs fit in 32 bits, so the display can go in the
+		// upper ones.
+		prefix := (hash(display) & 0x7fffffff) << 32
+		for _, w := range snap.Windows {
+			w.ID |= prefix
+			w.Display = display
+			if w.Monitor != DefaultMonitor {
+				w.Monitor = display + "/" + w.Monitor
+			}
+			merged.Windows = append(merged.Windows, w)
+		}
+		for _, id := range snap.Visible {
+			merged.Visible = append(merged.Visible, id|prefix)
+		}
+		for _, m := range snap.Monitors {
+			m.Name = display + "/" + m.Name
+			merged.Monitors = append(merged.Monitors, m)
+		}
+		if snap.Active != 0 && (merged.Active == 0 || snap.Idle < activeIdle) {
+			merged.Active, activeIdle = snap.Active|prefix, snap.Idle
+		}
+	}
+	if len(errs) == len(displays) {
+		return nil, fmt.Errorf("could not snapshot any X display: %s", strings.Join(errs, "; "))
+	}
+	merged.Idle, merged.Locked = activeIdle, sessionLocked(ctx)
+	return merged, nil
 }
 
-func (t *LinuxTracker) Snap() (*Snapshot, error) {
+// snapX takes a snapshot of the windows of the X display x. Lock state
+// is left to the caller, as it belongs to the login session rather
+// than the display.
+func snapX(ctx context.Context, x xDisplay) (*Snapshot, error) {
 	var viewWidth, viewHeight int
 	{
-		out, err := exec.Command("bash", "-c", "xdpyinfo | grep dimensions").Output()
+		out, err := x.output(ctx, "bash", "-c", "xdpyinfo | grep dimensions")
 		if err != nil {
 			return nil, fmt.Errorf("xdpyinfo failed with error: %s. Try running `xdpyinfo | grep dimensions` to diagnose.", err)
 		}
@@ -59,61 +218,93 @@ func (t *LinuxTracker) Snap() (*Snapshot, error) {
 		viewWidth, viewHeight = w, h
 	}
 
-	var windows []*Window
-	{
-		out, err := exec.Command("wmctrl", "-l").Output()
+	// Either of wmctrl and xdotool is enough to find the active window,
+	// though only wmctrl lists the others.
+	hasWmctrl, hasXdotool := hasProgram("wmctrl"), hasProgram("xdotool")
+	if !hasWmctrl && !hasXdotool {
+		return nil, fmt.Errorf("neither wmctrl nor xdotool is installed, and at least one of them is needed to find windows; see `thyme dep`")
+	}
+
+	var active int64
+	if hasXdotool {
+		out, err := x.output(ctx, "xdotool", "getactivewindow")
+		if err != nil {
+			return nil, fmt.Errorf("xdotool failed with error: %s. Try running `xdotool getactivewindow` to diagnose.", err)
+		}
+		id, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		active = id
+	} else {
+		active = xpropActiveWindow(ctx, x)
+	}
+
+	var currentDesktop int64
+	if hasWmctrl {
+		out, err := x.output(ctx, "wmctrl", "-d")
 		if err != nil {
-			return nil, fmt.Errorf("wmctrl failed with error: %s. Try running `wmctrl -l` to diagnose.", err)
+			return nil, err
 		}
 		lines := strings.Split(string(out), "\n")
 		for _, line := range lines {
 			fields := strings.Fields(line)
-			if len(fields) < 4 {
+			if len(fields) < 2 {
 				continue
 			}
-			id_, desktop_, name := fields[0], fields[1], strings.Join(fields[3:], " ")
+			id_, mode := fields[0], fields[1]
 			id, err := strconv.ParseInt(id_, 0, 64)
 			if err != nil {
 				return nil, err
 			}
-			desktop, err := strconv.ParseInt(desktop_, 0, 64)
-			if err != nil {
-				return nil, err
-			}
-			w := Window{ID: id, Desktop: desktop, Name: name}
-			if !w.IsSystem() {
-				windows = append(windows, &w)
+			if "*" == mode {
+				currentDesktop = id
 			}
 		}
+	} else if out, err := x.output(ctx, "xdotool", "get_desktop"); err == nil {
+		currentDesktop, _ = strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
 	}
 
-	var currentDesktop int64
-	{
-		out, err := exec.Command("wmctrl", "-d").Output()
+	var windows []*Window
+	if hasWmctrl {
+		out, err := x.output(ctx, "wmctrl", "-lp")
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("wmctrl failed with error: %s. Try running `wmctrl -lp` to diagnose.", err)
 		}
 		lines := strings.Split(string(out), "\n")
 		for _, line := range lines {
 			fields := strings.Fields(line)
-			if len(fields) < 2 {
+			if len(fields) < 4 {
 				continue
 			}
-			id_, mode := fields[0], fields[1]
+			id_, desktop_, pid_, name := fields[0], fields[1], fields[2], strings.Join(fields[4:], " ")
 			id, err := strconv.ParseInt(id_, 0, 64)
 			if err != nil {
 				return nil, err
 			}
-			if "*" == mode {
-				currentDesktop = id
+			// Window managers that don't expose desktops leave every
+			// window on desktop 0.
+			desktop, _ := strconv.ParseInt(desktop_, 0, 64)
+			w := Window{ID: id, Desktop: desktop, Name: name}
+			// wmctrl prints 0 for windows that don't set _NET_WM_PID.
+			if pid, err := strconv.Atoi(pid_); err == nil && pid > 0 {
+				w.PID, w.Process = pid, procName(pid)
+			}
+			if !w.IsSystem() {
+				windows = append(windows, &w)
 			}
 		}
+	} else if active != 0 {
+		slog.Debug("wmctrl isn't installed; only recording the active window")
+		if w := xdotoolWindow(ctx, x, active, currentDesktop); !w.IsSystem() {
+			windows = append(windows, w)
+		}
 	}
 
 	var visible []int64
 	{
 		for _, window := range windows {
-			out_, err := exec.Command("xwininfo", "-id", fmt.Sprintf("%d", window.ID), "-stats").Output()
+			out_, err := x.output(ctx, "xwininfo", "-id", fmt.Sprintf("%d", window.ID), "-stats")
 			if err != nil {
 				return nil, fmt.Errorf("xwininfo failed with error: %s", err)
 			}
@@ -134,26 +325,191 @@ func (t *LinuxTracker) Snap() (*Snapshot, error) {
 			if err != nil {
 				return nil, err
 			}
+			window.X, window.Y, window.Width, window.Height = x, y, w, h
 			if window.IsOnDesktop(currentDesktop) && isVisible(x, y, w, h, viewHeight, viewWidth) {
 				visible = append(visible, window.ID)
 			}
 		}
 	}
 
-	var active int64
-	{
-		out, err := exec.Command("xdotool", "getactivewindow").Output()
-		if err != nil {
-			return nil, fmt.Errorf("xdotool failed with error: %s. Try running `xdotool getactivewindow` to diagnose.", err)
+	snap := &Snapshot{Windows: windows, Active: active, Visible: visible, Time: time.Now(), Idle: xIdle(ctx, x), Monitors: xMonitors(ctx, x)}
+	snap.AssignMonitors()
+	snap.DetectFullScreen()
+	snap.MarkPartial()
+	return snap, nil
+}
+
+// hasProgram reports whether the program name is installed, i.e. on
+// PATH.
+func hasProgram(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// activeWindowRx matches the output of `xprop -root
+// _NET_ACTIVE_WINDOW`, e.g. "_NET_ACTIVE_WINDOW(WINDOW): window id #
+// 0x3a00007".
+var activeWindowRx = regexp.MustCompile(`window id # (0x[0-9a-fA-F]+)`)
+
+// xpropActiveWindow returns the ID of the active window of x, as
+// reported by xprop, or 0 if it is unknown, e.g. because xprop isn't
+// installed.
+func xpropActiveWindow(ctx context.Context, x xDisplay) int64 {
+	out, err := x.output(ctx, "xprop", "-root", "_NET_ACTIVE_WINDOW")
+	if err != nil {
+		slog.Debug("could not find the active window with xprop", "error", err)
+		return 0
+	}
+	m := activeWindowRx.FindStringSubmatch(string(out))
+	if m == nil {
+		return 0
+	}
+	id, _ := strconv.ParseInt(m[1], 0, 64)
+	return id
+}
+
+// xdotoolWindow returns the window id of x with what xdotool tells
+// about it, for when wmctrl isn't there to list windows. What xdotool
+// can't tell is left unknown, e.g. the PID of windows that don't set
+// it, except for the desktop, which is taken to be desktop, the
+// current one.
+func xdotoolWindow(ctx context.Context, x xDisplay, id, desktop int64) *Window {
+	w := &Window{ID: id, Desktop: desktop}
+	arg := strconv.FormatInt(id, 10)
+	if out, err := x.output(ctx, "xdotool", "getwindowname", arg); err == nil {
+		w.Name = strings.TrimRight(string(out), "\n")
+	}
+	if out, err := x.output(ctx, "xdotool", "getwindowpid", arg); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(out))); err == nil && pid > 0 {
+			w.PID, w.Process = pid, procName(pid)
 		}
-		id, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
-		if err != nil {
-			return nil, err
+	}
+	if out, err := x.output(ctx, "xdotool", "get_desktop_for_window", arg); err == nil {
+		if d, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64); err == nil {
+			w.Desktop = d
+		}
+	}
+	return w
+}
+
+// xDisplay is an X display to run programs against: the one called
+// name, or the one named by DISPLAY if name is empty.
+type xDisplay struct {
+	name string
+
+	// retry says how to run the programs again when the X server
+	// fails to answer them.
+	retry Retry
+}
+
+// output is like commandOutput, but runs the program against the
+// display, and retries it as x.retry says.
+func (x xDisplay) output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var env []string
+	if x.name != "" {
+		env = []string{"DISPLAY=" + x.name}
+	}
+	return commandOutputRetry(ctx, x.retry, env, name, args...)
+}
+
+// procName returns the name of the executable of the process pid, as
+// found in /proc, or an empty string if it can't be found there.
+func procName(pid int) string {
+	if exe, err := os.Readlink(fmt.Sprintf("/proc/%d/exe", pid)); err == nil {
+		return filepath.Base(strings.TrimSuffix(exe, " (deleted)"))
+	}
+	// The executable of processes owned by other users can't be read,
+	// but their command name (truncated to 15 characters) can.
+	if comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+		return strings.TrimSpace(string(comm))
+	}
+	return ""
+}
+
+// xMonitors returns the monitors of the screen of x, as reported by
+// `xrandr --listmonitors`. It returns nil if they can't be
+// determined, in which case windows are attributed to DefaultMonitor.
+func xMonitors(ctx context.Context, x xDisplay) []*Monitor {
+	out, err := x.output(ctx, "xrandr", "--listmonitors")
+	if err != nil {
+		return nil
+	}
+	var monitors []*Monitor
+	for _, line := range strings.Split(string(out), "\n") {
+		matches := monitorRx.FindStringSubmatch(line)
+		if len(matches) != 6 {
+			continue
+		}
+		var dims [4]int
+		for i := range dims {
+			if dims[i], err = strconv.Atoi(matches[i+1]); err != nil {
+				return nil
+			}
+		}
+		monitors = append(monitors, &Monitor{Name: matches[5], Width: dims[0], Height: dims[1], X: dims[2], Y: dims[3]})
+	}
+	if len(monitors) > 0 {
+		scale := xScale(ctx, x)
+		for _, m := range monitors {
+			m.Scale = scale
 		}
-		active = id
 	}
+	return monitors
+}
+
+var xftDPIRx = regexp.MustCompile(`(?m)^Xft\.dpi:\s*([0-9.]+)\s*$`)
 
-	return &Snapshot{Windows: windows, Active: active, Visible: visible, Time: time.Now()}, nil
+// xScale returns the scale factor of the screens of x, which X sets
+// for all of them at once: the Xft.dpi resource, which desktops raise
+// on HiDPI displays, over the 96 DPI of an unscaled one. It returns
+// zero if xrdb fails or the resource isn't set, in which case the
+// scale is unknown. Window and monitor geometry are in physical
+// pixels either way.
+func xScale(ctx context.Context, x xDisplay) float64 {
+	out, err := x.output(ctx, "xrdb", "-query")
+	if err != nil {
+		return 0
+	}
+	m := xftDPIRx.FindSubmatch(out)
+	if m == nil {
+		return 0
+	}
+	dpi, err := strconv.ParseFloat(string(m[1]), 64)
+	if err != nil || dpi <= 0 {
+		return 0
+	}
+	return dpi / 96
+}
+
+// xIdle returns how long the X server of x has gone without user
+// input, as reported by `xprintidle`. xprintidle is an optional
+// dependency, so xIdle returns zero if it fails.
+func xIdle(ctx context.Context, x xDisplay) time.Duration {
+	out, err := x.output(ctx, "xprintidle")
+	if err != nil {
+		return 0
+	}
+	ms, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// sessionLocked reports whether the screen of the current login
+// session is locked, as reported by the LockedHint property that
+// systemd-logind gets from the session's screen locker. It returns
+// false if the property can't be read, e.g. without systemd.
+func sessionLocked(ctx context.Context) bool {
+	session := os.Getenv("XDG_SESSION_ID")
+	if session == "" {
+		session = "auto"
+	}
+	out, err := commandOutput(ctx, "loginctl", "show-session", session, "--property=LockedHint", "--value")
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(out)) == "yes"
 }
 
 // isVisible checks if the window is visible in the current viewport.
@@ -173,6 +529,10 @@ var (
 	yRx   = regexp.MustCompile(`Absolute upper\-left Y:\s+(\-?[0-9]+)`)
 	wRx   = regexp.MustCompile(`Width:\s+([0-9]+)`)
 	hRx   = regexp.MustCompile(`Height:\s+([0-9]+)`)
+
+	// monitorRx matches a monitor in the output of `xrandr --listmonitors`, e.g.
+	// " 1: +HDMI-1 2560/597x1440/336+1920+0  HDMI-1".
+	monitorRx = regexp.MustCompile(`^\s*[0-9]+:\s+\S+\s+([0-9]+)/[0-9]+x([0-9]+)/[0-9]+\+(-?[0-9]+)\+(-?[0-9]+)\s+(\S+)`)
 )
 
 // parseWinDim parses window dimension info from the output of `xwininfo`
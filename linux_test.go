@@ -0,0 +1,201 @@
+package thyme
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeXPrograms are scripts standing in for the X utilities the
+// LinuxTracker runs. They tell of a 1920x1080 screen showing the
+// editor on its left half and the terminal, active, on its right half,
+// both windows belonging to the test process.
+var fakeXPrograms = map[string]string{
+	"xdpyinfo": `echo "  dimensions:    1920x1080 pixels (508x285 millimeters)"`,
+	"xdotool": `case "$1" in
+getactivewindow) echo 2 ;;
+getwindowname) [ "$2" = 1 ] && echo "main.go - Code" || echo "~ - Terminal" ;;
+getwindowpid) echo $PPID ;;
+get_desktop|get_desktop_for_window) echo 0 ;;
+*) exit 1 ;;
+esac`,
+	"wmctrl": `case "$1" in
+-d) echo "0  * DG: 1920x1080  VP: 0,0  WA: 0,0 1920x1080  Desktop 1" ;;
+-lp) echo "0x00000001  0 $PPID   host main.go - Code"
+     echo "0x00000002  0 $PPID   host ~ - Terminal" ;;
+*) exit 1 ;;
+esac`,
+	"xwininfo": `echo "  Absolute upper-left X:  $(( ($2 - 1) * 960 ))"
+echo "  Absolute upper-left Y:  0"
+echo "  Width: 960"
+echo "  Height: 1080"`,
+	"xprop":      `echo "_NET_ACTIVE_WINDOW(WINDOW): window id # 0x2"`,
+	"xprintidle": `echo 1500`,
+	"xrandr": `echo "Monitors: 1"
+echo " 0: +*eDP-1 1920/344x1080/193+0+0  eDP-1"`,
+	"xrdb":     `printf 'Xft.dpi:\t192\n'`,
+	"loginctl": `echo no`,
+}
+
+// fakeX puts the fake X utilities of fakeXPrograms on PATH for the
+// duration of the test, except those named in without, which are left
+// out of PATH rather than falling back to the real ones.
+func fakeX(t *testing.T, without ...string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, script := range fakeXPrograms {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range without {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// xdpyinfo is run through bash, and piped into grep.
+	for _, name := range []string{"bash", "grep"} {
+		path, err := exec.LookPath(name)
+		if err != nil {
+			t.Skipf("%s is not installed", name)
+		}
+		if err := os.Symlink(path, filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	t.Setenv("DISPLAY", ":99")
+}
+
+// fakeXWindows returns the windows fakeXPrograms tells of, as the
+// LinuxTracker records them.
+func fakeXWindows() []*Window {
+	pid := os.Getpid()
+	return []*Window{
+		{ID: 1, Name: "main.go - Code", Width: 960, Height: 1080, Monitor: "eDP-1", PID: pid, Process: procName(pid)},
+		{ID: 2, Name: "~ - Terminal", X: 960, Width: 960, Height: 1080, Monitor: "eDP-1", PID: pid, Process: procName(pid)},
+	}
+}
+
+// checkXSnapshot reports how snap differs from the snapshot of
+// windows, the one with ID active being active, taken through
+// fakeXPrograms.
+func checkXSnapshot(t *testing.T, snap *Snapshot, windows []*Window, active int64) {
+	t.Helper()
+	if snap.Active != active {
+		t.Errorf("active window %d, want %d", snap.Active, active)
+	}
+	if len(snap.Windows) != len(windows) {
+		t.Fatalf("got %d windows, want %d", len(snap.Windows), len(windows))
+	}
+	for i, w := range windows {
+		if *snap.Windows[i] != *w {
+			t.Errorf("window %d is %+v, want %+v", i, *snap.Windows[i], *w)
+		}
+	}
+	if len(snap.Visible) != len(windows) {
+		t.Errorf("visible windows %v, want all %d", snap.Visible, len(windows))
+	}
+	if snap.Idle != 1500*time.Millisecond {
+		t.Errorf("idle for %s, want 1.5s", snap.Idle)
+	}
+	if len(snap.Monitors) != 1 || snap.Monitors[0].Scale != 2 {
+		t.Errorf("monitors %v, want eDP-1 at scale 2", snap.Monitors)
+	}
+}
+
+// TestLinuxTrackerConcurrentSnap is meant to be run with -race, which
+// catches state the snapshots share.
+func TestLinuxTrackerConcurrentSnap(t *testing.T) {
+	fakeX(t)
+	tracker := &LinuxTracker{}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				snap, err := tracker.Snap(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				checkXSnapshot(t, snap, fakeXWindows(), 2)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLinuxTrackerMissingTools(t *testing.T) {
+	all := fakeXWindows()
+	// Without wmctrl, only the active window is recorded.
+	activeOnly := []*Window{all[1]}
+	for _, tt := range []struct {
+		name    string
+		without []string
+		windows []*Window
+		active  int64
+		status  string
+	}{
+		{
+			name:    "all tools",
+			windows: all,
+			active:  2,
+		},
+		{
+			name:    "no xdotool",
+			without: []string{"xdotool"},
+			windows: all,
+			active:  2,
+			status:  "* xdotool: missing; the active window is found with xprop instead",
+		},
+		{
+			name:    "neither xdotool nor xprop",
+			without: []string{"xdotool", "xprop"},
+			windows: all,
+			status:  "* xprop: missing (optional, used to find the active window if xdotool isn't installed)",
+		},
+		{
+			name:    "no wmctrl",
+			without: []string{"wmctrl"},
+			windows: activeOnly,
+			active:  2,
+			status:  "* wmctrl: missing; only the active window is recorded, found with xdotool, without the other windows",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeX(t, tt.without...)
+			snap, err := (&LinuxTracker{}).Snap(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if snap.Active != tt.active {
+				t.Errorf("active window %d, want %d", snap.Active, tt.active)
+			}
+			if len(snap.Windows) != len(tt.windows) {
+				t.Fatalf("got %d windows, want %d", len(snap.Windows), len(tt.windows))
+			}
+			for i, w := range tt.windows {
+				if *snap.Windows[i] != *w {
+					t.Errorf("window %d is %+v, want %+v", i, *snap.Windows[i], *w)
+				}
+			}
+			if status := ProgramStatus(linuxPrograms); !strings.Contains(status, tt.status) {
+				t.Errorf("program status doesn't say %q:\n%s", tt.status, status)
+			}
+		})
+	}
+}
+
+func TestLinuxTrackerNoTools(t *testing.T) {
+	fakeX(t, "wmctrl", "xdotool")
+	if _, err := (&LinuxTracker{}).Snap(context.Background()); err == nil || !strings.Contains(err.Error(), "neither wmctrl nor xdotool") {
+		t.Errorf("got error %v, want one saying neither wmctrl nor xdotool is installed", err)
+	}
+}
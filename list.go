@@ -1,7 +1,29 @@
 package thyme
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"os"
+)
 
+// List prints the snapshots of stream to standard output.
 func List(stream *Stream) {
-	fmt.Printf("%s", stream.Print())
+	WriteList(os.Stdout, stream)
+}
+
+// WriteList writes the snapshots of stream to w, as printed by List,
+// after the interval they were taken at.
+func WriteList(w io.Writer, stream *Stream) error {
+	if len(stream.Snapshots) > 1 {
+		interval, estimated := stream.SamplingInterval()
+		note := ""
+		if estimated {
+			note = " (estimated from their spacing)"
+		}
+		if _, err := fmt.Fprintf(w, "Snapshots taken every %s%s\n\n", interval, note); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s", stream.Print())
+	return err
 }
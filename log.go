@@ -0,0 +1,56 @@
+package thyme
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// The package logs what the trackers do at the debug level of the
+// default slog logger, so that it stays quiet unless the program
+// asks for more, e.g. with slog.SetLogLoggerLevel(slog.LevelDebug).
+
+// debugOutputLimit is how much of the output of a program is logged.
+const debugOutputLimit = 512
+
+// hideOutput is whether logCommand leaves the output of programs out
+// (see HideCommandOutput).
+var hideOutput atomic.Bool
+
+// HideCommandOutput keeps the output of the programs the trackers run,
+// which holds window titles, out of the debug logs, e.g. because titles
+// are hashed or redacted before they are stored.
+func HideCommandOutput() {
+	hideOutput.Store(true)
+}
+
+// logCommand logs that the program called name was run with args,
+// and what it printed, unless HideCommandOutput was called, or how it
+// failed.
+func logCommand(name string, args []string, out []byte, err error, took time.Duration) {
+	if !slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	attrs := []any{"program", name, "args", strings.Join(args, " "), "took", took.Round(time.Millisecond)}
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	if hideOutput.Load() {
+		attrs = append(attrs, "output_bytes", len(out))
+	} else {
+		output := string(out)
+		if len(output) > debugOutputLimit {
+			output = output[:debugOutputLimit] + "…"
+		}
+		attrs = append(attrs, "output", output)
+	}
+	slog.Debug("ran command", attrs...)
+}
+
+// logSnapshot logs what the tracker called tracker found in snap.
+func logSnapshot(tracker string, snap *Snapshot) {
+	slog.Debug("took snapshot", "tracker", tracker, "windows", len(snap.Windows), "visible", len(snap.Visible),
+		"active", snap.Active, "idle", snap.Idle, "monitors", len(snap.Monitors), "locked", snap.Locked)
+}
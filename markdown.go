@@ -0,0 +1,114 @@
+package thyme
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// markdownApps is the number of applications listed for each day by
+// WriteMarkdown.
+const markdownApps = 10
+
+// markdownWidth is the width, in characters, at which WriteMarkdown
+// wraps the lines of lists.
+const markdownWidth = 80
+
+// WriteMarkdown writes a summary of stream, which must be in
+// chronological order, to w as Markdown, e.g. for a work journal. Each
+// day gets a heading, its total active time, a table of the
+// applications most active during it, and its sessions, which end when
+// the user is away for longer than gap (see Sessions).
+func WriteMarkdown(w io.Writer, stream *Stream, gap time.Duration) error {
+	apps, err := LookupGrouping("app")
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Thyme summary\n")
+	if len(stream.Snapshots) == 0 {
+		fmt.Fprintf(bw, "\nNo snapshots.\n")
+	}
+	var start time.Time
+	for {
+		day := stream.Between(start, time.Time{})
+		if len(day.Snapshots) == 0 {
+			break
+		}
+		first := day.Snapshots[0].Time
+		y, m, d := first.Date()
+		start = time.Date(y, m, d+1, 0, 0, 0, 0, first.Location())
+		day = day.Between(time.Time{}, start)
+		writeMarkdownDay(bw, first, day, apps.Totals(day, nil), gap)
+	}
+	return bw.Flush()
+}
+
+// writeMarkdownDay writes the section of WriteMarkdown about the day of
+// date, whose snapshots make up stream, with apps the time spent in
+// each application.
+func writeMarkdownDay(w io.Writer, date time.Time, stream *Stream, apps []*Total, gap time.Duration) {
+	var active float64
+	for _, t := range apps {
+		active += t.ActiveSeconds
+	}
+	fmt.Fprintf(w, "\n## %s\n\n", date.Format("Monday, January 2, 2006"))
+	fmt.Fprintf(w, "Active for **%s**.\n", hoursMinutes(secondsDuration(active)))
+
+	for i, t := range apps {
+		if t.ActiveSeconds <= 0 {
+			apps = apps[:i]
+			break
+		}
+	}
+	if len(apps) > 0 {
+		fmt.Fprintf(w, "\n| Application | Active | Share |\n| --- | ---: | ---: |\n")
+		for _, t := range apps[:min(len(apps), markdownApps)] {
+			fmt.Fprintf(w, "| %s | %s | %.0f%% |\n", markdownCell(t.Label), hoursMinutes(secondsDuration(t.ActiveSeconds)), 100*t.ActiveSeconds/active)
+		}
+		if len(apps) > markdownApps {
+			fmt.Fprintf(w, "\nand %d more.\n", len(apps)-markdownApps)
+		}
+	}
+
+	if sessions := Sessions(stream, gap); len(sessions) > 0 {
+		fmt.Fprintf(w, "\n### Sessions\n\n")
+		for _, s := range sessions {
+			item := fmt.Sprintf("%s–%s (%s)", s.Start.Format("15:04"), s.End.Format("15:04"), hoursMinutes(s.Duration()))
+			if len(s.Apps) > 0 {
+				item += ": " + strings.Join(s.Apps, ", ")
+			}
+			fmt.Fprintf(w, "%s\n", wrapMarkdown("- "+item, "  "))
+		}
+	}
+}
+
+// markdownCell escapes s for use in a cell of a Markdown table.
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// wrapMarkdown wraps s at spaces so that lines are at most
+// markdownWidth characters long, where possible, and starts the lines
+// after the first with indent.
+func wrapMarkdown(s, indent string) string {
+	var b strings.Builder
+	width := 0
+	for i, word := range strings.Fields(s) {
+		n := len([]rune(word))
+		switch {
+		case i == 0:
+		case width+1+n > markdownWidth:
+			b.WriteString("\n" + indent)
+			width = len(indent)
+		default:
+			b.WriteByte(' ')
+			width++
+		}
+		b.WriteString(word)
+		width += n
+	}
+	return b.String()
+}
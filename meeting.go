@@ -0,0 +1,89 @@
+package thyme
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// meetingDetectors tell, on each system they support, whether a camera
+// or microphone is in use. Systems add theirs in init functions.
+var meetingDetectors = map[string]func(ctx context.Context) (bool, error){
+	"linux": procInMeeting,
+}
+
+// DetectMeeting sets the InMeeting field of snap to whether a camera
+// or microphone was in use when it was taken, as they are during
+// calls.
+//
+// On Linux, this is read from /proc: a process having a /dev/video*
+// device open, or an ALSA capture stream running. On macOS, CoreAudio
+// and CoreMediaIO are asked whether the default input device and the
+// cameras are running, which requires a build with cgo. On Windows,
+// the use of the camera and microphone is read from what the system
+// records for its privacy settings. Elsewhere, or if these can't be
+// read, DetectMeeting does nothing.
+//
+// Trackers don't detect meetings by default.
+func DetectMeeting(ctx context.Context, snap *Snapshot) error {
+	detect := meetingDetectors[runtime.GOOS]
+	if detect == nil {
+		return nil
+	}
+	in, err := detect(ctx)
+	if err != nil {
+		return fmt.Errorf("could not detect meeting: %s", err)
+	}
+	snap.InMeeting = in
+	return nil
+}
+
+// procInMeeting reports whether a process has a camera open or a
+// sound card is capturing, according to /proc. Only the processes of
+// the user, whose open files can be listed, are considered; these are
+// the ones that take part in calls.
+func procInMeeting(ctx context.Context) (bool, error) {
+	statuses, err := filepath.Glob("/proc/asound/card*/pcm*c/sub*/status")
+	if err != nil {
+		return false, err
+	}
+	for _, status := range statuses {
+		b, err := os.ReadFile(status)
+		if err != nil {
+			continue
+		}
+		if strings.Contains(string(b), "state: RUNNING") {
+			return true, nil
+		}
+	}
+
+	fds, err := filepath.Glob("/proc/[0-9]*/fd/*")
+	if err != nil {
+		return false, err
+	}
+	for _, fd := range fds {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if target, err := os.Readlink(fd); err == nil && strings.HasPrefix(target, "/dev/video") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// inMeetings returns a copy of the stream containing only the
+// snapshots taken during meetings.
+func (s *Stream) inMeetings() *Stream {
+	filtered := *s
+	filtered.Snapshots = make([]*Snapshot, 0, len(s.Snapshots))
+	for _, snap := range s.Snapshots {
+		if snap.InMeeting {
+			filtered.Snapshots = append(filtered.Snapshots, snap)
+		}
+	}
+	return &filtered
+}
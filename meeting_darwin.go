@@ -0,0 +1,88 @@
+//go:build darwin && cgo
+// +build darwin,cgo
+
+package thyme
+
+/*
+#cgo LDFLAGS: -framework CoreAudio -framework CoreMediaIO -framework CoreFoundation
+#include <CoreAudio/CoreAudio.h>
+#include <CoreMediaIO/CMIOHardware.h>
+#include <stdlib.h>
+
+// thyme_microphone_in_use returns 1 if the default input device is
+// running in any process, 0 if it isn't, and -1 if that can't be read.
+static int thyme_microphone_in_use(void) {
+	// Element 0 is the main element, called kAudioObjectPropertyElementMaster
+	// before macOS 12.
+	AudioObjectPropertyAddress addr = {kAudioHardwarePropertyDefaultInputDevice, kAudioObjectPropertyScopeGlobal, 0};
+	AudioDeviceID device = kAudioObjectUnknown;
+	UInt32 size = sizeof(device);
+	if (AudioObjectGetPropertyData(kAudioObjectSystemObject, &addr, 0, NULL, &size, &device) != noErr) {
+		return -1;
+	}
+	if (device == kAudioObjectUnknown) {
+		return 0;
+	}
+	addr.mSelector = kAudioDevicePropertyDeviceIsRunningSomewhere;
+	UInt32 running = 0;
+	size = sizeof(running);
+	if (AudioObjectGetPropertyData(device, &addr, 0, NULL, &size, &running) != noErr) {
+		return -1;
+	}
+	return running != 0;
+}
+
+// thyme_camera_in_use returns 1 if any camera is running in any
+// process, 0 if none is, and -1 if that can't be read.
+static int thyme_camera_in_use(void) {
+	CMIOObjectPropertyAddress addr = {kCMIOHardwarePropertyDevices, kCMIOObjectPropertyScopeGlobal, 0};
+	UInt32 size = 0;
+	if (CMIOObjectGetPropertyDataSize(kCMIOObjectSystemObject, &addr, 0, NULL, &size) != kCMIOHardwareNoError) {
+		return -1;
+	}
+	UInt32 n = size / sizeof(CMIOObjectID);
+	if (n == 0) {
+		return 0;
+	}
+	CMIOObjectID *devices = malloc(size);
+	if (devices == NULL) {
+		return -1;
+	}
+	UInt32 used = 0;
+	if (CMIOObjectGetPropertyData(kCMIOObjectSystemObject, &addr, 0, NULL, size, &used, devices) != kCMIOHardwareNoError) {
+		free(devices);
+		return -1;
+	}
+	int inUse = 0;
+	addr.mSelector = kCMIODevicePropertyDeviceIsRunningSomewhere;
+	for (UInt32 i = 0; i < used / sizeof(CMIOObjectID) && !inUse; i++) {
+		UInt32 running = 0;
+		UInt32 runningSize = 0;
+		if (CMIOObjectGetPropertyData(devices[i], &addr, 0, NULL, sizeof(running), &runningSize, &running) == kCMIOHardwareNoError) {
+			inUse = running != 0;
+		}
+	}
+	free(devices);
+	return inUse;
+}
+*/
+import "C"
+
+import (
+	"context"
+	"errors"
+)
+
+func init() {
+	meetingDetectors["darwin"] = coreAudioInMeeting
+}
+
+// coreAudioInMeeting reports whether the default input device or a
+// camera is in use, according to CoreAudio and CoreMediaIO.
+func coreAudioInMeeting(ctx context.Context) (bool, error) {
+	mic, camera := C.thyme_microphone_in_use(), C.thyme_camera_in_use()
+	if mic < 0 && camera < 0 {
+		return false, errors.New("CoreAudio and CoreMediaIO didn't say whether the microphone and cameras are in use")
+	}
+	return mic > 0 || camera > 0, nil
+}
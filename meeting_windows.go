@@ -0,0 +1,104 @@
+//go:build windows
+// +build windows
+
+package thyme
+
+import (
+	"context"
+	"syscall"
+	"unsafe"
+)
+
+func init() {
+	meetingDetectors["windows"] = consentStoreInMeeting
+}
+
+// consentStoreKey is the registry key in which Windows records, for
+// its privacy settings, when each application last used the camera
+// ("webcam") and microphone.
+const consentStoreKey = `Software\Microsoft\Windows\CurrentVersion\CapabilityAccessManager\ConsentStore\`
+
+// errorNoMoreItems is ERROR_NO_MORE_ITEMS, which RegEnumKeyEx returns
+// once all subkeys were enumerated.
+const errorNoMoreItems syscall.Errno = 259
+
+// consentStoreInMeeting reports whether an application is using the
+// camera or microphone, according to the consent store. Packaged
+// applications have a subkey of their own; the others are under
+// NonPackaged.
+func consentStoreInMeeting(ctx context.Context) (bool, error) {
+	var lastErr error
+	for _, capability := range []string{"webcam", "microphone"} {
+		inUse, err := consentInUse(consentStoreKey+capability, true)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if inUse {
+			return true, nil
+		}
+	}
+	// Neither could be read, e.g. on versions of Windows before 10.
+	if lastErr != nil {
+		return false, lastErr
+	}
+	return false, nil
+}
+
+// consentInUse reports whether an application under the key at path
+// of HKEY_CURRENT_USER is using the capability, which it is while it
+// has started using it but not stopped. nested says whether to look
+// under the NonPackaged subkey too.
+func consentInUse(path string, nested bool) (bool, error) {
+	var key syscall.Handle
+	if err := syscall.RegOpenKeyEx(syscall.HKEY_CURRENT_USER, syscall.StringToUTF16Ptr(path), 0, syscall.KEY_READ, &key); err != nil {
+		return false, err
+	}
+	defer syscall.RegCloseKey(key)
+	for i := uint32(0); ; i++ {
+		name := make([]uint16, 512)
+		n := uint32(len(name))
+		if err := syscall.RegEnumKeyEx(key, i, &name[0], &n, nil, nil, nil, nil); err != nil {
+			if err == errorNoMoreItems {
+				return false, nil
+			}
+			return false, err
+		}
+		sub := path + `\` + syscall.UTF16ToString(name[:n])
+		if syscall.UTF16ToString(name[:n]) == "NonPackaged" {
+			if nested {
+				if inUse, err := consentInUse(sub, false); err == nil && inUse {
+					return true, nil
+				}
+			}
+			continue
+		}
+		if consentStarted(sub) {
+			return true, nil
+		}
+	}
+}
+
+// consentStarted reports whether the application whose consent store
+// key is at path has started using the capability and not stopped.
+func consentStarted(path string) bool {
+	var key syscall.Handle
+	if err := syscall.RegOpenKeyEx(syscall.HKEY_CURRENT_USER, syscall.StringToUTF16Ptr(path), 0, syscall.KEY_READ, &key); err != nil {
+		return false
+	}
+	defer syscall.RegCloseKey(key)
+	start, okStart := regQword(key, "LastUsedTimeStart")
+	stop, okStop := regQword(key, "LastUsedTimeStop")
+	return okStart && okStop && start != 0 && stop == 0
+}
+
+// regQword returns the REG_QWORD value called name of key.
+func regQword(key syscall.Handle, name string) (uint64, bool) {
+	var value uint64
+	var typ uint32
+	size := uint32(unsafe.Sizeof(value))
+	if err := syscall.RegQueryValueEx(key, syscall.StringToUTF16Ptr(name), nil, &typ, (*byte)(unsafe.Pointer(&value)), &size); err != nil || typ != syscall.REG_QWORD {
+		return 0, false
+	}
+	return value, true
+}
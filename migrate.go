@@ -0,0 +1,164 @@
+package thyme
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// Migration is one step in the evolution of the schema of the sqlite
+// database written by `thyme track`.
+type Migration struct {
+	// Version is the schema version the database is at once the
+	// migration has been applied.
+	Version int
+
+	// Description says what the migration does.
+	Description string
+
+	apply func(tx *sql.Tx) error
+}
+
+// migrations are all the schema migrations, in the order they must be
+// applied. New ones go at the end, with the next version number.
+var migrations = []*Migration{
+	{
+		Version:     1,
+		Description: "create data table",
+		apply: func(tx *sql.Tx) error {
+			_, err := tx.Exec("CREATE TABLE IF NOT EXISTS data(time TIMESTAMP PRIMARY KEY, value TEXT)")
+			return err
+		},
+	},
+	{
+		Version:     2,
+		Description: "add end_time column to data table",
+		apply: func(tx *sql.Tx) error {
+			// Versions of thyme track that predate this migration
+			// may have added the column already.
+			var n int
+			if err := tx.QueryRow("SELECT count(*) FROM pragma_table_info('data') WHERE name = 'end_time'").Scan(&n); err != nil {
+				return err
+			}
+			if n > 0 {
+				return nil
+			}
+			_, err := tx.Exec("ALTER TABLE data ADD COLUMN end_time TIMESTAMP")
+			return err
+		},
+	},
+	{
+		Version:     3,
+		Description: "create day_totals cache tables",
+		apply: func(tx *sql.Tx) error {
+			for _, stmt := range []string{
+				"CREATE TABLE IF NOT EXISTS day_totals(options TEXT NOT NULL, day TEXT NOT NULL, label TEXT NOT NULL, active_seconds REAL, visible_seconds REAL, open_seconds REAL, active_samples INTEGER, visible_samples INTEGER, open_samples INTEGER, PRIMARY KEY(options, day, label))",
+				"CREATE TABLE IF NOT EXISTS day_totals_state(options TEXT PRIMARY KEY, through TIMESTAMP NOT NULL)",
+				// Snapshots added or deleted before the end of the cached
+				// days make the cache stale; it is then rebuilt on the
+				// next read.
+				"CREATE TRIGGER IF NOT EXISTS day_totals_insert AFTER INSERT ON data BEGIN " + dayTotalsInvalidate("NEW") + " END",
+				"CREATE TRIGGER IF NOT EXISTS day_totals_delete AFTER DELETE ON data BEGIN " + dayTotalsInvalidate("OLD") + " END",
+			} {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     4,
+		Description: "only invalidate the cached day totals of the days snapshots are added to or deleted from",
+		apply: func(tx *sql.Tx) error {
+			for _, stmt := range []string{
+				// The days are UTC dates, as the triggers don't know
+				// the time zone of the cached days.
+				"CREATE TABLE IF NOT EXISTS day_totals_stale(options TEXT NOT NULL, day TEXT NOT NULL, PRIMARY KEY(options, day))",
+				"DROP TRIGGER IF EXISTS day_totals_insert",
+				"DROP TRIGGER IF EXISTS day_totals_delete",
+				"CREATE TRIGGER day_totals_insert AFTER INSERT ON data BEGIN " + dayTotalsMarkStale("NEW") + " END",
+				"CREATE TRIGGER day_totals_delete AFTER DELETE ON data BEGIN " + dayTotalsMarkStale("OLD") + " END",
+			} {
+				if _, err := tx.Exec(stmt); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// dayTotalsInvalidate returns the statements that drop the cached day
+// totals that the snapshot row, NEW or OLD in a trigger, falls within.
+func dayTotalsInvalidate(row string) string {
+	return "DELETE FROM day_totals_state WHERE julianday(through) > julianday(" + row + ".time); " +
+		"DELETE FROM day_totals WHERE options NOT IN (SELECT options FROM day_totals_state);"
+}
+
+// dayTotalsMarkStale returns the statement that marks the UTC date of
+// the snapshot row, NEW or OLD in a trigger, as stale in the caches of
+// day totals that already cover it, for DayTotals to total the days
+// it overlaps anew.
+func dayTotalsMarkStale(row string) string {
+	return "INSERT OR IGNORE INTO day_totals_stale(options, day) SELECT options, date(" + row + ".time) FROM day_totals_state WHERE julianday(through) > julianday(" + row + ".time);"
+}
+
+// Migrate brings the schema of db up to date by applying, in order,
+// the migrations newer than the version recorded in its
+// schema_version table, and returns the migrations it applied. They
+// are applied in a single transaction, so if one fails, none of them
+// take effect. Running Migrate on an up-to-date database does nothing.
+func Migrate(db *sql.DB) ([]*Migration, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	applied, err := migrate(tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// migrate applies the migrations Migrate applies within tx, which is
+// left for the caller to commit.
+func migrate(tx *sql.Tx) ([]*Migration, error) {
+	if _, err := tx.Exec("CREATE TABLE IF NOT EXISTS schema_version(version INTEGER NOT NULL)"); err != nil {
+		return nil, fmt.Errorf("could not create schema_version table: %s", err)
+	}
+	var version int
+	switch err := tx.QueryRow("SELECT version FROM schema_version").Scan(&version); err {
+	case nil:
+	case sql.ErrNoRows:
+		if _, err := tx.Exec("INSERT INTO schema_version(version) VALUES(0)"); err != nil {
+			return nil, fmt.Errorf("could not initialize schema version: %s", err)
+		}
+	default:
+		return nil, fmt.Errorf("could not read schema version: %s", err)
+	}
+
+	var applied []*Migration
+	for _, m := range migrations {
+		if m.Version <= version {
+			continue
+		}
+		if err := m.apply(tx); err != nil {
+			return nil, fmt.Errorf("migration %d (%s) failed: %s", m.Version, m.Description, err)
+		}
+		if _, err := tx.Exec("UPDATE schema_version SET version = ?", m.Version); err != nil {
+			return nil, fmt.Errorf("could not record schema version %d: %s", m.Version, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
+
+// SchemaVersion returns the version of the newest migration, which is
+// the schema version of a database Migrate has brought up to date.
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
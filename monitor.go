@@ -0,0 +1,168 @@
+package thyme
+
+import (
+	"math"
+	"runtime"
+	"slices"
+)
+
+// DefaultMonitor is the monitor of windows whose monitor couldn't be
+// determined, e.g. because the tracker can't detect monitors on this
+// system.
+const DefaultMonitor = "default"
+
+// Monitor is a physical display. X and Y are the screen coordinates of
+// its top-left corner, in the same coordinate space as window
+// geometry.
+type Monitor struct {
+	Name          string
+	X, Y          int
+	Width, Height int
+
+	// Scale is the scale factor of the display, how many physical
+	// pixels it shows a logical pixel with, e.g. 2 on Retina displays
+	// or 1.5 at 150% scaling. It is zero if the tracker couldn't tell
+	// (see ScaleFactor).
+	Scale float64 `json:",omitempty"`
+}
+
+// ScaleFactor returns the scale factor of the monitor, assuming 1 if
+// it isn't known, or if m is nil.
+func (m *Monitor) ScaleFactor() float64 {
+	if m == nil || m.Scale <= 0 {
+		return 1
+	}
+	return m.Scale
+}
+
+// logicalGeometry lists the systems whose trackers record geometry in
+// logical pixels, such as the points of macOS, rather than in the
+// physical pixels of X and of Windows.
+var logicalGeometry = map[string]bool{"darwin": true}
+
+// tolerance returns n logical pixels in the units of the geometry of
+// the windows on m, as recorded on this system, so that tolerances
+// are as wide on HiDPI displays as on others.
+func (m *Monitor) tolerance(n int) int {
+	if logicalGeometry[runtime.GOOS] {
+		return n
+	}
+	return int(math.Round(float64(n) * m.ScaleFactor()))
+}
+
+// AssignMonitors sets the Monitor of every window in the snapshot that
+// doesn't have one yet to the monitor that contains the largest part
+// of the window, or to DefaultMonitor if that can't be determined.
+func (s *Snapshot) AssignMonitors() {
+	for _, w := range s.Windows {
+		if w.Monitor != "" {
+			continue
+		}
+		w.Monitor = DefaultMonitor
+		if len(s.Monitors) == 1 {
+			w.Monitor = s.Monitors[0].Name
+			continue
+		}
+		var best int
+		for _, m := range s.Monitors {
+			if area := overlap(w.X, w.Y, w.Width, w.Height, m.X, m.Y, m.Width, m.Height); area > best {
+				best, w.Monitor = area, m.Name
+			}
+		}
+	}
+}
+
+// fullScreenTolerance is how many logical pixels a window may fall
+// short of each edge of its monitor and still count as full-screen, to
+// allow for borders and rounding with display scaling.
+const fullScreenTolerance = 8
+
+// DetectFullScreen sets FullScreen on every window of the snapshot
+// whose geometry covers the whole of its monitor. It must be called
+// after AssignMonitors.
+func (s *Snapshot) DetectFullScreen() {
+	monitors := make(map[string]*Monitor)
+	for _, m := range s.Monitors {
+		monitors[m.Name] = m
+	}
+	for _, w := range s.Windows {
+		m := monitors[w.Monitor]
+		if m == nil || w.Width <= 0 || w.Height <= 0 {
+			continue
+		}
+		tol := m.tolerance(fullScreenTolerance)
+		w.FullScreen = w.X <= m.X+tol && w.Y <= m.Y+tol &&
+			w.X+w.Width >= m.X+m.Width-tol && w.Y+w.Height >= m.Y+m.Height-tol
+	}
+}
+
+// DetectSplitActive sets AlsoActive to the visible windows laid out
+// next to the focused window on its monitor, as tiling window managers
+// and split-screen views do: those that overlap no other visible
+// window, allowing for fullScreenTolerance logical pixels of borders,
+// while the focused window doesn't either. It is based on window
+// geometry alone, so that it is the same on every system, and must be
+// called after AssignMonitors.
+func (s *Snapshot) DetectSplitActive() {
+	s.AlsoActive = nil
+	active := s.ActiveWindow()
+	if active == nil {
+		return
+	}
+	var monitor *Monitor
+	for _, m := range s.Monitors {
+		if m.Name == active.Monitor {
+			monitor = m
+		}
+	}
+	tol := monitor.tolerance(fullScreenTolerance)
+	var visible []*Window
+	for _, w := range s.Windows {
+		if slices.Contains(s.Visible, w.ID) && w.Width > 0 && w.Height > 0 {
+			visible = append(visible, w)
+		}
+	}
+	tiled := func(w *Window) bool {
+		for _, o := range visible {
+			if o != w && overlaps(w, o, tol) {
+				return false
+			}
+		}
+		return slices.Contains(visible, w)
+	}
+	if !tiled(active) {
+		return
+	}
+	for _, w := range visible {
+		if w != active && monitorOf(w) == monitorOf(active) && tiled(w) {
+			s.AlsoActive = append(s.AlsoActive, w.ID)
+		}
+	}
+}
+
+// overlaps reports whether windows a and b overlap by more than tol
+// pixels both ways.
+func overlaps(a, b *Window, tol int) bool {
+	w := min(a.X+a.Width, b.X+b.Width) - max(a.X, b.X)
+	h := min(a.Y+a.Height, b.Y+b.Height) - max(a.Y, b.Y)
+	return w > tol && h > tol
+}
+
+// overlap returns the area of the intersection of two rectangles.
+func overlap(x1, y1, w1, h1, x2, y2, w2, h2 int) int {
+	w := min(x1+w1, x2+w2) - max(x1, x2)
+	h := min(y1+h1, y2+h2) - max(y1, y2)
+	if w <= 0 || h <= 0 {
+		return 0
+	}
+	return w * h
+}
+
+// monitorOf returns the monitor of w, treating windows recorded
+// before monitors were tracked as being on DefaultMonitor.
+func monitorOf(w *Window) string {
+	if w == nil || w.Monitor == "" {
+		return DefaultMonitor
+	}
+	return w.Monitor
+}
@@ -0,0 +1,143 @@
+package thyme
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// postgresStore is a Store backed by a PostgreSQL database, which lets
+// several machines record snapshots in the same place. Its data table
+// has the same columns as the sqlite one, and a host column naming the
+// machine each snapshot was recorded on, so that each machine only
+// merges snapshots into the last one it recorded itself.
+type postgresStore struct {
+	db *sql.DB
+
+	// host is the name of this machine, which the snapshots it
+	// records are tagged with.
+	host string
+
+	// last is the primary key of the snapshot last returned by Last,
+	// which is the one UpdateLast replaces.
+	last time.Time
+}
+
+func openPostgresStore(dsn string) (*postgresStore, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("could not get host name: %s", err)
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, err
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE IF NOT EXISTS data(time TIMESTAMPTZ PRIMARY KEY, value TEXT, end_time TIMESTAMPTZ)",
+		// Databases created before snapshots were tagged with their
+		// host lack the column; their rows belong to no host.
+		"ALTER TABLE data ADD COLUMN IF NOT EXISTS host TEXT NOT NULL DEFAULT ''",
+		"CREATE INDEX IF NOT EXISTS data_host_time ON data(host, time)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("could not create data table: %s", err)
+		}
+	}
+	return &postgresStore{db: db, host: host}, nil
+}
+
+func (s *postgresStore) Append(snap *Snapshot) error {
+	out, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("INSERT INTO data(time, value, host) VALUES($1, $2, $3) ON CONFLICT (time) DO NOTHING", snap.Time, string(out), s.host)
+	return err
+}
+
+// Last returns the most recent snapshot recorded on this machine,
+// ignoring those other machines recorded since.
+func (s *postgresStore) Last() (*Snapshot, error) {
+	var (
+		key   time.Time
+		value string
+	)
+	switch err := s.db.QueryRow("SELECT time, value FROM data WHERE host = $1 ORDER BY time DESC LIMIT 1", s.host).Scan(&key, &value); err {
+	case nil:
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal([]byte(value), &snap); err != nil {
+		return nil, fmt.Errorf("could not decode snapshot %q: %s", value, err)
+	}
+	s.last = key
+	return &snap, nil
+}
+
+// UpdateLast replaces the snapshot last returned by Last, checking
+// within the same transaction that it is still the most recent one
+// recorded on this machine.
+func (s *postgresStore) UpdateLast(snap *Snapshot) error {
+	if s.last.IsZero() {
+		return fmt.Errorf("no snapshot to update")
+	}
+	out, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	var end *time.Time
+	if !snap.EndTime.IsZero() {
+		end = &snap.EndTime
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var newest time.Time
+	if err := tx.QueryRow("SELECT time FROM data WHERE host = $1 ORDER BY time DESC LIMIT 1 FOR UPDATE", s.host).Scan(&newest); err != nil {
+		return fmt.Errorf("could not lock last snapshot: %s", err)
+	}
+	if !newest.Equal(s.last) {
+		return fmt.Errorf("snapshot taken at %s is no longer the last one", s.last)
+	}
+	if _, err := tx.Exec("UPDATE data SET value = $1, end_time = $2 WHERE time = $3", string(out), end, s.last); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *postgresStore) Load() (*Stream, error) {
+	rows, err := s.db.Query("SELECT value FROM data ORDER BY time")
+	if err != nil {
+		return nil, err
+	}
+	return scanStream(rows)
+}
+
+func (s *postgresStore) Prune(before time.Time) (int64, error) {
+	res, err := s.db.Exec("DELETE FROM data WHERE time < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.db.Exec("VACUUM data"); err != nil {
+		return n, fmt.Errorf("could not reclaim space: %s", err)
+	}
+	return n, nil
+}
+
+func (s *postgresStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,93 @@
+package thyme
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// openTestPostgres opens, emptied, the PostgreSQL database named by
+// $THYME_TEST_POSTGRES as recorded in by host, skipping the test if
+// that isn't set.
+func openTestPostgres(t *testing.T, host string) *postgresStore {
+	t.Helper()
+	dsn := os.Getenv("THYME_TEST_POSTGRES")
+	if dsn == "" {
+		t.Skip("THYME_TEST_POSTGRES is not set")
+	}
+	s, err := openPostgresStore(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	s.host = host
+	return s
+}
+
+func TestPostgresStorePerHost(t *testing.T) {
+	a := openTestPostgres(t, "a")
+	if _, err := a.db.Exec("DELETE FROM data"); err != nil {
+		t.Fatal(err)
+	}
+	b := openTestPostgres(t, "b")
+
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	fromA := &Snapshot{Time: start, Active: 1, Windows: []*Window{{ID: 1, Name: "main.go - Code"}}}
+	fromB := &Snapshot{Time: start.Add(time.Second), Active: 2, Windows: []*Window{{ID: 2, Name: "~ - Terminal"}}}
+	if err := a.Append(fromA); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Append(fromB); err != nil {
+		t.Fatal(err)
+	}
+
+	last, err := a.Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last == nil || !last.Time.Equal(fromA.Time) {
+		t.Fatalf("last snapshot of a is %v, want the one taken at %s", last, fromA.Time)
+	}
+	last.EndTime = start.Add(time.Minute)
+	if err := a.UpdateLast(last); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := a.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stream.Snapshots) != 2 {
+		t.Fatalf("got %d snapshots, want 2", len(stream.Snapshots))
+	}
+	if got := stream.Snapshots[0].EndTime; !got.Equal(last.EndTime) {
+		t.Errorf("snapshot of a ends at %s, want %s", got, last.EndTime)
+	}
+	if got := stream.Snapshots[1]; !got.EndTime.IsZero() || got.Active != fromB.Active {
+		t.Errorf("snapshot of b was changed to %+v", got)
+	}
+}
+
+func TestPostgresStoreUpdateLastMoved(t *testing.T) {
+	s := openTestPostgres(t, "a")
+	if _, err := s.db.Exec("DELETE FROM data"); err != nil {
+		t.Fatal(err)
+	}
+	other := openTestPostgres(t, "a")
+
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	if err := s.Append(&Snapshot{Time: start}); err != nil {
+		t.Fatal(err)
+	}
+	last, err := s.Last()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Append(&Snapshot{Time: start.Add(time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+	last.EndTime = start.Add(time.Minute)
+	if err := s.UpdateLast(last); err == nil {
+		t.Error("UpdateLast replaced a snapshot that is no longer the last one")
+	}
+}
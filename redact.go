@@ -0,0 +1,107 @@
+package thyme
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+)
+
+// Redacted replaces the titles of windows hidden by a Redactor.
+const Redacted = "[redacted]"
+
+// Redactor hides the titles of windows that may reveal private
+// information, such as document names or account numbers, before
+// snapshots are stored.
+type Redactor struct {
+	Patterns []*regexp.Regexp
+}
+
+// LoadRedactor reads the patterns of a Redactor from the JSON file at
+// path, a list of regexes matched against window names and URLs, e.g.
+//
+//	["Bank of .*", "\\b\\d{8,}\\b"]
+//
+// A missing file yields a Redactor that hides nothing rather than an
+// error.
+func LoadRedactor(path string) (*Redactor, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Redactor{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var raw []string
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("could not parse redaction file %s: %s", path, err)
+	}
+	r := &Redactor{}
+	for _, pattern := range raw {
+		rx, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q in %s: %s", pattern, path, err)
+		}
+		r.Patterns = append(r.Patterns, rx)
+	}
+	return r, nil
+}
+
+// Redact replaces, in place, the title of each window of snap whose
+// name or URL matches one of the patterns with Redacted. The part of
+// the name that holds the application is kept, so that the window
+// still counts towards its application. The URL of such a window is
+// cut down to its origin.
+func (r *Redactor) Redact(snap *Snapshot) {
+	if r == nil || len(r.Patterns) == 0 {
+		return
+	}
+	for _, w := range snap.Windows {
+		if r.matches(w.Name) || (w.URL != "" && r.matches(w.URL)) {
+			w.Name = redactName(w)
+			w.URL = redactURL(w.URL)
+		}
+	}
+}
+
+func (r *Redactor) matches(s string) bool {
+	for _, rx := range r.Patterns {
+		if rx.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactName returns the name of w with everything but the
+// application replaced by Redacted.
+func redactName(w *Window) string {
+	return replaceTitle(w.Name, func(string) string { return Redacted })
+}
+
+// replaceTitle returns name, the name of a window, with everything but
+// the application replaced by what replace returns for it, following
+// the same rules as Window.Info to tell where the application is. A
+// name without an application is replaced whole.
+func replaceTitle(name string, replace func(title string) string) string {
+	parts, bounds := splitTitle(name)
+	n := len(parts)
+	if n < 2 {
+		return replace(name)
+	}
+	if matchTitlePattern(parts[n-1], false) == nil && matchTitlePattern(parts[0], true) != nil {
+		// The application comes first, as in "Slack - #general".
+		return name[:bounds[1][0]] + replace(name[bounds[1][0]:])
+	}
+	return replace(name[:bounds[n-2][1]]) + name[bounds[n-2][1]:]
+}
+
+// redactURL returns the origin (e.g. "https://example.com") of u, or
+// nothing if u isn't a URL with a host.
+func redactURL(u string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+	return (&url.URL{Scheme: parsed.Scheme, Host: parsed.Host}).String()
+}
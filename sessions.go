@@ -0,0 +1,68 @@
+package thyme
+
+import (
+	"sort"
+	"time"
+)
+
+// Session is a block of uninterrupted activity.
+type Session struct {
+	Start time.Time
+	End   time.Time
+
+	// Apps are the applications used during the session, ordered by
+	// decreasing active time.
+	Apps []string
+}
+
+// Duration returns the length of the session.
+func (s *Session) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Sessions groups the snapshots of stream, which must be in
+// chronological order, into sessions, starting a new one whenever
+// consecutive snapshots are more than gap apart. Only snapshots with an
+// active window count as activity, so hiding idle time with
+// WithoutIdle also splits sessions at the user's breaks. Each snapshot
+// stands for the time until the next one, up to the usual interval
+// between snapshots.
+func Sessions(stream *Stream, gap time.Duration) []Session {
+	durations := sampleDurations(stream)
+
+	var sessions []Session
+	var current *Session
+	var apps map[string]time.Duration
+	finish := func() {
+		if current == nil {
+			return
+		}
+		for app := range apps {
+			current.Apps = append(current.Apps, app)
+		}
+		sort.Slice(current.Apps, func(a, b int) bool {
+			da, db := apps[current.Apps[a]], apps[current.Apps[b]]
+			if da != db {
+				return da > db
+			}
+			return current.Apps[a] < current.Apps[b]
+		})
+		sessions = append(sessions, *current)
+	}
+
+	for i, snap := range stream.Snapshots {
+		win := snap.ActiveWindow()
+		if win == nil {
+			continue
+		}
+		if current == nil || snap.Time.Sub(current.End) > gap {
+			finish()
+			current = &Session{Start: snap.Time}
+			apps = make(map[string]time.Duration)
+		}
+		current.End = snap.Time.Add(durations[i])
+		apps[appID(win)] += durations[i]
+	}
+	finish()
+	return sessions
+}
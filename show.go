@@ -1,31 +1,81 @@
 package thyme
 
 import (
+	_ "embed"
 	"fmt"
+	"html/template"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
-	"text/template"
 	"time"
 )
 
 const maxNumberOfBars = 30
 
+// StatsOptions say what the page rendered by WriteStats shows, and
+// how.
+type StatsOptions struct {
+	// Template renders the page, as returned by LoadStatsTemplate.
+	// If nil, the default template is used.
+	Template *template.Template
+
+	// Categories determine the category of windows. With no rules,
+	// there is no barchart of categories.
+	Categories *Categories
+
+	// Group, if not nil, adds a barchart of active time grouped by
+	// it.
+	Group *Grouping
+
+	// Goals are the goals whose daily progress is shown.
+	Goals []*Goal
+
+	// Colors are the colors labels are drawn in, in every chart.
+	Colors Colors
+
+	// Icons, if not nil, looks up the icons of the applications in
+	// the bar charts.
+	Icons *IconResolver
+}
+
 // Stats renders an HTML page with charts using stream as its data
-// source. Currently, it renders the following charts:
+// source to standard output. See WriteStats.
+func Stats(stream *Stream, opts StatsOptions) error {
+	return WriteStats(os.Stdout, stream, opts)
+}
+
+// WriteStats renders an HTML page with charts using stream as its data
+// source to w. Currently, it renders the following charts:
 // 1. A timeline of applications active, visible, and open
 // 2. A timeline of windows active, visible, and open
 // 3. A barchart of applications most often active, visible, and open
-func Stats(stream *Stream) error {
-	tlFine := NewTimeline(stream, func(w *Window) string { return w.Name })
-	tlCoarse := NewTimeline(stream, appID)
-	agg := NewAggTime(stream, appID)
-
-	if err := statsTmpl.Execute(os.Stdout, &statsPage{
-		Fine:   tlFine,
-		Coarse: tlCoarse,
-		Agg:    agg,
-	}); err != nil {
+// 4. A barchart of the categories most often active, if
+// opts.Categories has any rules
+// 5. A barchart of the monitors most often showing the active window,
+// if more than one was used
+// 6. A barchart of active time grouped by opts.Group, if it isn't nil
+// 7. The daily progress towards each of opts.Goals
+// 8. A stacked area chart of the applications active at each time of
+// the day
+func WriteStats(w io.Writer, stream *Stream, opts StatsOptions) error {
+	tmpl := opts.Template
+	if tmpl == nil {
+		tmpl = statsTmpl
+	}
+	page := newStatsPage(stream, opts.Categories)
+	page.Goals = NewGoalProgress(stream, opts.Categories, opts.Goals)
+	page.Colors = opts.Colors
+	if opts.Group != nil {
+		page.Breakdowns = append([]*BarChart{NewGroupChart(stream, opts.Group, opts.Categories)}, page.Breakdowns...)
+	}
+	if opts.Icons != nil {
+		page.Icons = opts.Icons.Icons(page.apps(stream))
+	}
+	if err := tmpl.Execute(w, page); err != nil {
 		return err
 	}
 	return nil
@@ -36,31 +86,66 @@ type AggTime struct {
 	Charts []*BarChart
 }
 
-// NewAggTime returns a new AggTime created from a Stream.
+// NewAggTime returns a new AggTime created from a Stream, in which each
+// snapshot counts for as many samples as it stands for (see
+// sampleCounts).
 func NewAggTime(stream *Stream, labelFunc func(*Window) string) *AggTime {
 	n := strconv.Itoa(maxNumberOfBars)
 	active := NewBarChart("Active", "App", "Samples", "Top "+n+" active applications by time (multiplied by window count)")
 	visible := NewBarChart("Visible", "App", "Samples", "Top "+n+" visible applications by time (multiplied by window count)")
 	all := NewBarChart("All", "App", "Samples", "Top "+n+" open applications by time (multiplied by window count)")
-	for _, snap := range stream.Snapshots {
+	activeShares := make(map[string]float64)
+	counts := sampleCounts(stream)
+	for i, snap := range stream.Snapshots {
 		windows := make(map[int64]*Window)
 		for _, win := range snap.Windows {
 			windows[win.ID] = win
 		}
 
-		if win := windows[snap.Active]; win != nil {
-			active.Plus(labelFunc(windows[snap.Active]), 1)
+		n := counts[i]
+		share := snap.ActiveShare() * float64(n)
+		for _, win := range snap.ActiveWindows() {
+			activeShares[labelFunc(win)] += share
 		}
 		for _, v := range snap.Visible {
-			visible.Plus(labelFunc(windows[v]), 1)
+			visible.Plus(labelFunc(windows[v]), n)
 		}
 		for _, win := range snap.Windows {
-			all.Plus(labelFunc(win), 1)
+			all.Plus(labelFunc(win), n)
 		}
 	}
+	active.plusShares(activeShares)
 	return &AggTime{Charts: []*BarChart{active, visible, all}}
 }
 
+// NewActiveChart returns a bar chart of the number of samples in
+// which the active window had each label, snapshots counting for as
+// many samples as they stand for (see sampleCounts) and those with
+// several active windows for each its share (see Snapshot.ActiveShare).
+// labelFunc determines the label of a window, and x is the x-axis
+// label. Windows labeled with an empty string are left out.
+func NewActiveChart(stream *Stream, id, x, title string, labelFunc func(*Window) string) *BarChart {
+	chart := NewBarChart(id, x, "Samples", title)
+	shares := make(map[string]float64)
+	counts := sampleCounts(stream)
+	for i, snap := range stream.Snapshots {
+		share := snap.ActiveShare() * float64(counts[i])
+		for _, win := range snap.ActiveWindows() {
+			if label := labelFunc(win); label != "" {
+				shares[label] += share
+			}
+		}
+	}
+	chart.plusShares(shares)
+	return chart
+}
+
+// NewCategoryChart returns a bar chart of the number of samples in
+// which an application of each category was active.
+func NewCategoryChart(stream *Stream, cats *Categories) *BarChart {
+	return NewActiveChart(stream, "Categories", "Category", "Active categories by time", cats.Categorize)
+}
+
 // BarChart is a representation of a bar chart.
 type BarChart struct {
 	ID     string
@@ -68,6 +153,14 @@ type BarChart struct {
 	XLabel string
 	Title  string
 	Series map[string]int
+
+	// Order, if set, lists the labels in the order their bars are
+	// shown, instead of by decreasing count.
+	Order []string
+
+	// Apps is whether the labels are application names, as returned
+	// by AppID, which the page shows with their icons.
+	Apps bool
 }
 
 // Bar represents a single bar in a bar chart.
@@ -87,10 +180,27 @@ func (c *BarChart) Plus(label string, n int) {
 	c.Series[label] += n
 }
 
+// plusShares adds shares, fractions of samples keyed by label, to the
+// counts of their labels, once rounded, so that fractions add up
+// before they are. Labels that round to no sample are left out.
+func (c *BarChart) plusShares(shares map[string]float64) {
+	for label, n := range shares {
+		if n := int(math.Round(n)); n > 0 {
+			c.Plus(label, n)
+		}
+	}
+}
+
 // OrderedBars returns a list of the top $maxNumberOfBars bars in the bar chart ordered by
-// decreasing count.
+// decreasing count, or all of them in the order of c.Order if it is set.
 func (c *BarChart) OrderedBars() []Bar {
 	var bars []Bar
+	if c.Order != nil {
+		for _, l := range c.Order {
+			bars = append(bars, Bar{Label: l, Count: c.Series[l]})
+		}
+		return bars
+	}
 	for l, c := range c.Series {
 		bars = append(bars, Bar{Label: l, Count: c})
 	}
@@ -141,8 +251,12 @@ func NewTimeline(stream *Stream, labelFunc func(*Window) string) *Timeline {
 	var active, visible, other []*Range
 	var lastActive *Range
 	var lastVisible, lastOther = make(map[string]*Range), make(map[string]*Range)
+	// The maps are reused from one snapshot to the next rather than
+	// allocated for each, which matters with a lot of snapshots.
+	windows := make(map[int64]*Window)
+	nextVisible, nextOther := make(map[string]*Range), make(map[string]*Range)
 	for _, snap := range stream.Snapshots {
-		windows := make(map[int64]*Window)
+		clear(windows)
 		for _, win := range snap.Windows {
 			windows[win.ID] = win
 		}
@@ -168,7 +282,7 @@ func NewTimeline(stream *Stream, labelFunc func(*Window) string) *Timeline {
 		for _, prevRange := range lastVisible {
 			prevRange.End = snap.Time
 		}
-		nextVisible := make(map[string]*Range)
+		clear(nextVisible)
 		for _, v := range snap.Visible {
 			var winLabel string
 			if win := windows[v]; win != nil {
@@ -182,12 +296,12 @@ func NewTimeline(stream *Stream, labelFunc func(*Window) string) *Timeline {
 				nextVisible[winLabel] = existRng
 			}
 		}
-		lastVisible = nextVisible
+		lastVisible, nextVisible = nextVisible, lastVisible
 
 		for _, prevRange := range lastOther {
 			prevRange.End = snap.Time
 		}
-		nextOther := make(map[string]*Range)
+		clear(nextOther)
 		for _, win := range snap.Windows {
 			winLabel := labelFunc(win)
 			if existRng, exists := lastOther[winLabel]; !exists {
@@ -198,198 +312,313 @@ func NewTimeline(stream *Stream, labelFunc func(*Window) string) *Timeline {
 				nextOther[winLabel] = existRng
 			}
 		}
-		lastOther = nextOther
+		lastOther, nextOther = nextOther, lastOther
+	}
+
+	// The ranges still open at the last snapshot last as long as it
+	// does.
+	end := stream.Snapshots[len(stream.Snapshots)-1].End()
+	if lastActive != nil {
+		lastActive.End = end
+	}
+	for _, r := range lastVisible {
+		r.End = end
+	}
+	for _, r := range lastOther {
+		r.End = end
 	}
 	return &Timeline{
 		Start: stream.Snapshots[0].Time,
-		End:   stream.Snapshots[len(stream.Snapshots)-1].Time,
+		End:   end,
 		Rows:  map[string][]*Range{"Active": active, "Visible": visible, "All": other},
 	}
 }
 
+// hoursMinutes is a template helper function that formats a duration
+// as hours and minutes, e.g. "8h05m".
+func hoursMinutes(d time.Duration) string {
+	d = d.Round(time.Minute)
+	return fmt.Sprintf("%dh%02dm", int(d.Hours()), int(d.Minutes())%60)
+}
+
 // timeToJS is a template helper function that converts a time.Time to
 // code that creates a JavaScript Date object.
-func timeToJS(t time.Time) string {
-	return fmt.Sprintf(`new Date(%d, %d, %d, %d, %d, %d)`, t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second())
+func timeToJS(t time.Time) template.JS {
+	// JavaScript months are zero-based.
+	return template.JS(fmt.Sprintf(`new Date(%d, %d, %d, %d, %d, %d)`, t.Year(), t.Month()-1, t.Day(), t.Hour(), t.Minute(), t.Second()))
 }
 
-// statsPage is the data rendered in statsTmpl.
-type statsPage struct {
+// StatsPage is the data of the page rendered by WriteStats, which its
+// template renders. Fields that a page has no data for are empty.
+type StatsPage struct {
+	// Fine and Coarse are the timelines of windows and of
+	// applications.
 	Fine   *Timeline
 	Coarse *Timeline
-	Agg    *AggTime
+
+	// Agg are the bar charts of the applications most often active,
+	// visible and open.
+	Agg *AggTime
+
+	// Breakdowns are additional bar charts of active time, each
+	// grouping windows along a different dimension.
+	Breakdowns []*BarChart
+
+	// Heatmaps show when the user was active.
+	Heatmaps []*Heatmap
+
+	// Switches counts the switches between applications, shown at
+	// the top of the page.
+	Switches *Switches
+
+	// Days are the spans of activity of each day, shown next to the
+	// heatmaps.
+	Days []*DaySpan
+
+	// Hours are the typical working hours of each day of the week,
+	// shown as bands.
+	Hours []*WeekdayHours
+
+	// Windows are the numbers of windows open and visible, shown as
+	// trend lines by day and by hour of the day.
+	Windows *WindowCounts
+
+	// Focus are the focus scores of each day, shown as a trend line.
+	Focus []*DayFocus
+
+	// Goals are the daily progress towards each goal, shown as
+	// progress bars.
+	Goals []*GoalProgress
+
+	// Flow is the flow of the day, shown as a stacked area chart.
+	Flow *DayFlow
+
+	// Streaks are the longest uninterrupted stretches of time spent in
+	// an application, at most statsStreaks of them, one per
+	// application.
+	Streaks []*Stretch
+
+	// PartialShare is the share of the snapshots whose active window
+	// the tracker couldn't fully identify.
+	PartialShare float64
+
+	// LockDetected is whether any of the snapshots was taken while the
+	// screen was locked, which tells whether the tracker could detect
+	// it.
+	LockDetected bool
+
+	// Interval is the time between snapshots, as returned by
+	// Stream.SamplingInterval, and IntervalEstimated whether it was
+	// estimated from their spacing because they don't say.
+	Interval          time.Duration
+	IntervalEstimated bool
+
+	// Colors override the colors of the labels they list in every
+	// chart, once passed to thyme.setColors in the page.
+	Colors Colors
+
+	// Icons are the icons of the applications of the bar charts whose
+	// labels are applications, once passed to thyme.setIcons in the
+	// page.
+	Icons Icons
 }
 
-// statsTmpl is the HTML template for the page rendered by the `Stats`
-// function.
-var statsTmpl = template.Must(template.New("").Funcs(map[string]interface{}{
-	"timeToJS": timeToJS,
-}).Parse(`<html>
-  <head>
-	<meta charset="utf-8">
-	<style>
-		.description {
-			font-family: Roboto;
-			font-size: 16px;
-			padding: 16px 0;
-			color: rgb(117, 117, 117);
+// apps returns the applications of the bar charts of p whose labels
+// are applications, with the processes of their windows in stream.
+func (p *StatsPage) apps(stream *Stream) map[string][]string {
+	apps := make(map[string][]string)
+	for _, c := range append(append([]*BarChart{}, p.Agg.Charts...), p.Breakdowns...) {
+		if !c.Apps {
+			continue
+		}
+		for _, b := range c.OrderedBars() {
+			if _, ok := apps[b.Label]; !ok {
+				apps[b.Label] = nil
+			}
+		}
+	}
+	seen := make(map[string]bool)
+	for _, snap := range stream.Snapshots {
+		for _, w := range snap.Windows {
+			app := appID(w)
+			if _, ok := apps[app]; !ok || w.Process == "" || seen[app+"\x00"+w.Process] {
+				continue
+			}
+			seen[app+"\x00"+w.Process] = true
+			apps[app] = append(apps[app], w.Process)
 		}
-	</style>
-
-    <script type="text/javascript" src="https://www.gstatic.com/charts/loader.js"></script>
-    <script type="text/javascript">
-      google.charts.load('current', {'packages':['corechart', 'bar', 'timeline']});
-	</script>
-
-	{{with .Coarse}}
-    <script type="text/javascript">
-      google.charts.setOnLoadCallback(drawChartCoarse);
-      function drawChartCoarse() {
-        var container = document.getElementById('timeline_coarse');
-        var chart = new google.visualization.Timeline(container);
-        var dataTable = new google.visualization.DataTable();
-
-        dataTable.addColumn({ type: 'string', id: 'Status' });
-		dataTable.addColumn({ type: 'string', id: 'Name' });
-        dataTable.addColumn({ type: 'date', id: 'Start' });
-        dataTable.addColumn({ type: 'date', id: 'End' });
-        dataTable.addRows([
-		{{range .Rows.Active}}
-			[
-				"Active",
-				{{printf "%q" .Label}},
-				{{timeToJS .Start}},
-				{{timeToJS .End}},
-			],
-		{{end}}
-		{{range .Rows.Visible}}
-			[
-				"Visible",
-				{{printf "%q" .Label}},
-				{{timeToJS .Start}},
-				{{timeToJS .End}},
-			],
-		{{end}}
-		{{range .Rows.All}}
-			[
-				"All",
-				{{printf "%q" .Label}},
-				{{timeToJS .Start}},
-				{{timeToJS .End}},
-			],
-		{{end}}
-		]);
-
-		var options = {
-			timeline: { showRowLabels: true },
-		};
-        chart.draw(dataTable, options);
-      }
-    </script>
-	{{end}}
-
-	{{range $chart := .Agg.Charts}}
-	<script type="text/javascript">
-	google.charts.setOnLoadCallback(drawBarChart{{$chart.ID}});
-	function drawBarChart{{$chart.ID}}() {
-      var data = google.visualization.arrayToDataTable([
-        ['Application', 'Number of samples'],
-		{{range $chart.OrderedBars}}
-		[{{printf "%q" .Label}}, {{.Count}}],
-		{{end}}
-      ]);
-
-      var options = {
-        chart: {
-          title: '{{$chart.Title}}'
-        },
-		legend: { position: "none" },
-        hAxis: {
-          title: '{{$chart.YLabel}}',
-          minValue: 0,
-        },
-        vAxis: {
-          title: '{{$chart.XLabel}}'
-        },
-        bars: 'horizontal',
-        height: 600
-      };
-      var material = new google.charts.Bar(document.getElementById('bar_chart_{{$chart.ID}}'));
-      material.draw(data, options);
-    }
-	</script>
-	{{end}}
-
-	{{with .Fine}}
-    <script type="text/javascript">
-      google.charts.setOnLoadCallback(drawChartFine);
-      function drawChartFine() {
-        var container = document.getElementById('timeline_fine');
-        var chart = new google.visualization.Timeline(container);
-        var dataTable = new google.visualization.DataTable();
-
-        dataTable.addColumn({ type: 'string', id: 'Status' });
-		dataTable.addColumn({ type: 'string', id: 'Name' });
-        dataTable.addColumn({ type: 'date', id: 'Start' });
-        dataTable.addColumn({ type: 'date', id: 'End' });
-        dataTable.addRows([
-		{{range .Rows.Active}}
-			[
-				"Active",
-				{{printf "%q" .Label}},
-				{{timeToJS .Start}},
-				{{timeToJS .End}},
-			],
-		{{end}}
-		{{range .Rows.Visible}}
-			[
-				"Visible",
-				{{printf "%q" .Label}},
-				{{timeToJS .Start}},
-				{{timeToJS .End}},
-			],
-		{{end}}
-		{{range .Rows.All}}
-			[
-				"All",
-				{{printf "%q" .Label}},
-				{{timeToJS .Start}},
-				{{timeToJS .End}},
-			],
-		{{end}}
-		]);
-
-		var options = {
-			timeline: { showRowLabels: true },
-		};
-        chart.draw(dataTable, options);
-      }
-    </script>
-	{{end}}
-
-
-  </head>
-  <body>
-
-	<div class="description">
-		This is a coarse-grained timeline of all the applications you use over the course of the day. Every bar represents an application.
-	</div>
-    <div id="timeline_coarse" style="min-height: 500px;"></div>
-	<hr>
-
-	<div class="description">
-		This is a fine-grained timeline of all the applications you use over the course of the day. Every bar represents a distinct window.
-	</div>
-    <div id="timeline_fine" style="min-height: 500px;"></div>
-	<hr>
-
-	{{range $chart := .Agg.Charts}}
-	<div id="bar_chart_{{$chart.ID}}"></div>
-	<hr>
-	{{end}}
-
-  </body>
-</html>`))
+	}
+	return apps
+}
+
+// statsStreaks is the number of applications whose longest streak is
+// shown by Stats.
+const statsStreaks = 10
+
+// newStatsPage computes the aggregates shown by Stats from stream.
+// Breakdowns that would have a single bar, or that depend on
+// categories when cats has no rules, are left out.
+func newStatsPage(stream *Stream, cats *Categories) *StatsPage {
+	page := &StatsPage{
+		Fine:     NewTimeline(stream, func(w *Window) string { return w.Name }),
+		Coarse:   NewTimeline(stream, appID),
+		Agg:      NewAggTime(stream, appID),
+		Switches: NewSwitches(stream),
+		Days:     NewDaySpans(stream),
+		Hours:    NewWeekdayHours(stream),
+		Windows:  NewWindowCounts(stream),
+		Focus:    NewDayFocus(stream, cats),
+		Flow:     NewDayFlow(stream),
+	}
+	for _, c := range page.Agg.Charts {
+		c.Apps = true
+	}
+	page.Streaks = page.Switches.Longest[:min(len(page.Switches.Longest), statsStreaks)]
+	for _, snap := range stream.Snapshots {
+		page.LockDetected = page.LockDetected || snap.Locked
+	}
+	page.PartialShare = stream.PartialShare()
+	page.Interval, page.IntervalEstimated = stream.SamplingInterval()
+	if cats != nil && len(cats.Rules) > 0 {
+		page.Breakdowns = append(page.Breakdowns, NewCategoryChart(stream, cats))
+	}
+	if hours := NewHourChart(stream); len(hours.Series) > 0 {
+		page.Breakdowns = append(page.Breakdowns, hours)
+	}
+	if monitors := NewActiveChart(stream, "Monitors", "Monitor", "Active monitors by time", monitorOf); len(monitors.Series) > 1 {
+		page.Breakdowns = append(page.Breakdowns, monitors)
+	}
+	if desktops := NewActiveChart(stream, "Desktops", "Desktop", "Active desktops by time", (*Window).DesktopLabel); len(desktops.Series) > 1 {
+		page.Breakdowns = append(page.Breakdowns, desktops)
+	}
+	if meetings := NewActiveChart(stream.inMeetings(), "Meetings", "App", "Active applications during meetings by time", appID); len(meetings.Series) > 0 {
+		meetings.Apps = true
+		page.Breakdowns = append(page.Breakdowns, meetings)
+	}
+	if input := NewActiveChart(stream.withInput(), "Input", "App", "Active applications while typing, clicking or scrolling by time", appID); len(input.Series) > 0 {
+		input.Apps = true
+		page.Breakdowns = append(page.Breakdowns, input)
+	}
+	if displays := NewActiveChart(stream, "Displays", "Display", "Active X displays by time", func(w *Window) string { return w.Display }); len(displays.Series) > 1 {
+		page.Breakdowns = append(page.Breakdowns, displays)
+	}
+	if processes := NewActiveChart(stream, "Processes", "Process", "Active processes by time", func(w *Window) string { return w.Process }); len(processes.Series) > 0 {
+		page.Breakdowns = append(page.Breakdowns, processes)
+	}
+	if fullScreen := NewActiveChart(stream, "FullScreen", "App", "Active full-screen applications by time", fullScreenApp); len(fullScreen.Series) > 0 {
+		page.Breakdowns = append(page.Breakdowns, fullScreen)
+	}
+	if domains := NewActiveChart(stream, "Domains", "Domain", "Active browsing time by domain", Domain); len(domains.Series) > 0 {
+		page.Breakdowns = append(page.Breakdowns, domains)
+	}
+	if calendar := NewCalendarHeatmap(stream); len(calendar.Cells) > 0 {
+		page.Heatmaps = append(page.Heatmaps, calendar, NewWeekHeatmap(stream))
+		if cats != nil && len(cats.Rules) > 0 {
+			page.Heatmaps = append(page.Heatmaps, NewCategoryHourHeatmap(stream, cats))
+		}
+	}
+	return page
+}
+
+// The stylesheet and script that draw the charts are inlined into the
+// page rendered by Stats so that it works without network access.
+var (
+	//go:embed assets/report/report.css
+	reportCSS string
+
+	//go:embed assets/report/report.js
+	reportJS string
+
+	//go:embed assets/report/report.tmpl
+	reportTmpl string
+)
+
+// statsFuncs are the functions available to the templates of the page
+// rendered by WriteStats. See LoadStatsTemplate.
+var statsFuncs = template.FuncMap{
+	"timeToJS":        timeToJS,
+	"hoursMinutes":    hoursMinutes,
+	"percent":         func(f float64) string { return fmt.Sprintf("%.0f", 100*f) },
+	"flowSlotMinutes": func() int { return int(FlowSlot / time.Minute) },
+	"hourLabel":       hourLabel,
+	"reportCSS":       func() template.CSS { return template.CSS(reportCSS) },
+	"reportJS":        func() template.JS { return template.JS(reportJS) },
+}
+
+// statsTmpl is the default template of the page rendered by
+// WriteStats.
+var statsTmpl = template.Must(template.New("report.tmpl").Funcs(statsFuncs).Parse(reportTmpl))
+
+// LoadStatsTemplate reads an html/template for the page rendered by
+// WriteStats from the file at path, e.g. to brand reports. The
+// template is executed with a *StatsPage, and may call the following
+// functions:
+//
+//   - timeToJS, which turns a time.Time into a JavaScript Date
+//   - hoursMinutes, which formats a time.Duration as e.g. "1h05m"
+//   - percent, which formats a share between 0 and 1 as a percentage
+//   - flowSlotMinutes, which returns the length of the slots of
+//     StatsPage.Flow, in minutes
+//   - hourLabel, which labels an hour of the day, e.g. "09:00"
+//   - reportCSS and reportJS, which return the stylesheet and the
+//     script of the default template, whose functions draw the charts
+//
+// The default template, assets/report/report.tmpl in the source tree,
+// shows how to use them. Besides syntax errors and references to
+// functions that don't exist, the errors that html/template detects
+// when it escapes the template, and references to fields that don't
+// exist in the parts of it that a page with a single window and goal
+// runs, are reported here rather than when the page is rendered.
+func LoadStatsTemplate(path string) (*template.Template, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(statsFuncs).Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse report template %s: %s", path, err)
+	}
+	// Templates are escaped when they are first executed, so render a
+	// small page, which also catches references to fields that don't
+	// exist in the parts of the template it executes.
+	if err := tmpl.Execute(io.Discard, templateCheckPage()); err != nil {
+		return nil, fmt.Errorf("invalid report template %s: %s", path, err)
+	}
+	return tmpl, nil
+}
+
+// templateCheckPage returns the page LoadStatsTemplate renders
+// templates with: that of a single snapshot of a single window, in a
+// category with a goal, so that the template's range and if actions
+// over the applications, the breakdowns and the goals run their
+// bodies too.
+func templateCheckPage() *StatsPage {
+	stream := &Stream{Interval: time.Minute, Snapshots: []*Snapshot{{
+		Time:    time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC),
+		Windows: []*Window{{ID: 1, Name: "main.go - thyme - Visual Studio Code", Process: "code"}},
+		Active:  1,
+		Visible: []int64{1},
+	}}}
+	cats := &Categories{Rules: []*CategoryRule{{Match: regexp.MustCompile("."), Category: "Development"}}}
+	page := newStatsPage(stream, cats)
+	page.Goals = NewGoalProgress(stream, cats, []*Goal{{Category: "Development", Min: time.Hour}})
+	return page
+}
+
+// fullScreenApp returns the application of w if it is full-screen,
+// and an empty string otherwise.
+func fullScreenApp(w *Window) string {
+	if !w.FullScreen {
+		return ""
+	}
+	return appID(w)
+}
+
+// AppID returns a string that identifies the application of the
+// window, w, as used to label applications in reports.
+func AppID(w *Window) string {
+	return appID(w)
+}
 
 // appID returns a string that identifies the application of the
 // window, w. It does so in best effort fashion. If the application
@@ -398,14 +627,15 @@ func appID(w *Window) string {
 	if w == nil {
 		return "(nil)"
 	}
-	if w.Info().App != "" {
-		return w.Info().App
+	info := w.Info()
+	if info.App != "" {
+		return info.App
 	}
-	if w.Info().SubApp != "" {
-		return fmt.Sprintf("%s :: %s", w.Info().App, w.Info().SubApp)
+	if info.SubApp != "" {
+		return fmt.Sprintf("%s :: %s", info.App, info.SubApp)
 	}
-	if w.Info().Title != "" {
-		return w.Info().Title
+	if info.Title != "" {
+		return info.Title
 	}
 	return w.Name
 }
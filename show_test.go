@@ -0,0 +1,153 @@
+package thyme
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// benchSnapshots is the number of snapshots of the stream the stats
+// page is benchmarked over, about two months at 30s.
+const benchSnapshots = 200000
+
+// benchStream returns a synthetic stream of benchSnapshots snapshots
+// of 8 windows each, taken 30s apart, whose titles and active window
+// change every few snapshots as they would with a user at work. It is
+// only built once.
+var benchStream = sync.OnceValue(func() *Stream {
+	titles := []string{
+		"main.go - thyme - Visual Studio Code",
+		"Inbox - Gmail - Google Chrome",
+		"general | Slack",
+		"Slack - general",
+		"bash",
+		"README.md - Sublime Text",
+		"Issue 12 · repo - Mozilla Firefox",
+	}
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	stream := &Stream{Interval: 30 * time.Second}
+	for i := 0; i < benchSnapshots; i++ {
+		var windows []*Window
+		for k := 0; k < 8; k++ {
+			name := fmt.Sprintf("%d %s", (i/50+k)%40, titles[(i/20+k)%len(titles)])
+			windows = append(windows, &Window{ID: int64(k + 1), Name: name})
+		}
+		stream.Snapshots = append(stream.Snapshots, &Snapshot{
+			Time:    start.Add(time.Duration(i) * stream.Interval),
+			Windows: windows,
+			Active:  int64(i/7%8 + 1),
+			Visible: []int64{1, 2},
+		})
+	}
+	return stream
+})
+
+func BenchmarkNewStatsPage(b *testing.B) {
+	stream := benchStream()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		newStatsPage(stream, nil)
+	}
+}
+
+func BenchmarkWriteStats(b *testing.B) {
+	stream := benchStream()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := WriteStats(io.Discard, stream, StatsOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestWriteStatsEscapesStreaks checks that the names of applications,
+// which come from window titles, are escaped in the table of the
+// longest streaks.
+func TestWriteStatsEscapesStreaks(t *testing.T) {
+	const app = `<script>alert("streak")</script>`
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	stream := &Stream{Interval: time.Minute}
+	for i := 0; i < 10; i++ {
+		stream.Snapshots = append(stream.Snapshots, &Snapshot{
+			Time:    start.Add(time.Duration(i) * time.Minute),
+			Windows: []*Window{{ID: 1, Name: "notes - " + app}},
+			Active:  1,
+			Visible: []int64{1},
+		})
+	}
+	var b strings.Builder
+	if err := WriteStats(&b, stream, StatsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(b.String(), app) {
+		t.Errorf("the page holds the application name %s unescaped", app)
+	}
+	if !strings.Contains(b.String(), template.HTMLEscapeString(app)) {
+		t.Errorf("the page doesn't list the streak in %s", app)
+	}
+}
+
+// TestWriteStatsOffline checks that the stats page loads no script or
+// stylesheet from the network, so that it can be viewed offline.
+func TestWriteStatsOffline(t *testing.T) {
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	stream := &Stream{Interval: time.Minute}
+	for i := 0; i < 3; i++ {
+		stream.Snapshots = append(stream.Snapshots, &Snapshot{
+			Time:    start.Add(time.Duration(i) * time.Minute),
+			Windows: []*Window{{ID: 1, Name: "main.go - thyme - Visual Studio Code"}, {ID: 2, Name: "bash"}},
+			Active:  int64(i%2 + 1),
+			Visible: []int64{1, 2},
+		})
+	}
+	var b strings.Builder
+	if err := WriteStats(&b, stream, StatsOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	page := b.String()
+	for _, remote := range []string{`<script src="http`, `<link href="http`} {
+		if strings.Contains(page, remote) {
+			t.Errorf("the page loads %s...", remote)
+		}
+	}
+	if !strings.Contains(page, "var thyme = ") {
+		t.Error("the page doesn't inline its charting script")
+	}
+}
+
+func TestLoadStatsTemplate(t *testing.T) {
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name, tmpl string
+		ok         bool
+	}{
+		{name: "default", tmpl: reportTmpl, ok: true},
+		{name: "streaks", tmpl: `{{range .Streaks}}{{.App}}: {{hoursMinutes .Duration}}{{end}}`, ok: true},
+		{name: "syntax", tmpl: `{{range .Streaks}}`},
+		{name: "function", tmpl: `{{minutes .Interval}}`},
+		{name: "field", tmpl: `{{.Intreval}}`},
+		{name: "field in a range over applications", tmpl: `{{range .Flow.Apps}}{{.Nmae}}{{end}}`},
+		{name: "field in a range over streaks", tmpl: `{{range .Streaks}}{{.Ap}}{{end}}`},
+		{name: "field in a range over goals", tmpl: `{{range .Goals}}{{.Gaol}}{{end}}`},
+		{name: "field in a range over breakdowns", tmpl: `{{range .Breakdowns}}{{.Titel}}{{end}}`},
+	} {
+		path := filepath.Join(dir, tt.name+".tmpl")
+		if err := os.WriteFile(path, []byte(tt.tmpl), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := LoadStatsTemplate(path)
+		if tt.ok && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("%s: template %s was accepted", tt.name, tt.tmpl)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package thyme
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Store is where `thyme track` records snapshots.
+type Store interface {
+	// Append stores snap after the snapshots already in the store. If
+	// the store already has a snapshot taken at the same time, e.g.
+	// because the clock's resolution is coarse or a call was retried,
+	// snap is dropped and Append succeeds.
+	Append(snap *Snapshot) error
+
+	// Last returns the most recent snapshot in the store, or nil if the
+	// store is empty. Stores that several machines record in return
+	// the most recent one recorded on this machine.
+	Last() (*Snapshot, error)
+
+	// UpdateLast replaces the snapshot returned by Last with snap,
+	// which has the same Time. It is used to merge snapshots that are
+	// identical to the previous one into it.
+	UpdateLast(snap *Snapshot) error
+
+	// Load returns every snapshot in the store, ordered by time.
+	Load() (*Stream, error)
+
+	// Prune deletes the snapshots taken before before, reclaims the
+	// space they took, and returns how many it deleted.
+	Prune(before time.Time) (int64, error)
+
+	// Close releases the resources held by the store. Calling it more
+	// than once is harmless.
+	Close() error
+}
+
+// OpenStore opens the store described by dsn and brings its schema up
+// to date: a PostgreSQL database if dsn is a postgres:// or
+// postgresql:// connection string, the file of JSON lines at dsn if it
+// ends in .jsonl, and the sqlite database file at dsn otherwise. It
+// returns the migrations that were applied, which only exist for
+// sqlite databases.
+func OpenStore(dsn string) (Store, []*Migration, error) {
+	if isPostgresDSN(dsn) {
+		s, err := openPostgresStore(dsn)
+		return s, nil, err
+	}
+	if IsJSONLinesPath(dsn) {
+		s, err := OpenJSONLinesStore(dsn)
+		return s, nil, err
+	}
+	return openSQLiteStore(dsn)
+}
+
+// StoreName returns dsn, the description of a store given to
+// OpenStore, with any password it contains masked, so that it can be
+// shown to the user.
+func StoreName(dsn string) string {
+	if !isPostgresDSN(dsn) {
+		return dsn
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "postgres database"
+	}
+	return u.Redacted()
+}
+
+func isPostgresDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// sqliteStore is a Store backed by a sqlite database file, with one row
+// per snapshot in the data table.
+type sqliteStore struct {
+	db *sql.DB
+
+	// migrated is whether the schema of the database is up to date.
+	// A new database only gets its schema in the transaction that
+	// appends its first snapshot, so that it never has a data table
+	// without it, unless it is read or updated first.
+	migrated atomic.Bool
+}
+
+// openSQLiteStore opens the sqlite database at path and brings its
+// schema up to date, unless it is still empty.
+func openSQLiteStore(path string) (*sqliteStore, []*Migration, error) {
+	db, err := openSQLite(path, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	s := &sqliteStore{db: db}
+	var tables int
+	if err := db.QueryRow("SELECT count(*) FROM sqlite_master").Scan(&tables); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	if tables == 0 {
+		return s, nil, nil
+	}
+	applied, err := Migrate(db)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	s.migrated.Store(true)
+	return s, applied, nil
+}
+
+// migrate brings the schema of the database up to date, if it isn't
+// known to be yet.
+func (s *sqliteStore) migrate() error {
+	if s.migrated.Load() {
+		return nil
+	}
+	if _, err := Migrate(s.db); err != nil {
+		return err
+	}
+	s.migrated.Store(true)
+	return nil
+}
+
+// Append brings the schema up to date and inserts snap in a single
+// transaction, so that thyme track either records its first snapshot
+// in a new database along with the tables it goes in, or leaves the
+// database empty.
+func (s *sqliteStore) Append(snap *Snapshot) error {
+	out, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return retryLocked(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if !s.migrated.Load() {
+			if _, err := migrate(tx); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec("INSERT OR IGNORE INTO data(time, value) values(?,?)", snap.Time, out); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		s.migrated.Store(true)
+		return nil
+	})
+}
+
+func (s *sqliteStore) Last() (*Snapshot, error) {
+	if !s.migrated.Load() {
+		// The database was empty when it was opened.
+		return nil, nil
+	}
+	return lastSnapshot(s.db.QueryRow("SELECT value FROM data ORDER BY time DESC LIMIT 1"))
+}
+
+func (s *sqliteStore) UpdateLast(snap *Snapshot) error {
+	out, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if err := s.migrate(); err != nil {
+		return err
+	}
+	return retryLocked(func() error {
+		_, err := s.db.Exec("UPDATE data SET value = ?, end_time = ? WHERE rowid = (SELECT rowid FROM data ORDER BY time DESC LIMIT 1)", out, snap.EndTime)
+		return err
+	})
+}
+
+func (s *sqliteStore) Load() (*Stream, error) {
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+	return loadSQLiteStream(s.db)
+}
+
+func (s *sqliteStore) Prune(before time.Time) (int64, error) {
+	if err := s.migrate(); err != nil {
+		return 0, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	// Times are stored as text with their UTC offset, which julianday
+	// takes into account.
+	res, err := tx.Exec("DELETE FROM data WHERE julianday(time) < julianday(?)", before)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	// VACUUM can't run inside a transaction.
+	if _, err := s.db.Exec("VACUUM"); err != nil {
+		return n, fmt.Errorf("could not reclaim space: %s", err)
+	}
+	return n, nil
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// lastSnapshot decodes the snapshot in the value column of row, which
+// may be empty.
+func lastSnapshot(row *sql.Row) (*Snapshot, error) {
+	var value string
+	switch err := row.Scan(&value); err {
+	case nil:
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+	var snap Snapshot
+	if err := json.Unmarshal([]byte(value), &snap); err != nil {
+		return nil, fmt.Errorf("could not decode snapshot %q: %s", value, err)
+	}
+	return &snap, nil
+}
@@ -0,0 +1,141 @@
+package thyme
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestSQLiteAppendSameTime(t *testing.T) {
+	s := openTestSQLite(t)
+	at := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	first := &Snapshot{Time: at, Windows: []*Window{{ID: 1, Name: "main.go - Code"}}, Active: 1}
+	second := &Snapshot{Time: at, Windows: []*Window{{ID: 2, Name: "~ - Terminal"}}, Active: 2}
+	for _, snap := range []*Snapshot{first, second} {
+		if err := s.Append(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(stream.Snapshots); n != 1 {
+		t.Fatalf("got %d snapshots, want 1", n)
+	}
+	if got := stream.Snapshots[0].Active; got != first.Active {
+		t.Errorf("kept the snapshot with active window %d, want the first one, %d", got, first.Active)
+	}
+}
+
+var (
+	// commits counts the transactions committed through the
+	// sqlite3_commits driver.
+	commits            atomic.Int64
+	registerCommitHook sync.Once
+)
+
+// openCountingSQLite opens a new sqlite database in a file that is
+// removed at the end of the test, through a driver that counts its
+// commits in commits.
+func openCountingSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	registerCommitHook.Do(func() {
+		sql.Register("sqlite3_commits", &sqlite3.SQLiteDriver{ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			conn.RegisterCommitHook(func() int {
+				commits.Add(1)
+				return 0
+			})
+			return nil
+		}})
+	})
+	db, err := sql.Open("sqlite3_commits", filepath.Join(t.TempDir(), "thyme.db")+"?_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestSQLiteAppendCreatesSchemaInSameTransaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "thyme.db")
+	s, applied, err := openSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if len(applied) != 0 {
+		t.Errorf("opening a new database applied %d migrations, want them left to the first snapshot", len(applied))
+	}
+	if last, err := s.Last(); err != nil || last != nil {
+		t.Errorf("Last on a new database returned %v, %v, want no snapshot", last, err)
+	}
+	var tables int
+	if err := s.db.QueryRow("SELECT count(*) FROM sqlite_master").Scan(&tables); err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("opening a new database created %d tables, want none", tables)
+	}
+
+	// The same, with the commits counted.
+	counted := &sqliteStore{db: openCountingSQLite(t)}
+	before := commits.Load()
+	if err := counted.Append(&Snapshot{Time: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)}); err != nil {
+		t.Fatal(err)
+	}
+	if n := commits.Load() - before; n != 1 {
+		t.Errorf("appending the first snapshot took %d commits, want 1", n)
+	}
+	var version int
+	if err := counted.db.QueryRow("SELECT version FROM schema_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != SchemaVersion() {
+		t.Errorf("schema version %d, want %d", version, SchemaVersion())
+	}
+	stream, err := counted.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(stream.Snapshots); n != 1 {
+		t.Errorf("got %d snapshots, want 1", n)
+	}
+}
+
+func TestJSONLinesPruneKeepsMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "thyme.jsonl")
+	s, err := OpenJSONLinesStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	start := time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		snap := &Snapshot{Time: start.Add(time.Duration(i) * time.Hour), Windows: []*Window{{ID: 1, Name: "bash"}}, Active: 1}
+		if err := s.Append(snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(path, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.Prune(start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Errorf("pruned %d snapshots, want 1", n)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := fi.Mode().Perm(); mode != 0640 {
+		t.Errorf("pruned file has mode %v, want %v", mode, os.FileMode(0640))
+	}
+}
@@ -0,0 +1,83 @@
+package thyme
+
+import "time"
+
+// Summary is a machine-readable digest of a Stream. Its totals are
+// computed by Aggregate, as those of `thyme top` are, and its JSON
+// encoding is what `thyme show -w json` prints.
+type Summary struct {
+	// Start and End are the times of the first and last snapshots.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Apps is the time spent in each application, ordered by
+	// decreasing active time.
+	Apps []*Total `json:"apps"`
+
+	// Titles is the time spent in each window, identified by its
+	// full name, ordered by decreasing active time.
+	Titles []*Total `json:"titles"`
+
+	// Sessions lists, in chronological order, the periods during
+	// which a single application stayed active.
+	Sessions []*SummarySession `json:"sessions"`
+
+	// GroupBy names the grouping of Groups, the time spent in each
+	// group of windows. Both are only set on request, with Group.
+	GroupBy string   `json:"group_by,omitempty"`
+	Groups  []*Total `json:"groups,omitempty"`
+}
+
+// Total is the time an application or window spent active, visible,
+// and open. The *Seconds fields are derived from the time between
+// snapshots; the *Samples fields count the samples the snapshots stand
+// for, including those merged into them when they were stored.
+type Total struct {
+	Label          string  `json:"label"`
+	ActiveSeconds  float64 `json:"active_seconds"`
+	VisibleSeconds float64 `json:"visible_seconds"`
+	OpenSeconds    float64 `json:"open_seconds"`
+	ActiveSamples  int     `json:"active_samples"`
+	VisibleSamples int     `json:"visible_samples"`
+	OpenSamples    int     `json:"open_samples"`
+}
+
+// SummarySession is a period during which a single application
+// stayed active.
+type SummarySession struct {
+	App     string    `json:"app"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Seconds float64   `json:"seconds"`
+}
+
+// Summarize computes the Summary of stream.
+func Summarize(stream *Stream) *Summary {
+	apps, _ := LookupGrouping("app")
+	titles, _ := LookupGrouping("title")
+	summary := &Summary{
+		Apps:     apps.Totals(stream, nil),
+		Titles:   titles.Totals(stream, nil),
+		Sessions: []*SummarySession{},
+	}
+	if tl := NewTimeline(stream, appID); tl != nil {
+		summary.Start, summary.End = tl.Start, tl.End
+		for _, r := range tl.Rows["Active"] {
+			summary.Sessions = append(summary.Sessions, &SummarySession{
+				App:     r.Label,
+				Start:   r.Start,
+				End:     r.End,
+				Seconds: r.End.Sub(r.Start).Seconds(),
+			})
+		}
+	}
+	return summary
+}
+
+// Group sets the Groups of s to the totals of the groups of g in
+// stream, which s summarizes, with cats determining the category of
+// windows.
+func (s *Summary) Group(stream *Stream, g *Grouping, cats *Categories) {
+	s.GroupBy = g.Name
+	s.Groups = g.Totals(stream, cats)
+}
@@ -0,0 +1,100 @@
+package thyme
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// palette is the palette of report.js, whose colors charts rendered
+// as SVG share.
+var palette = []string{
+	"#4285f4", "#db4437", "#f4b400", "#0f9d58", "#ab47bc", "#00acc1",
+	"#ff7043", "#9e9d24", "#5c6bc0", "#f06292", "#00796b", "#c2185b",
+	"#7e57c2", "#8d6e63", "#26a69a", "#d4e157",
+}
+
+// labelColor returns the color of label, the same as report.js gives
+// it.
+func labelColor(label string) string {
+	var h int32
+	for _, c := range utf16.Encode([]rune(label)) {
+		h = h*31 + int32(c)
+	}
+	i := int64(h)
+	if i < 0 {
+		i = -i
+	}
+	return palette[i%int64(len(palette))]
+}
+
+// StatsSVG renders the main chart of the page rendered by WriteStats as a
+// standalone SVG image, e.g. to embed in a README: the bar chart of
+// active time grouped by opts.Group if it isn't nil, and of the
+// applications most often active otherwise. The other options that
+// don't apply to an image are ignored.
+func StatsSVG(w io.Writer, stream *Stream, opts StatsOptions) error {
+	chart := NewAggTime(stream, appID).Charts[0]
+	if opts.Group != nil {
+		chart = NewGroupChart(stream, opts.Group, opts.Categories)
+	}
+	return WriteBarChartSVG(w, chart, opts)
+}
+
+// Dimensions of bar charts rendered as SVG, in pixels.
+const (
+	svgWidth      = 640
+	svgLabelWidth = 200
+	svgValueWidth = 60
+	svgRowHeight  = 20
+	svgTitleSpace = 36
+)
+
+// WriteBarChartSVG writes chart to w as a standalone SVG image with
+// one horizontal bar per label, in the order of OrderedBars, colored
+// as opts.Colors says.
+func WriteBarChartSVG(w io.Writer, chart *BarChart, opts StatsOptions) error {
+	bars := chart.OrderedBars()
+	longest := 0
+	for _, b := range bars {
+		longest = max(longest, b.Count)
+	}
+	height := svgTitleSpace + svgRowHeight*max(len(bars), 1) + 8
+	barSpace := float64(svgWidth - svgLabelWidth - svgValueWidth)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Roboto, Helvetica Neue, Arial, sans-serif" font-size="12">`+"\n", svgWidth, height, svgWidth, height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+	fmt.Fprintf(&b, `<text x="0" y="18" font-size="16" fill="#212121">%s</text>`+"\n", html.EscapeString(chart.Title))
+	if len(bars) == 0 {
+		fmt.Fprintf(&b, `<text x="0" y="%d" fill="#757575">No data.</text>`+"\n", svgTitleSpace+14)
+	}
+	for i, bar := range bars {
+		y := svgTitleSpace + i*svgRowHeight
+		width := 0.0
+		if longest > 0 {
+			width = barSpace * float64(bar.Count) / float64(longest)
+		}
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end" fill="#212121">%s</text>`+"\n", svgLabelWidth-8, y+14, html.EscapeString(truncateLabel(bar.Label, 32)))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"/>`+"\n", svgLabelWidth, y+3, width, svgRowHeight-6, opts.Colors.Of(bar.Label))
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" fill="#757575">%d</text>`+"\n", float64(svgLabelWidth)+width+6, y+14, bar.Count)
+	}
+	if chart.YLabel != "" {
+		fmt.Fprintf(&b, `<text x="%d" y="18" text-anchor="end" fill="#757575">%s</text>`+"\n", svgWidth, html.EscapeString(chart.YLabel))
+	}
+	b.WriteString("</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// truncateLabel shortens label to at most n characters, ending it with
+// an ellipsis if it had to be cut.
+func truncateLabel(label string, n int) string {
+	r := []rune(label)
+	if len(r) <= n {
+		return label
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,114 @@
+package thyme
+
+import (
+	"sort"
+	"time"
+)
+
+// Switches describes how often the user switched between
+// applications.
+type Switches struct {
+	// Count is the number of times the active application changed
+	// from one snapshot to the next.
+	Count int
+
+	// Active is the time during which there was an active window.
+	Active time.Duration
+
+	// Longest is the longest uninterrupted stretch of time spent in
+	// each application, ordered by decreasing duration.
+	Longest []*Stretch
+}
+
+// PerHour returns the number of switches per hour of active time.
+func (s *Switches) PerHour() float64 {
+	if s.Active <= 0 {
+		return 0
+	}
+	return float64(s.Count) / s.Active.Hours()
+}
+
+// Stretch is a period during which an application stayed active.
+type Stretch struct {
+	App   string
+	Start time.Time
+	End   time.Time
+}
+
+// Duration returns the length of the stretch.
+func (s *Stretch) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// NewSwitches counts the switches between applications in stream,
+// which must be in chronological order. Only consecutive snapshots that
+// both have an active window, and that aren't separated by a gap in
+// tracking, count: going idle or suspending the computer ends a stretch
+// without counting as a switch.
+func NewSwitches(stream *Stream) *Switches {
+	switches := &Switches{}
+	all, switched := stretches(stream)
+	longest := make(map[string]*Stretch)
+	for i, s := range all {
+		switches.Active += s.Duration()
+		if switched[i] {
+			switches.Count++
+		}
+		if l, exists := longest[s.App]; !exists || s.Duration() > l.Duration() {
+			longest[s.App] = s
+		}
+	}
+
+	for _, s := range longest {
+		switches.Longest = append(switches.Longest, s)
+	}
+	sort.Slice(switches.Longest, func(a, b int) bool {
+		da, db := switches.Longest[a].Duration(), switches.Longest[b].Duration()
+		if da != db {
+			return da > db
+		}
+		return switches.Longest[a].App < switches.Longest[b].App
+	})
+	return switches
+}
+
+// stretches returns the uninterrupted stretches of time spent in each
+// application in stream, which must be in chronological order, as
+// described by NewSwitches. switched[i] is whether stretch i started
+// with a switch from the application of the previous one.
+func stretches(stream *Stream) (all []*Stretch, switched []bool) {
+	durations := sampleDurations(stream)
+	var current *Stretch
+	var currentSwitched bool
+	end := func() {
+		if current == nil {
+			return
+		}
+		all = append(all, current)
+		switched = append(switched, currentSwitched)
+		current = nil
+	}
+
+	for i, snap := range stream.Snapshots {
+		win := snap.ActiveWindow()
+		if win == nil {
+			end()
+			continue
+		}
+		app := appID(win)
+		if current != nil && snap.Time.After(current.End) {
+			// There is a gap in tracking since the last snapshot.
+			end()
+		}
+		if current != nil && current.App != app {
+			end()
+			current, currentSwitched = &Stretch{App: app, Start: snap.Time}, true
+		}
+		if current == nil {
+			current, currentSwitched = &Stretch{App: app, Start: snap.Time}, false
+		}
+		current.End = snap.Time.Add(durations[i])
+	}
+	end()
+	return all, switched
+}
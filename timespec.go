@@ -0,0 +1,54 @@
+package thyme
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// longUnitRx matches the day and week components of a duration.
+var longUnitRx = regexp.MustCompile(`([0-9]*\.?[0-9]+)([dw])`)
+
+// ParseDuration is like time.ParseDuration, but also accepts days
+// ("d") and weeks ("w"), e.g. "7d" or "1w12h". A day is always 24
+// hours long.
+func ParseDuration(s string) (time.Duration, error) {
+	var convErr error
+	expanded := longUnitRx.ReplaceAllStringFunc(s, func(m string) string {
+		parts := longUnitRx.FindStringSubmatch(m)
+		n, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			convErr = err
+			return m
+		}
+		if parts[2] == "w" {
+			n *= 7
+		}
+		return strconv.FormatFloat(n*24, 'f', -1, 64) + "h"
+	})
+	if convErr != nil {
+		return 0, fmt.Errorf("invalid duration %q: %s", s, convErr)
+	}
+	d, err := time.ParseDuration(expanded)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// ParseTime parses s as either an absolute time, in RFC 3339 or
+// YYYY-MM-DD (local midnight) form, or as a duration before now in
+// the form accepted by ParseDuration, e.g. "24h" or "7d".
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: expected RFC 3339 (e.g. 2006-01-02T15:04:05Z07:00), a date (e.g. 2006-01-02), or a duration ago (e.g. 7d, 24h)", s)
+}
@@ -0,0 +1,132 @@
+package thyme
+
+import (
+	"strings"
+	"sync"
+)
+
+// titleSeparators separate the parts of window titles, e.g. a page's
+// title from the name of the browser showing it. Microsoft Edge uses a
+// left-to-right mark followed by a dash, and some of its titles spell
+// its name with a zero-width space.
+var titleSeparators = []string{" - ", " \u2014 ", " \u2013 ", "\u200e- "}
+
+// TitlePattern describes how an application lays out its window
+// titles, so that Window.Info can tell its name apart from the rest.
+type TitlePattern struct {
+	// App is the application name reported for matching windows.
+	App string
+
+	// Names are the spellings of the application's name that appear
+	// in its window titles.
+	Names []string
+
+	// First is set for applications that put their name before the
+	// rest of the title rather than after it.
+	First bool
+
+	// SubApps is set for applications, such as browsers, whose titles
+	// name a sub-application (e.g. a web app) just before the
+	// application name.
+	SubApps bool
+}
+
+// titlePatterns are the applications known to Window.Info.
+var titlePatterns = []*TitlePattern{
+	{App: "Google Chrome", Names: []string{"Google Chrome", "Chrome"}, SubApps: true},
+	{App: "Chromium", Names: []string{"Chromium"}, SubApps: true},
+	{App: "Mozilla Firefox", Names: []string{"Mozilla Firefox", "Firefox", "Mozilla Firefox Private Browsing", "Firefox Developer Edition", "Firefox Nightly"}, SubApps: true},
+	{App: "Microsoft Edge", Names: []string{"Microsoft Edge", "Microsoft\u200b Edge"}, SubApps: true},
+	{App: "Brave", Names: []string{"Brave"}, SubApps: true},
+	{App: "Opera", Names: []string{"Opera"}, SubApps: true},
+	{App: "Vivaldi", Names: []string{"Vivaldi"}, SubApps: true},
+	{App: "Visual Studio Code", Names: []string{"Visual Studio Code", "VSCodium"}},
+	{App: "Sublime Text", Names: []string{"Sublime Text", "Sublime Text (UNREGISTERED)"}},
+	{App: "Slack", Names: []string{"Slack"}, First: true},
+}
+
+// RegisterTitlePattern teaches Window.Info about the window titles of
+// another application. Patterns registered later take precedence over
+// earlier ones and over the built-in ones.
+func RegisterTitlePattern(p *TitlePattern) {
+	titleInfos.Lock()
+	defer titleInfos.Unlock()
+	titlePatterns = append([]*TitlePattern{p}, titlePatterns...)
+	clear(titleInfos.m)
+}
+
+// titleInfosSize is how many titles titleInfos holds at most before it
+// is emptied.
+const titleInfosSize = 1 << 16
+
+// titleInfos holds the metadata Window.Info extracted from the titles
+// it was given, since reports look at the same titles in snapshot
+// after snapshot. It is emptied when a title or document pattern is
+// registered, and its lock also guards titlePatterns and
+// documentPatterns.
+var titleInfos = struct {
+	sync.Mutex
+	m map[titleInfoKey]Winfo
+}{m: make(map[titleInfoKey]Winfo)}
+
+// titleInfoKey identifies the windows Window.Info returns the same
+// metadata for: those with the same title and App.
+type titleInfoKey struct {
+	name, app string
+}
+
+// matchTitlePattern returns the pattern, if any, whose application
+// name is part, considering only patterns that put the name first if
+// first is set and only ones that put it last otherwise.
+func matchTitlePattern(part string, first bool) *TitlePattern {
+	for _, p := range titlePatterns {
+		if p.First != first {
+			continue
+		}
+		for _, name := range p.Names {
+			if part == name {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// splitTitle splits name at each title separator. Each part comes
+// with its start and end offsets in name, so that runs of parts can
+// be recovered with their original separators.
+func splitTitle(name string) (parts []string, bounds [][2]int) {
+	// found holds the offset of the next occurrence of each separator
+	// at or after start, or -1 if there is none, so that titles with
+	// many separators are only searched once per separator occurrence.
+	found := make([]int, len(titleSeparators))
+	for k, sep := range titleSeparators {
+		found[k] = strings.Index(name, sep)
+	}
+	start := 0
+	for {
+		next, sepLen := -1, 0
+		for k, sep := range titleSeparators {
+			if found[k] > -1 && found[k] < start {
+				// The separator overlapped the one just consumed.
+				if i := strings.Index(name[start:], sep); i > -1 {
+					found[k] = start + i
+				} else {
+					found[k] = -1
+				}
+			}
+			if found[k] > -1 && (next == -1 || found[k] < next) {
+				next, sepLen = found[k], len(sep)
+			}
+		}
+		if next == -1 {
+			break
+		}
+		parts = append(parts, strings.TrimSpace(name[start:next]))
+		bounds = append(bounds, [2]int{start, next})
+		start = next + sepLen
+	}
+	parts = append(parts, strings.TrimSpace(name[start:]))
+	bounds = append(bounds, [2]int{start, len(name)})
+	return parts, bounds
+}
@@ -0,0 +1,124 @@
+package thyme
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestWindowInfo(t *testing.T) {
+	for _, tt := range []struct {
+		name, app string
+		want      Winfo
+	}{
+		{name: "Document — Google Docs — Chrome", want: Winfo{App: "Google Chrome", SubApp: "Google Docs", Title: "Document"}},
+		{name: "Inbox (3) - me@example.com - Gmail - Google Chrome", want: Winfo{App: "Google Chrome", SubApp: "Gmail", Title: "Inbox (3) - me@example.com"}},
+		{name: "Issue #12 · mehdidc/thyme — Mozilla Firefox", want: Winfo{App: "Mozilla Firefox", Title: "Issue #12 · mehdidc/thyme"}},
+		{name: "Wikipedia – Mozilla Firefox Private Browsing", want: Winfo{App: "Mozilla Firefox", Title: "Wikipedia"}},
+		{name: "YouTube \u200e- Microsoft Edge", want: Winfo{App: "Microsoft Edge", Title: "YouTube"}},
+		{name: "Pull requests - Microsoft\u200b Edge", want: Winfo{App: "Microsoft Edge", Title: "Pull requests"}},
+		{name: "New Tab - Brave", want: Winfo{App: "Brave", Title: "New Tab"}},
+		{name: "main.go - thyme - Visual Studio Code", want: Winfo{App: "Visual Studio Code", Title: "main.go - thyme", Document: "main.go"}},
+		{name: "notes.txt - VSCodium", want: Winfo{App: "Visual Studio Code", Title: "notes.txt", Document: "notes.txt"}},
+		{name: "README.md (~/src/thyme) - Sublime Text", want: Winfo{App: "Sublime Text", Title: "README.md (~/src/thyme)", Document: "README.md"}},
+		{name: "Slack - general - Acme", want: Winfo{App: "Slack", Title: "general - Acme"}},
+		{name: "Budget 2024.xlsx – Excel", want: Winfo{App: "Excel", Title: "Budget 2024.xlsx", Document: "Budget 2024.xlsx"}},
+		{name: "mehdi@host: ~/src", want: Winfo{Title: "mehdi@host: ~/src"}},
+		{name: "Calculator", app: "Microsoft.WindowsCalculator", want: Winfo{App: "Microsoft.WindowsCalculator", Title: "Calculator"}},
+	} {
+		w := &Window{Name: tt.name, App: tt.app}
+		if got := *w.Info(); got != tt.want {
+			t.Errorf("Info of %q is %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterTitlePatternConcurrently(t *testing.T) {
+	saved := titlePatterns
+	t.Cleanup(func() {
+		titleInfos.Lock()
+		defer titleInfos.Unlock()
+		titlePatterns = saved
+		clear(titleInfos.m)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				w := &Window{Name: fmt.Sprintf("Page %d - Thyme Browser", j)}
+				w.Info()
+				runtime.Gosched()
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		RegisterTitlePattern(&TitlePattern{App: "Thyme Browser", Names: []string{"Thyme Browser"}})
+		runtime.Gosched()
+	}
+	wg.Wait()
+
+	w := &Window{Name: "Page - Thyme Browser"}
+	if got, want := *w.Info(), (Winfo{App: "Thyme Browser", Title: "Page"}); got != want {
+		t.Errorf("Info of %q is %+v, want %+v", w.Name, got, want)
+	}
+}
+
+func FuzzInfo(f *testing.F) {
+	for _, seed := range []struct{ name, app string }{
+		{"", ""},
+		{" - ", ""},
+		{" - - ", ""},
+		{"main.go - ", ""},
+		{" - main.go", ""},
+		{"main.go - thyme - Visual Studio Code", ""},
+		{"Inbox - Gmail - Google Chrome", ""},
+		{"Slack | general | Acme", ""},
+		{"café — Mozilla Firefox", ""},
+		{"Résumé – Word", ""},
+		{"New tab ‎- Microsoft​ Edge", ""},
+		{"日本語のタイトル - メモ帳", ""},
+		{"🔥 - 🔥 - 🔥", ""},
+		{"~ - Terminal", "Terminal"},
+		{"\xff - \xfe", ""},
+	} {
+		f.Add(seed.name, seed.app)
+	}
+	f.Fuzz(func(t *testing.T, name, app string) {
+		w := &Window{Name: name, App: app}
+		info := w.Info()
+		if info == nil {
+			t.Fatal("nil Winfo")
+		}
+		if again := w.parseInfo(); again != *info {
+			t.Errorf("Info returned %+v, then parseInfo %+v", *info, again)
+		}
+		if app != "" && info.App != app {
+			t.Errorf("App is %q, want the window's %q", info.App, app)
+		}
+		if !strings.Contains(name, info.Title) {
+			t.Errorf("Title %q is not part of the name %q", info.Title, name)
+		}
+		if !strings.Contains(name, info.SubApp) {
+			t.Errorf("SubApp %q is not part of the name %q", info.SubApp, name)
+		}
+		if info.SubApp != "" && info.App == "" {
+			t.Errorf("SubApp %q without an App", info.SubApp)
+		}
+		if !strings.Contains(info.Title, info.Document) {
+			t.Errorf("Document %q is not part of the title %q", info.Document, info.Title)
+		}
+		if utf8.ValidString(name) && utf8.ValidString(app) {
+			for field, s := range map[string]string{"App": info.App, "SubApp": info.SubApp, "Document": info.Document, "Title": info.Title} {
+				if !utf8.ValidString(s) {
+					t.Errorf("%s %q is not valid UTF-8", field, s)
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package thyme
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// hashedPrefix starts the titles replaced by a TitleHasher, which are
+// followed by the hash and "]", e.g. "[hashed 3fa9c02e1b7d]", so that
+// reports show them for what they are.
+const hashedPrefix = "[hashed "
+
+// hashLength is how many hex digits of the hash of a title are kept,
+// enough for distinct titles not to share a hash in practice.
+const hashLength = 12
+
+// TitleHasher replaces the titles of windows with salted hashes of
+// them before snapshots are stored, so that the time spent in each
+// window can still be told apart without revealing what it showed.
+// The same title always gets the same hash with the same salt.
+type TitleHasher struct {
+	salt []byte
+}
+
+// LoadTitleHasher returns a TitleHasher with the salt in the file at
+// path, which is generated and written there, readable by the user
+// only, if it doesn't exist yet. Keep the file: titles hashed with
+// another salt get other hashes.
+func LoadTitleHasher(path string) (*TitleHasher, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		b, err = createSalt(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	salt, err := hex.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil || len(salt) == 0 {
+		return nil, fmt.Errorf("could not parse salt file %s: it should hold a hex-encoded salt", path)
+	}
+	return &TitleHasher{salt: salt}, nil
+}
+
+// saltWait is how long createSalt waits for another process that
+// created the salt file to write the salt in it.
+const saltWait = time.Second
+
+// createSalt generates a salt and writes it, hex-encoded, to a new file
+// at path, and returns what it wrote. If another process, e.g. thyme
+// stream started along with thyme track, created the file first, it
+// returns what that one wrote instead, so that both hash titles with
+// the same salt.
+func createSalt(path string) ([]byte, error) {
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("could not generate salt: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		// The file may be read before the salt is written in it.
+		for deadline := time.Now().Add(saltWait); ; time.Sleep(10 * time.Millisecond) {
+			b, err := os.ReadFile(path)
+			if err != nil || len(b) > 0 || time.Now().After(deadline) {
+				return b, err
+			}
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	b := []byte(hex.EncodeToString(salt) + "\n")
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return b, f.Close()
+}
+
+// Hash replaces, in place, the title of each window of snap with its
+// hash, keeping the part of the name that holds the application, as
+// Redact does, so that the window still counts towards it. Titles
+// already redacted, or hashed, are left as they are. URLs, which
+// reveal as much as titles, are cut down to their origin.
+func (h *TitleHasher) Hash(snap *Snapshot) {
+	if h == nil {
+		return
+	}
+	for _, w := range snap.Windows {
+		w.Name = replaceTitle(w.Name, h.hash)
+		w.URL = redactURL(w.URL)
+	}
+}
+
+// hash returns the hashed form of title.
+func (h *TitleHasher) hash(title string) string {
+	if title == Redacted || IsHashedTitle(title) || strings.TrimSpace(title) == "" {
+		return title
+	}
+	mac := hmac.New(sha256.New, h.salt)
+	mac.Write([]byte(title))
+	return hashedPrefix + hex.EncodeToString(mac.Sum(nil))[:hashLength] + "]"
+}
+
+// IsHashedTitle reports whether title was replaced by a TitleHasher.
+func IsHashedTitle(title string) bool {
+	return strings.HasPrefix(title, hashedPrefix) && strings.HasSuffix(title, "]")
+}
@@ -0,0 +1,42 @@
+package thyme
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestLoadTitleHasherConcurrently loads the hasher from several
+// goroutines at once, as thyme track and thyme stream started together
+// would, and checks that they all get the same salt.
+func TestLoadTitleHasherConcurrently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "title-salt")
+	hashers := make([]*TitleHasher, 8)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range hashers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			h, err := LoadTitleHasher(path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			hashers[i] = h
+		}()
+	}
+	close(start)
+	wg.Wait()
+	again, err := LoadTitleHasher(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := again.hash("main.go")
+	for i, h := range hashers {
+		if h != nil && h.hash("main.go") != want {
+			t.Errorf("hasher %d hashes main.go as %s, want %s as the salt written says", i, h.hash("main.go"), want)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package thyme
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// CaptureURL records the URL of the active tab in the active window of
+// snap, if that window is a supported browser, in its URL field.
+//
+// On macOS, Safari and Chromium-based browsers are asked for the URL
+// with AppleScript. On Linux, Chromium-based browsers are asked through
+// the DevTools protocol, which requires them to have been started with
+// --remote-debugging-port=9222. URLs can't be captured on other
+// systems; CaptureURL then does nothing.
+//
+// URLs can reveal a lot about the user, so trackers don't capture them
+// by default.
+func CaptureURL(ctx context.Context, snap *Snapshot) error {
+	win := snap.ActiveWindow()
+	if win == nil {
+		return nil
+	}
+	var u string
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		u, err = darwinActiveURL(ctx)
+	case "windows":
+		return nil
+	default:
+		u, err = devToolsActiveURL(ctx, win)
+	}
+	if err != nil {
+		return fmt.Errorf("could not capture URL of %q: %s", win.Name, err)
+	}
+	win.URL = u
+	return nil
+}
+
+// darwinURLScript prints the URL of the frontmost browser tab, or
+// nothing if the frontmost application isn't a supported browser.
+const darwinURLScript = `
+tell application "System Events" to set frontApp to name of first application process whose frontmost is true
+if frontApp is "Safari" then
+	tell application "Safari" to return URL of front document
+else if frontApp is in {"Google Chrome", "Chromium", "Brave Browser", "Microsoft Edge", "Vivaldi", "Arc"} then
+	using terms from application "Google Chrome"
+		tell application frontApp to return URL of active tab of front window
+	end using terms from
+end if
+return ""
+`
+
+func darwinActiveURL(ctx context.Context) (string, error) {
+	out, err := commandOutput(ctx, "osascript", "-e", darwinURLScript)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// devToolsAddr is where Chromium-based browsers serve the DevTools
+// protocol when started with --remote-debugging-port=9222.
+const devToolsAddr = "http://127.0.0.1:9222"
+
+// devToolsTarget is an entry of the DevTools protocol's /json/list.
+type devToolsTarget struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// devToolsActiveURL returns the URL of the tab shown in win, a browser
+// window, as reported by the browser's DevTools endpoint. Tabs are
+// listed most recently active first, so the first one whose title is
+// part of the window's title is the one shown. It returns nothing, and
+// no error, if win isn't a browser window or no browser is listening.
+func devToolsActiveURL(ctx context.Context, win *Window) (string, error) {
+	if !isBrowser(win) {
+		return "", nil
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", devToolsAddr+"/json/list", nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", err
+		}
+		// Remote debugging isn't enabled.
+		return "", nil
+	}
+	defer resp.Body.Close()
+	var targets []devToolsTarget
+	if err := json.NewDecoder(resp.Body).Decode(&targets); err != nil {
+		return "", fmt.Errorf("could not parse DevTools target list: %s", err)
+	}
+	for _, t := range targets {
+		if t.Type == "page" && t.Title != "" && strings.Contains(win.Name, t.Title) {
+			return t.URL, nil
+		}
+	}
+	return "", nil
+}
+
+// isBrowser reports whether win belongs to a web browser, judging by
+// the title patterns that name sub-applications.
+func isBrowser(win *Window) bool {
+	parts, _ := splitTitle(win.Name)
+	p := matchTitlePattern(parts[len(parts)-1], false)
+	return p != nil && p.SubApps
+}
+
+// Domain returns the registrable domain (e.g. "example.co.uk" for
+// "https://www.example.co.uk/page") of the URL of w, or an empty
+// string if w has no web URL.
+func Domain(w *Window) string {
+	if w == nil || w.URL == "" {
+		return ""
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	host := u.Hostname()
+	if domain, err := publicsuffix.EffectiveTLDPlusOne(host); err == nil {
+		return domain
+	}
+	// e.g. localhost or an IP address.
+	return host
+}
@@ -0,0 +1,270 @@
+package thyme
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+)
+
+// StreamError is a part of the JSON of a stream that couldn't be
+// decoded.
+type StreamError struct {
+	// Offset is where the part starts, in bytes from the start of the
+	// uncompressed JSON.
+	Offset int64
+
+	// Entry is the position of the part among the entries of the
+	// stream's Snapshots, or -1 if it isn't one of them.
+	Entry int
+
+	Err error
+}
+
+func (e *StreamError) Error() string {
+	if e.Entry < 0 {
+		return fmt.Sprintf("offset %d: %s", e.Offset, e.Err)
+	}
+	return fmt.Sprintf("offset %d: entry %d: %s", e.Offset, e.Entry, e.Err)
+}
+
+// snapshotStart matches the start of the entries of the streams
+// written by thyme, which ValidateStream looks for to resume after an
+// entry that can't be parsed.
+var snapshotStart = regexp.MustCompile(`\{\s*"Time"\s*:`)
+
+// ValidateStream decodes a stream from the JSON read from r, as
+// ReadStream does, but snapshot by snapshot, so that malformed ones
+// are reported rather than failing the whole stream. It returns the
+// stream of the snapshots that could be decoded, and errors describing
+// the others. Entries that aren't valid JSON, e.g. because the file
+// was truncated or overwritten in the middle, are skipped up to the
+// start of the next snapshot, if any. The error is only set if r can't
+// be read, or doesn't hold a stream at all.
+func ValidateStream(r io.Reader) (*Stream, []*StreamError, error) {
+	br := bufio.NewReader(r)
+	r = br
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	var problems []*StreamError
+	stream := &Stream{Snapshots: []*Snapshot{}}
+	dec := json.NewDecoder(r)
+	if t, err := dec.Token(); err != nil {
+		return nil, nil, err
+	} else if t != json.Delim('{') {
+		return nil, nil, fmt.Errorf("expected a JSON object, found %v", t)
+	}
+	// fields holds the fields read so far, in case they turn out to be
+	// those of a single snapshot.
+	var fields []byte
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return stream, append(problems, &StreamError{Offset: dec.InputOffset(), Entry: -1, Err: err}), nil
+		}
+		switch t {
+		case "Snapshots":
+			if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+				return nil, nil, fmt.Errorf("expected a list of snapshots at offset %d", dec.InputOffset())
+			}
+			sr := &snapshotReader{r: io.MultiReader(dec.Buffered(), r), off: dec.InputOffset()}
+			problems = append(problems, validateSnapshots(sr, stream)...)
+			if sr.err != nil && sr.err != io.EOF {
+				// What could be read of a truncated gzip file is kept.
+				problems = append(problems, &StreamError{Offset: sr.off + int64(len(sr.buf)), Entry: -1, Err: sr.err})
+			}
+			return stream, problems, nil
+		case "Time", "Windows":
+			// A single snapshot, as read by ReadStream, of which the
+			// fields read so far and the rest of the object, from the
+			// colon after the key, are decoded.
+			key, _ := json.Marshal(t)
+			rest := io.MultiReader(bytes.NewReader(append(append([]byte{'{'}, fields...), key...)), dec.Buffered(), r)
+			snap := &Snapshot{}
+			if err := json.NewDecoder(rest).Decode(snap); err != nil {
+				return stream, append(problems, &StreamError{Offset: 0, Entry: 0, Err: err}), nil
+			}
+			stream.Snapshots = append(stream.Snapshots, snap)
+			return stream, problems, nil
+		default:
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return stream, append(problems, &StreamError{Offset: dec.InputOffset(), Entry: -1, Err: err}), nil
+			}
+			key, _ := json.Marshal(t)
+			fields = append(append(append(append(fields, key...), ':'), raw...), ',')
+			var err error
+			switch t {
+			case "Version":
+				err = json.Unmarshal(raw, &stream.Version)
+			case "Interval":
+				err = json.Unmarshal(raw, &stream.Interval)
+			}
+			if err != nil {
+				problems = append(problems, &StreamError{Offset: dec.InputOffset(), Entry: -1, Err: err})
+			}
+		}
+	}
+	return stream, problems, nil
+}
+
+// snapshotReader reads the list of snapshots of a stream for
+// validateSnapshots. It only keeps in memory what it read but didn't
+// consume yet, at most an entry and a read ahead, which is enough to
+// skip the entries that aren't valid JSON.
+type snapshotReader struct {
+	r io.Reader
+
+	// buf holds what was read but not consumed, which starts at offset
+	// off of the JSON.
+	buf []byte
+	off int64
+
+	// err is the error r returned, after which it isn't read anymore.
+	err error
+}
+
+// snapshotReadSize is the least snapshotReader reads at once.
+const snapshotReadSize = 64 << 10
+
+// more reads more of the JSON into buf, and reports whether there was
+// any left. It reads at least as much as buf holds, so that decoding
+// an entry again as more of it is read takes linear time.
+func (s *snapshotReader) more() bool {
+	if s.err != nil {
+		return false
+	}
+	n := max(len(s.buf), snapshotReadSize)
+	s.buf = slices.Grow(s.buf, n)
+	m, err := io.ReadFull(s.r, s.buf[len(s.buf):len(s.buf)+n])
+	s.buf = s.buf[:len(s.buf)+m]
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	s.err = err
+	return m > 0
+}
+
+// consume drops the first n bytes of buf.
+func (s *snapshotReader) consume(n int) {
+	s.buf = s.buf[n:]
+	s.off += int64(n)
+}
+
+// peek returns the next byte of the JSON, and false if there is none.
+func (s *snapshotReader) peek() (byte, bool) {
+	for len(s.buf) == 0 {
+		if !s.more() {
+			return 0, false
+		}
+	}
+	return s.buf[0], true
+}
+
+// skipSpace consumes the white space that comes next.
+func (s *snapshotReader) skipSpace() {
+	for {
+		c, ok := s.peek()
+		if !ok || (c != ' ' && c != '\t' && c != '\n' && c != '\r') {
+			return
+		}
+		s.consume(1)
+	}
+}
+
+// value consumes the JSON value that comes next, and returns it.
+func (s *snapshotReader) value() (json.RawMessage, error) {
+	for {
+		dec := json.NewDecoder(bytes.NewReader(s.buf))
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == nil {
+			s.consume(int(dec.InputOffset()))
+			return raw, nil
+		}
+		if err != io.ErrUnexpectedEOF || !s.more() {
+			return nil, err
+		}
+	}
+}
+
+// resync consumes the JSON up to the start of the next snapshot after
+// the first n bytes of buf, and reports whether there is one.
+func (s *snapshotReader) resync(n int) bool {
+	for {
+		if loc := snapshotStart.FindIndex(s.buf[n:]); loc != nil {
+			s.consume(n + loc[0])
+			return true
+		}
+		// The start of a snapshot may be cut off by the end of buf,
+		// after its opening brace.
+		if i := bytes.LastIndexByte(s.buf[n:], '{'); i >= 0 {
+			s.consume(n + i)
+		} else {
+			s.consume(len(s.buf))
+		}
+		n = 0
+		if !s.more() {
+			return false
+		}
+	}
+}
+
+// validateSnapshots decodes the entries of the list of snapshots read
+// by s, which starts just after its opening bracket, and appends the
+// valid ones to stream.
+func validateSnapshots(s *snapshotReader, stream *Stream) []*StreamError {
+	var problems []*StreamError
+	for entry := 0; ; entry++ {
+		s.skipSpace()
+		c, ok := s.peek()
+		if !ok {
+			return append(problems, &StreamError{Offset: s.off, Entry: -1, Err: io.ErrUnexpectedEOF})
+		}
+		if c == ']' {
+			return problems
+		}
+		start := s.off
+		raw, err := s.value()
+		if err != nil {
+			problems = append(problems, &StreamError{Offset: start, Entry: entry, Err: err})
+			if !s.resync(1) {
+				return problems
+			}
+			continue
+		}
+		snap := &Snapshot{}
+		if err := json.Unmarshal(raw, snap); err != nil {
+			problems = append(problems, &StreamError{Offset: start, Entry: entry, Err: err})
+		} else {
+			stream.Snapshots = append(stream.Snapshots, snap)
+		}
+
+		s.skipSpace()
+		c, ok = s.peek()
+		switch {
+		case !ok:
+			return append(problems, &StreamError{Offset: s.off, Entry: -1, Err: io.ErrUnexpectedEOF})
+		case c == ',':
+			s.consume(1)
+		case c == ']':
+			return problems
+		default:
+			problems = append(problems, &StreamError{Offset: s.off, Entry: -1, Err: fmt.Errorf("unexpected %q after entry %d", c, entry)})
+			if !s.resync(0) {
+				return problems
+			}
+		}
+	}
+}
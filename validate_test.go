@@ -0,0 +1,131 @@
+package thyme
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestValidateStream(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		in       string
+		times    []string
+		interval time.Duration
+		problems int
+	}{
+		{
+			name:  "valid",
+			in:    `{"Version":1,"Snapshots":[{"Time":"2024-03-04T09:00:00Z","Windows":[],"Active":0},{"Time":"2024-03-04T09:01:00Z","Windows":[],"Active":0}]}`,
+			times: []string{"2024-03-04T09:00:00Z", "2024-03-04T09:01:00Z"},
+		},
+		{
+			name:     "interval",
+			in:       `{"Version":1,"Interval":30000000000,"Snapshots":[{"Time":"2024-03-04T09:00:00Z","Windows":[],"Active":0}]}`,
+			times:    []string{"2024-03-04T09:00:00Z"},
+			interval: 30 * time.Second,
+		},
+		{
+			name:     "malformed entry",
+			in:       `{"Version":1,"Interval":30000000000,"Snapshots":[{"Time":"2024-03-04T09:00:00Z","Windows":[],"Active":0},{"Time":"2024-03-04T09:01:00Z","Windows":[},{"Time":"2024-03-04T09:02:00Z","Windows":[],"Active":0}]}`,
+			times:    []string{"2024-03-04T09:00:00Z", "2024-03-04T09:02:00Z"},
+			interval: 30 * time.Second,
+			problems: 1,
+		},
+		{
+			name:     "entry of the wrong type",
+			in:       `{"Version":1,"Snapshots":[{"Time":"yesterday","Windows":[],"Active":0},{"Time":"2024-03-04T09:02:00Z","Windows":[],"Active":0}]}`,
+			times:    []string{"2024-03-04T09:02:00Z"},
+			problems: 1,
+		},
+		{
+			name:     "truncated",
+			in:       `{"Version":1,"Snapshots":[{"Time":"2024-03-04T09:00:00Z","Windows":[],"Active":0},{"Time":"2024-03-04T09:01`,
+			times:    []string{"2024-03-04T09:00:00Z"},
+			problems: 1,
+		},
+		{
+			name:     "garbage between entries",
+			in:       `{"Version":1,"Snapshots":[{"Time":"2024-03-04T09:00:00Z","Windows":[],"Active":0} oops {"Time":"2024-03-04T09:01:00Z","Windows":[],"Active":0}]}`,
+			times:    []string{"2024-03-04T09:00:00Z", "2024-03-04T09:01:00Z"},
+			problems: 1,
+		},
+		{
+			name:     "entry longer than a read",
+			in:       `{"Version":1,"Snapshots":[{"Time":"2024-03-04T09:00:00Z","Windows":[{"ID":1,"Name":"` + strings.Repeat("x", 3*snapshotReadSize) + `"}],"Active":1},{"Time":"2024-03-04T09:01:00Z","Windows":[},{"Time":"2024-03-04T09:02:00Z","Windows":[],"Active":0}]}`,
+			times:    []string{"2024-03-04T09:00:00Z", "2024-03-04T09:02:00Z"},
+			problems: 1,
+		},
+		{
+			name:  "single snapshot",
+			in:    `{"Time":"2024-03-04T09:00:00Z","Windows":[],"Active":0}`,
+			times: []string{"2024-03-04T09:00:00Z"},
+		},
+		{
+			name:  "single snapshot starting with another field",
+			in:    `{"Active":1,"Time":"2024-03-04T09:00:00Z","Windows":[{"ID":1,"Name":"main.go - Code"}]}`,
+			times: []string{"2024-03-04T09:00:00Z"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			stream, problems, err := ValidateStream(strings.NewReader(tt.in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Read a byte at a time, the entries are cut off by the
+			// end of every read.
+			again, againProblems, err := ValidateStream(iotest.OneByteReader(strings.NewReader(tt.in)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(again.Snapshots) != len(stream.Snapshots) || len(againProblems) != len(problems) {
+				t.Errorf("read a byte at a time, got %d snapshots and problems %v, want %d and %v", len(again.Snapshots), againProblems, len(stream.Snapshots), problems)
+			}
+			if len(problems) != tt.problems {
+				t.Errorf("got problems %v, want %d", problems, tt.problems)
+			}
+			if stream.Interval != tt.interval {
+				t.Errorf("got interval %s, want %s", stream.Interval, tt.interval)
+			}
+			var times []string
+			for _, snap := range stream.Snapshots {
+				times = append(times, snap.Time.Format(time.RFC3339))
+			}
+			if strings.Join(times, " ") != strings.Join(tt.times, " ") {
+				t.Errorf("got snapshots taken at %v, want %v", times, tt.times)
+			}
+		})
+	}
+}
+
+// TestValidateStreamTruncatedGzip checks that the snapshots read from
+// a gzip file before it was cut off are kept.
+func TestValidateStreamTruncatedGzip(t *testing.T) {
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	fmt.Fprintf(zw, `{"Version":1,"Snapshots":[`)
+	for i := range 1000 {
+		if i > 0 {
+			fmt.Fprintf(zw, ",")
+		}
+		fmt.Fprintf(zw, `{"Time":"2024-03-04T09:%02d:%02dZ","Windows":[{"ID":%d,"Name":"main.go - Code"}],"Active":%[3]d}`, i/60%60, i%60, i)
+	}
+	fmt.Fprintf(zw, "]}")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	stream, problems, err := ValidateStream(bytes.NewReader(b.Bytes()[:b.Len()*3/4]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(stream.Snapshots); n == 0 || n == 1000 {
+		t.Errorf("got %d snapshots, want some of the 1000", n)
+	}
+	if len(problems) == 0 {
+		t.Error("got no problems, want the file reported as truncated")
+	}
+}
@@ -0,0 +1,272 @@
+package thyme
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+func init() {
+	RegisterTracker("wayland", NewWaylandTracker)
+}
+
+// WaylandTracker tracks application usage in Wayland sessions, where the X11 utilities used by the LinuxTracker can't
+// see native windows. It asks the compositor for its windows, via kdotool on KDE Plasma and via the "Window Calls"
+// extension's D-Bus interface on GNOME Shell. If neither works, it falls back to the LinuxTracker, which still sees
+// applications running under XWayland.
+type WaylandTracker struct{}
+
+var _ Tracker = (*WaylandTracker)(nil)
+
+func NewWaylandTracker() Tracker {
+	return &WaylandTracker{}
+}
+
+func (t *WaylandTracker) Deps() string {
+	return `
+Wayland compositors don't let ordinary programs list windows, so Thyme needs a helper for your desktop:
+* KDE Plasma: install kdotool (https://github.com/jinliu/kdotool)
+* GNOME Shell: install the "Window Calls" extension (https://extensions.gnome.org/extension/4724/window-calls/)
+  and make sure gdbus is on your PATH (it ships with GLib)
+
+If neither is available, Thyme falls back to the X11 utilities listed by the Linux tracker, which only see XWayland
+windows:
+* xdpyinfo
+* xwininfo
+* xdotool
+* wmctrl
+* xprintidle (optional, used to detect when you're away from the keyboard outside GNOME)
+
+Thyme can only track a graphical session, whose compositor is named by the WAYLAND_DISPLAY environment variable. Over
+SSH, run Thyme from within the session instead.
+
+Note: this command prints out this message regardless of whether the dependencies are already installed.
+`
+}
+
+// Programs returns the helpers for each desktop, none of which is
+// required on its own, and the X11 utilities of the LinuxTracker,
+// which it falls back to.
+func (t *WaylandTracker) Programs() []Program {
+	programs := []Program{
+		{Name: "kdotool", Optional: true, Purpose: "list windows on KDE Plasma"},
+		{Name: "gdbus", Optional: true, Purpose: "list windows on GNOME Shell"},
+	}
+	for _, p := range linuxPrograms {
+		p.Optional = true
+		p.Purpose += " under XWayland"
+		programs = append(programs, p)
+	}
+	return programs
+}
+
+func (t *WaylandTracker) Snap(ctx context.Context) (*Snapshot, error) {
+	if err := CheckDisplay(); err != nil {
+		return nil, err
+	}
+	var errs []string
+	for _, snap := range []func(context.Context) (*Snapshot, error){snapKWin, snapGNOMEShell} {
+		s, err := snap(ctx)
+		if err == nil {
+			s.Idle = waylandIdle(ctx)
+			s.Locked = sessionLocked(ctx)
+			s.AssignMonitors()
+			s.DetectFullScreen()
+			s.MarkPartial()
+			logSnapshot("wayland", s)
+			return s, nil
+		}
+		if ctx.Err() != nil {
+			// There's no point in trying the other compositors.
+			return nil, err
+		}
+		errs = append(errs, err.Error())
+	}
+	slog.Debug("falling back to XWayland", "errors", strings.Join(errs, "; "))
+	s, err := (&LinuxTracker{}).Snap(ctx)
+	if err != nil {
+		errs = append(errs, err.Error())
+		return nil, fmt.Errorf("could not list Wayland windows: %s", strings.Join(errs, "; "))
+	}
+	return s, nil
+}
+
+// snapKWin takes a snapshot of the windows managed by KWin using kdotool. KWin identifies windows with UUIDs, so
+// window IDs are hashes of those.
+func snapKWin(ctx context.Context) (*Snapshot, error) {
+	kdotool := func(args ...string) (string, error) {
+		out, err := commandOutput(ctx, "kdotool", args...)
+		if err != nil {
+			return "", fmt.Errorf("kdotool failed with error: %s. Try running `kdotool %s` to diagnose.", err, strings.Join(args, " "))
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+
+	out, err := kdotool("search", "--name", ".")
+	if err != nil {
+		return nil, err
+	}
+	currentDesktop, err := kdotool("get_desktop")
+	if err != nil {
+		return nil, err
+	}
+	activeUUID, err := kdotool("getactivewindow")
+	if err != nil {
+		return nil, err
+	}
+
+	var windows []*Window
+	var visible []int64
+	for _, uuid := range strings.Fields(out) {
+		name, err := kdotool("getwindowname", uuid)
+		if err != nil {
+			return nil, err
+		}
+		w := Window{ID: hash(uuid), Name: name}
+		if pid, err := kdotool("getwindowpid", uuid); err == nil {
+			if n, err := strconv.Atoi(pid); err == nil && n > 0 {
+				w.PID, w.Process = n, procName(n)
+			}
+		}
+		if desktop, err := kdotool("get_desktop_for_window", uuid); err == nil {
+			if d, err := strconv.ParseInt(desktop, 10, 64); err == nil {
+				w.Desktop = d
+			}
+			if desktop == currentDesktop || w.IsSticky() {
+				visible = append(visible, w.ID)
+			}
+		}
+		if !w.IsSystem() {
+			windows = append(windows, &w)
+		}
+	}
+
+	return &Snapshot{Windows: windows, Active: hash(activeUUID), Visible: visible, Time: time.Now()}, nil
+}
+
+// gnomeWindow is a window as described by the "Window Calls" GNOME Shell extension. Recent versions of the extension
+// no longer include the title in the window list, in which case it's fetched separately.
+type gnomeWindow struct {
+	ID                 int64  `json:"id"`
+	PID                int    `json:"pid"`
+	Title              string `json:"title"`
+	WMClass            string `json:"wm_class"`
+	Focus              bool   `json:"focus"`
+	InCurrentWorkspace bool   `json:"in_current_workspace"`
+}
+
+// snapGNOMEShell takes a snapshot of the windows managed by GNOME Shell through the "Window Calls" extension.
+func snapGNOMEShell(ctx context.Context) (*Snapshot, error) {
+	gdbus := func(method string, args ...string) (string, error) {
+		cmd := append([]string{"call", "--session", "--dest", "org.gnome.Shell",
+			"--object-path", "/org/gnome/Shell/Extensions/Windows",
+			"--method", "org.gnome.Shell.Extensions.Windows." + method}, args...)
+		out, err := commandOutput(ctx, "gdbus", cmd...)
+		if err != nil {
+			return "", fmt.Errorf("gdbus failed with error: %s. Try running `gdbus %s` to diagnose.", err, strings.Join(cmd, " "))
+		}
+		return parseGVariantString(string(out))
+	}
+
+	out, err := gdbus("List")
+	if err != nil {
+		return nil, err
+	}
+	var gwins []gnomeWindow
+	if err := json.Unmarshal([]byte(out), &gwins); err != nil {
+		return nil, fmt.Errorf("could not parse window list from GNOME Shell: %s", err)
+	}
+
+	var windows []*Window
+	var visible []int64
+	var active int64
+	for _, gw := range gwins {
+		name := gw.Title
+		if name == "" {
+			if title, err := gdbus("GetTitle", strconv.FormatInt(gw.ID, 10)); err == nil {
+				name = title
+			}
+		}
+		if name == "" {
+			name = gw.WMClass
+		}
+		w := Window{ID: gw.ID, Name: name}
+		if gw.PID > 0 {
+			w.PID, w.Process = gw.PID, procName(gw.PID)
+		}
+		if w.IsSystem() {
+			continue
+		}
+		windows = append(windows, &w)
+		if gw.InCurrentWorkspace {
+			visible = append(visible, w.ID)
+		}
+		if gw.Focus {
+			active = w.ID
+		}
+	}
+
+	return &Snapshot{Windows: windows, Active: active, Visible: visible, Time: time.Now()}, nil
+}
+
+// waylandIdle returns how long the session has gone without user input. It asks Mutter's idle monitor, which exists on
+// GNOME, and otherwise falls back to xIdle. It returns zero if neither works.
+func waylandIdle(ctx context.Context) time.Duration {
+	out, err := commandOutput(ctx, "gdbus", "call", "--session", "--dest", "org.gnome.Mutter.IdleMonitor",
+		"--object-path", "/org/gnome/Mutter/IdleMonitor/Core",
+		"--method", "org.gnome.Mutter.IdleMonitor.GetIdletime")
+	if err != nil {
+		return xIdle(ctx, xDisplay{retry: DefaultRetry})
+	}
+	ms, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(string(out)), "(uint64 "), ",)"), 10, 64)
+	if err != nil {
+		return xIdle(ctx, xDisplay{retry: DefaultRetry})
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// parseGVariantString extracts the string from the single-string tuple that `gdbus call` prints, e.g. ('foo',).
+func parseGVariantString(out string) (string, error) {
+	out = strings.TrimSpace(out)
+	if !strings.HasPrefix(out, "('") || !strings.HasSuffix(out, "',)") {
+		return "", fmt.Errorf("could not parse gdbus output %q", out)
+	}
+	quoted := out[len("('") : len(out)-len("',)")]
+
+	var b strings.Builder
+	for i := 0; i < len(quoted); i++ {
+		if quoted[i] != '\\' || i+1 == len(quoted) {
+			b.WriteByte(quoted[i])
+			continue
+		}
+		i++
+		switch quoted[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		case 'u':
+			if i+4 < len(quoted) {
+				if r, err := strconv.ParseUint(quoted[i+1:i+5], 16, 32); err == nil {
+					b.WriteRune(rune(r))
+					i += 4
+					continue
+				}
+			}
+			b.WriteByte('u')
+		default:
+			b.WriteByte(quoted[i])
+		}
+	}
+	if !utf8.ValidString(b.String()) {
+		return "", fmt.Errorf("gdbus output is not valid UTF-8: %q", out)
+	}
+	return b.String(), nil
+}
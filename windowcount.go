@@ -0,0 +1,125 @@
+package thyme
+
+import (
+	"math"
+	"time"
+)
+
+// WindowCounts are how many windows the user typically had open and
+// visible, a measure of clutter. Averages are weighted by how long
+// each snapshot stands for, and rounded to a tenth.
+type WindowCounts struct {
+	// Open and Visible are the average numbers of open and visible
+	// windows.
+	Open, Visible float64
+
+	// OpenPeak is the largest number of windows open in a snapshot,
+	// and OpenPeakTime the time of the first snapshot that had as
+	// many.
+	OpenPeak     int
+	OpenPeakTime time.Time
+
+	// Days are the averages of each day with snapshots, in
+	// chronological order.
+	Days []*DayWindowCount
+
+	// Hours are the averages during each hour of the day with
+	// snapshots, over all the days, from midnight on.
+	Hours []*HourWindowCount
+}
+
+// DayWindowCount is the number of windows open and visible on a day,
+// on average, and the largest number open at once.
+type DayWindowCount struct {
+	// Date is the day, as midnight UTC.
+	Date time.Time
+
+	Open, Visible float64
+	OpenPeak      int
+}
+
+// HourWindowCount is the number of windows open and visible on average
+// during an hour of the day.
+type HourWindowCount struct {
+	Hour          int
+	Open, Visible float64
+}
+
+// YMax returns a round number at least as large as all the averages of
+// open windows, to scale charts of them by.
+func (c *WindowCounts) YMax() int {
+	var most float64
+	for _, d := range c.Days {
+		most = max(most, d.Open)
+	}
+	for _, h := range c.Hours {
+		most = max(most, h.Open)
+	}
+	// An even maximum keeps the middle of the scale whole.
+	n := int(math.Ceil(most))
+	return max(n+n%2, 2)
+}
+
+// windowTally accumulates the number of windows in snapshots, weighted
+// by the time they stand for.
+type windowTally struct {
+	open, visible, weight float64
+	peak                  int
+}
+
+func (t *windowTally) add(open, visible int, d time.Duration) {
+	t.open += float64(open) * d.Seconds()
+	t.visible += float64(visible) * d.Seconds()
+	t.weight += d.Seconds()
+	t.peak = max(t.peak, open)
+}
+
+// averages returns the average numbers of open and visible windows,
+// rounded to a tenth.
+func (t *windowTally) averages() (open, visible float64) {
+	if t.weight == 0 {
+		return 0, 0
+	}
+	return math.Round(10*t.open/t.weight) / 10, math.Round(10*t.visible/t.weight) / 10
+}
+
+// NewWindowCounts returns the counts of the windows open and visible
+// in stream, which must be in chronological order.
+func NewWindowCounts(stream *Stream) *WindowCounts {
+	counts := &WindowCounts{}
+	durations := sampleDurations(stream)
+	var all windowTally
+	var hours [24]windowTally
+	var days []int
+	byDay := make(map[int]*windowTally)
+	for i, snap := range stream.Snapshots {
+		open, visible := len(snap.Windows), len(snap.Visible)
+		all.add(open, visible, durations[i])
+		hours[snap.Time.Hour()].add(open, visible, durations[i])
+		day := civilDay(snap.Time)
+		if byDay[day] == nil {
+			byDay[day] = &windowTally{}
+			days = append(days, day)
+		}
+		byDay[day].add(open, visible, durations[i])
+		if open > counts.OpenPeak {
+			counts.OpenPeak, counts.OpenPeakTime = open, snap.Time
+		}
+	}
+
+	counts.Open, counts.Visible = all.averages()
+	for h := range hours {
+		if hours[h].weight == 0 {
+			continue
+		}
+		c := &HourWindowCount{Hour: h}
+		c.Open, c.Visible = hours[h].averages()
+		counts.Hours = append(counts.Hours, c)
+	}
+	for _, day := range days {
+		d := &DayWindowCount{Date: civilDate(day), OpenPeak: byDay[day].peak}
+		d.Open, d.Visible = byDay[day].averages()
+		counts.Days = append(counts.Days, d)
+	}
+	return counts
+}
@@ -3,7 +3,13 @@
 package thyme
 
 import (
+	"context"
+	"encoding/xml"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 	"unsafe"
@@ -21,6 +27,7 @@ type WindowsTracker struct{}
 var _ Tracker = (*WindowsTracker)(nil)
 
 func NewWindowsTracker() Tracker {
+	setDPIAware()
 	return &WindowsTracker{}
 }
 
@@ -38,10 +45,64 @@ var (
 	procIsWindow                 = user.NewProc("IsWindow")
 	procIsWindowVisible          = user.NewProc("IsWindowVisible")
 	procGetWindowThreadProcessId = user.NewProc("GetWindowThreadProcessId")
+	procGetLastInputInfo         = user.NewProc("GetLastInputInfo")
+	procGetWindowRect            = user.NewProc("GetWindowRect")
+	procMonitorFromWindow        = user.NewProc("MonitorFromWindow")
+	procGetMonitorInfo           = user.NewProc("GetMonitorInfoW")
+	procOpenInputDesktop         = user.NewProc("OpenInputDesktop")
+	procCloseDesktop             = user.NewProc("CloseDesktop")
+	procEnumChildWindows         = user.NewProc("EnumChildWindows")
+
+	kernel                         = syscall.NewLazyDLL("kernel32.dll")
+	procGetTickCount               = kernel.NewProc("GetTickCount")
+	procOpenProcess                = kernel.NewProc("OpenProcess")
+	procCloseHandle                = kernel.NewProc("CloseHandle")
+	procQueryFullProcessImageNameW = kernel.NewProc("QueryFullProcessImageNameW")
+	procGetPackageFullName         = kernel.NewProc("GetPackageFullName")
+	procGetPackagePathByFullName   = kernel.NewProc("GetPackagePathByFullName")
+
+	shlwapi                  = syscall.NewLazyDLL("shlwapi.dll")
+	procSHLoadIndirectString = shlwapi.NewProc("SHLoadIndirectString")
+
+	procSetProcessDpiAwarenessContext = user.NewProc("SetProcessDpiAwarenessContext")
+	shcore                            = syscall.NewLazyDLL("shcore.dll")
+	procGetDpiForMonitor              = shcore.NewProc("GetDpiForMonitor")
 )
 
+// dpiAwarenessContextPerMonitorAwareV2 is the DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE_V2 handle.
+const dpiAwarenessContextPerMonitorAwareV2 = ^uintptr(3) // -4
+
+// setDPIAware makes the process aware of the DPI of each monitor, so that window and monitor geometry are in physical
+// pixels on every monitor, rather than scaled to 96 DPI in ways that differ between monitors of different scales, and
+// GetDpiForMonitor reports the actual DPI. It does nothing before Windows 10 version 1703, which lacks the API.
+func setDPIAware() {
+	if procSetProcessDpiAwarenessContext.Find() != nil {
+		return
+	}
+	procSetProcessDpiAwarenessContext.Call(dpiAwarenessContextPerMonitorAwareV2)
+}
+
+// mdtEffectiveDPI makes GetDpiForMonitor return the DPI the monitor is scaled to.
+const mdtEffectiveDPI = 0
+
+// getMonitorScale returns the scale factor of the monitor of the provided handle: its effective DPI over the 96 DPI of
+// an unscaled one. It returns zero if it can't be determined, e.g. before Windows 8.1.
+func getMonitorScale(hmonitor uintptr) float64 {
+	if procGetDpiForMonitor.Find() != nil {
+		return 0
+	}
+	var dpiX, dpiY uint32
+	if hr, _, _ := procGetDpiForMonitor.Call(hmonitor, mdtEffectiveDPI, uintptr(unsafe.Pointer(&dpiX)), uintptr(unsafe.Pointer(&dpiY))); hr != 0 || dpiX == 0 {
+		return 0
+	}
+	return float64(dpiX) / 96
+}
+
 func (t *WindowsTracker) Deps() string {
-	return "Nothing, Ready to Go!"
+	return `Nothing, Ready to Go!
+
+Telling UWP apps such as Mail or Calculator apart, rather than counting them all as ApplicationFrameHost, requires
+Windows 8.1 or later.`
 }
 
 // getWindowTitle returns a title of a window of the provided system window handle
@@ -60,6 +121,249 @@ func getWindowID(window uintptr) int64 {
 	return int64(id)
 }
 
+// getWindowPID returns the ID of the process that created the window, or zero if it can't be determined.
+func getWindowPID(window uintptr) int {
+	var pid uint32
+	procGetWindowThreadProcessId.Call(window, uintptr(unsafe.Pointer(&pid)))
+	return int(pid)
+}
+
+// processQueryLimitedInformation is the PROCESS_QUERY_LIMITED_INFORMATION access right.
+const processQueryLimitedInformation = 0x1000
+
+// getProcessName returns the file name of the executable of the process pid (e.g. "chrome.exe"), or an empty string
+// if it can't be determined.
+func getProcessName(pid int) string {
+	if pid == 0 {
+		return ""
+	}
+	h, _, _ := procOpenProcess.Call(processQueryLimitedInformation, 0, uintptr(pid))
+	if h == 0 {
+		return ""
+	}
+	defer procCloseHandle.Call(h)
+	buf := make([]uint16, syscall.MAX_LONG_PATH)
+	size := uint32(len(buf))
+	if r, _, _ := procQueryFullProcessImageNameW.Call(h, 0, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size))); r == 0 {
+		return ""
+	}
+	return filepath.Base(syscall.UTF16ToString(buf[:size]))
+}
+
+// applicationFrameHost is the process that owns the windows of UWP apps, whose content comes from a child window
+// owned by the app's own process.
+const applicationFrameHost = "ApplicationFrameHost.exe"
+
+// uwpCoreWindow returns the child window showing the content of the UWP app framed by a window of the provided system
+// window handle, which belongs to the ApplicationFrameHost.exe process hostPID. It returns zero if there is none,
+// e.g. while the app is suspended.
+func uwpCoreWindow(window uintptr, hostPID int) uintptr {
+	currentChildSearch = &childSearch{hostPID: hostPID}
+	procEnumChildWindows.Call(window, enumChildWindowsCallback, 0)
+	child := currentChildSearch.child
+	currentChildSearch = nil
+	return child
+}
+
+// childSearch is the state of one call to uwpCoreWindow.
+type childSearch struct {
+	hostPID int
+	child   uintptr
+}
+
+// errorInsufficientBuffer is the ERROR_INSUFFICIENT_BUFFER error code.
+const errorInsufficientBuffer = 122
+
+// getPackageFullName returns the full name of the package of the process pid (e.g.
+// "Microsoft.WindowsCalculator_10.2103.8.0_x64__8wekyb3d8bbwe"), or an empty string if it isn't packaged or the
+// package can't be determined.
+func getPackageFullName(pid int) string {
+	if pid == 0 || procGetPackageFullName.Find() != nil {
+		return ""
+	}
+	h, _, _ := procOpenProcess.Call(processQueryLimitedInformation, 0, uintptr(pid))
+	if h == 0 {
+		return ""
+	}
+	defer procCloseHandle.Call(h)
+	var length uint32
+	if r, _, _ := procGetPackageFullName.Call(h, uintptr(unsafe.Pointer(&length)), 0); r != errorInsufficientBuffer {
+		return ""
+	}
+	buf := make([]uint16, length)
+	if r, _, _ := procGetPackageFullName.Call(h, uintptr(unsafe.Pointer(&length)), uintptr(unsafe.Pointer(&buf[0]))); r != 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(buf)
+}
+
+// getPackagePath returns the directory the package called fullName is installed in, or an empty string if it can't
+// be determined.
+func getPackagePath(fullName string) string {
+	if procGetPackagePathByFullName.Find() != nil {
+		return ""
+	}
+	name, err := syscall.UTF16PtrFromString(fullName)
+	if err != nil {
+		return ""
+	}
+	var length uint32
+	if r, _, _ := procGetPackagePathByFullName.Call(uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(&length)), 0); r != errorInsufficientBuffer {
+		return ""
+	}
+	buf := make([]uint16, length)
+	if r, _, _ := procGetPackagePathByFullName.Call(uintptr(unsafe.Pointer(name)), uintptr(unsafe.Pointer(&length)), uintptr(unsafe.Pointer(&buf[0]))); r != 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(buf)
+}
+
+// appxManifest holds the parts of the AppxManifest.xml file of a package that name it.
+type appxManifest struct {
+	Identity struct {
+		Name string `xml:"Name,attr"`
+	} `xml:"Identity"`
+	DisplayName string `xml:"Properties>DisplayName"`
+}
+
+// packageNames caches the results of packageDisplayName, which reads files, by package full name. It is only used
+// while enumerating windows, and so is guarded by enumMu.
+var packageNames = make(map[string]string)
+
+// packageDisplayName returns the name under which the package called fullName is shown to users (e.g. "Calculator"),
+// as declared in its manifest. If that can't be read, it falls back to the package name (e.g.
+// "Microsoft.WindowsCalculator"). It returns an empty string if fullName is.
+func packageDisplayName(fullName string) string {
+	if fullName == "" {
+		return ""
+	}
+	if name, ok := packageNames[fullName]; ok {
+		return name
+	}
+	name, _, _ := strings.Cut(fullName, "_")
+	if b, err := os.ReadFile(filepath.Join(getPackagePath(fullName), "AppxManifest.xml")); err == nil {
+		var m appxManifest
+		if err := xml.Unmarshal(b, &m); err == nil && m.DisplayName != "" {
+			if !strings.HasPrefix(m.DisplayName, "ms-resource:") {
+				name = m.DisplayName
+			} else if s := loadPackageResource(fullName, m.Identity.Name, m.DisplayName); s != "" {
+				name = s
+			}
+		}
+	}
+	packageNames[fullName] = name
+	return name
+}
+
+// loadPackageResource returns the string that the "ms-resource:" URI res refers to in the resources of the package
+// with the provided full and short names, or an empty string if it can't be loaded.
+func loadPackageResource(fullName, name, res string) string {
+	if procSHLoadIndirectString.Find() != nil {
+		return ""
+	}
+	// Manifests usually abbreviate the URI, leaving out the package, and for strings in the default resource file,
+	// its name.
+	path := strings.TrimPrefix(res, "ms-resource:")
+	switch {
+	case strings.HasPrefix(path, "//"):
+	case strings.HasPrefix(path, "/"):
+		path = "//" + name + path
+	case strings.Contains(path, "/"):
+		path = "//" + name + "/" + path
+	default:
+		path = "//" + name + "/Resources/" + path
+	}
+	source, err := syscall.UTF16PtrFromString("@{" + fullName + "?ms-resource:" + path + "}")
+	if err != nil {
+		return ""
+	}
+	buf := make([]uint16, 256)
+	if r, _, _ := procSHLoadIndirectString.Call(uintptr(unsafe.Pointer(source)), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0); r != 0 {
+		return ""
+	}
+	return syscall.UTF16ToString(buf)
+}
+
+// rect mirrors the RECT struct used by GetWindowRect.
+type rect struct {
+	left, top, right, bottom int32
+}
+
+// getWindowRect returns the position and size of a window of the provided system window handle. All four are zero
+// if the window's geometry can't be retrieved.
+func getWindowRect(window uintptr) (x, y, w, h int) {
+	var r rect
+	if ok, _, _ := procGetWindowRect.Call(window, uintptr(unsafe.Pointer(&r))); ok == 0 {
+		return 0, 0, 0, 0
+	}
+	return int(r.left), int(r.top), int(r.right - r.left), int(r.bottom - r.top)
+}
+
+// monitorInfoEx mirrors the MONITORINFOEXW struct used by GetMonitorInfoW.
+type monitorInfoEx struct {
+	cbSize    uint32
+	rcMonitor rect
+	rcWork    rect
+	dwFlags   uint32
+	szDevice  [32]uint16
+}
+
+// monitorDefaultToNearest makes MonitorFromWindow return the monitor closest to windows that aren't on any monitor.
+const monitorDefaultToNearest = 2
+
+// getWindowMonitor returns the monitor that contains the largest part of a window of the provided system window
+// handle, or nil if it can't be determined.
+func getWindowMonitor(window uintptr) *Monitor {
+	hmonitor, _, _ := procMonitorFromWindow.Call(window, monitorDefaultToNearest)
+	if hmonitor == 0 {
+		return nil
+	}
+	info := monitorInfoEx{cbSize: uint32(unsafe.Sizeof(monitorInfoEx{}))}
+	if ok, _, _ := procGetMonitorInfo.Call(hmonitor, uintptr(unsafe.Pointer(&info))); ok == 0 {
+		return nil
+	}
+	r := info.rcMonitor
+	return &Monitor{
+		Name:   syscall.UTF16ToString(info.szDevice[:]),
+		X:      int(r.left),
+		Y:      int(r.top),
+		Width:  int(r.right - r.left),
+		Height: int(r.bottom - r.top),
+		Scale:  getMonitorScale(hmonitor),
+	}
+}
+
+// lastInputInfo mirrors the LASTINPUTINFO struct used by GetLastInputInfo.
+type lastInputInfo struct {
+	cbSize uint32
+	dwTime uint32
+}
+
+// getIdleTime returns how long the system has gone without user input. It returns zero if it can't be determined.
+func getIdleTime() time.Duration {
+	info := lastInputInfo{cbSize: uint32(unsafe.Sizeof(lastInputInfo{}))}
+	if ok, _, _ := procGetLastInputInfo.Call(uintptr(unsafe.Pointer(&info))); ok == 0 {
+		return 0
+	}
+	now, _, _ := procGetTickCount.Call()
+	// Both tick counts wrap around every ~49.7 days, which uint32 arithmetic accounts for.
+	return time.Duration(uint32(now)-info.dwTime) * time.Millisecond
+}
+
+// desktopSwitchDesktop is the DESKTOP_SWITCHDESKTOP access right.
+const desktopSwitchDesktop = 0x0100
+
+// isScreenLocked returns true if the screen is locked, in which case the desktop receiving user input is the secure
+// desktop of the lock screen, which can't be opened.
+func isScreenLocked() bool {
+	desktop, _, _ := procOpenInputDesktop.Call(0, 0, desktopSwitchDesktop)
+	if desktop == 0 {
+		return true
+	}
+	procCloseDesktop.Call(desktop)
+	return false
+}
+
 // windowsIgnore will return true for titles of windows that are likely internal to windows itself
 // and not the applications we care to monitor.
 func windowsIgnore(title string) bool {
@@ -74,51 +378,139 @@ func windowsIgnore(title string) bool {
 	return false
 }
 
-func (t *WindowsTracker) Snap() (snap *Snapshot, err error) {
-	var allWindows []*Window
-	var visible []int64
-	var active int64
+// windowsEnum accumulates the windows found by one call to EnumWindows.
+type windowsEnum struct {
+	activeTitle  string
+	allWindows   []*Window
+	visible      []int64
+	active       int64
+	monitors     []*Monitor
+	seenMonitors map[string]bool
+	err          error
+}
 
-	var cbId uintptr = 888
+// enumWindowsCookie is passed to enumWindowsCallback by EnumWindows, to
+// check that it is being called as expected.
+const enumWindowsCookie uintptr = 888
 
-	activeWindow, _, _ := procGetForegroundWindow.Call()
-	activeTitle := getWindowTitle(activeWindow)
+var (
+	// enumMu serializes calls to EnumWindows, so that
+	// enumWindowsCallback can find the enumeration it is part of in
+	// currentEnum. EnumWindows calls the callback on the calling thread
+	// before returning.
+	enumMu      sync.Mutex
+	currentEnum *windowsEnum
+
+	// currentChildSearch is the search for the content of a UWP app
+	// that enumChildWindowsCallback is part of, also guarded by
+	// enumMu.
+	currentChildSearch *childSearch
 
-	cb := syscall.NewCallback(func(hwnd syscall.Handle, lparam uintptr) uintptr {
-		if lparam != cbId {
-			err = fmt.Errorf("lparam does not match what callback expected; received (%d), expected (%d)", lparam, cbId)
+	// enumWindowsCallback is created once and for all because the
+	// number of callbacks a program can create is limited, and they
+	// are never released.
+	enumWindowsCallback = syscall.NewCallback(func(hwnd syscall.Handle, lparam uintptr) uintptr {
+		if lparam != enumWindowsCookie {
+			currentEnum.err = fmt.Errorf("lparam does not match what callback expected; received (%d), expected (%d)", lparam, enumWindowsCookie)
 			return 0
 		}
-		b, _, _ := procIsWindow.Call(uintptr(hwnd))
-		if b != 0 {
-			currentTitle := getWindowTitle(uintptr(hwnd))
-			currentId := getWindowID(uintptr(hwnd))
-			// Skip windows that are in a process where we already have a visible window
-			for _, visibleId := range visible {
-				if currentId == visibleId {
-					return 1
-				}
-			}
-			if !windowsIgnore(currentTitle) {
-				if activeTitle == currentTitle {
-					active = currentId
-				}
-				v, _, _ := procIsWindowVisible.Call(uintptr(hwnd))
-				if v != 0 {
-					visible = append(visible, currentId)
-				}
-				allWindows = append(allWindows, &Window{ID: currentId, Name: currentTitle})
-			}
+		return currentEnum.visit(hwnd)
+	})
+
+	// enumChildWindowsCallback stops at the first child window that
+	// belongs to another process than the host of the UWP app.
+	enumChildWindowsCallback = syscall.NewCallback(func(hwnd syscall.Handle, lparam uintptr) uintptr {
+		if pid := getWindowPID(uintptr(hwnd)); pid != 0 && pid != currentChildSearch.hostPID {
+			currentChildSearch.child = uintptr(hwnd)
+			return 0
 		}
-		return 1 // continue enumeration
+		return 1
 	})
+)
 
-	procEnumWindows.Call(cb, cbId)
+// visit records the window hwnd, and returns 1 to continue the
+// enumeration. Windows of UWP apps are recorded as belonging to the
+// app rather than to ApplicationFrameHost.exe.
+func (e *windowsEnum) visit(hwnd syscall.Handle) uintptr {
+	b, _, _ := procIsWindow.Call(uintptr(hwnd))
+	if b == 0 {
+		return 1
+	}
+	currentTitle := getWindowTitle(uintptr(hwnd))
+	if windowsIgnore(currentTitle) {
+		return 1
+	}
+	currentId := getWindowID(uintptr(hwnd))
+	pid := getWindowPID(uintptr(hwnd))
+	process := getProcessName(pid)
+	var app string
+	if strings.EqualFold(process, applicationFrameHost) {
+		if core := uwpCoreWindow(uintptr(hwnd), pid); core != 0 {
+			currentId, pid = getWindowID(core), getWindowPID(core)
+			process = getProcessName(pid)
+			app = packageDisplayName(getPackageFullName(pid))
+		}
+	}
+	// Skip windows that are in a process where we already have a visible window
+	for _, visibleId := range e.visible {
+		if currentId == visibleId {
+			return 1
+		}
+	}
+	if e.activeTitle == currentTitle {
+		e.active = currentId
+	}
+	v, _, _ := procIsWindowVisible.Call(uintptr(hwnd))
+	if v != 0 {
+		e.visible = append(e.visible, currentId)
+	}
+	x, y, w, h := getWindowRect(uintptr(hwnd))
+	window := &Window{ID: currentId, Name: currentTitle, X: x, Y: y, Width: w, Height: h, App: app}
+	if pid != 0 {
+		window.PID, window.Process = pid, process
+	}
+	if m := getWindowMonitor(uintptr(hwnd)); m != nil {
+		window.Monitor = m.Name
+		if !e.seenMonitors[m.Name] {
+			e.seenMonitors[m.Name] = true
+			e.monitors = append(e.monitors, m)
+		}
+	}
+	e.allWindows = append(e.allWindows, window)
+	return 1 // continue enumeration
+}
 
-	return &Snapshot{
-		Time:    time.Now(),
-		Windows: allWindows,
-		Active:  active,
-		Visible: visible,
-	}, err
+// Snap only checks ctx before it starts, since it makes no calls that
+// could block. Concurrent calls take turns enumerating the windows.
+func (t *WindowsTracker) Snap(ctx context.Context) (*Snapshot, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	activeWindow, _, _ := procGetForegroundWindow.Call()
+	e := &windowsEnum{
+		activeTitle:  getWindowTitle(activeWindow),
+		seenMonitors: make(map[string]bool),
+	}
+
+	enumMu.Lock()
+	currentEnum = e
+	procEnumWindows.Call(enumWindowsCallback, enumWindowsCookie)
+	currentEnum = nil
+	enumMu.Unlock()
+
+	snap := &Snapshot{
+		Time:     time.Now(),
+		Windows:  e.allWindows,
+		Active:   e.active,
+		Visible:  e.visible,
+		Idle:     getIdleTime(),
+		Monitors: e.monitors,
+		Locked:   isScreenLocked(),
+	}
+	snap.AssignMonitors()
+	snap.DetectFullScreen()
+	snap.MarkPartial()
+	logSnapshot("windows", snap)
+	return snap, e.err
 }
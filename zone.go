@@ -0,0 +1,118 @@
+package thyme
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LocalZone returns the name of the local time zone, e.g.
+// "Europe/Paris", to record in Snapshot.Zone. When the name can't be
+// found, e.g. on Windows, it returns the offset from UTC at t instead,
+// e.g. "+02:00".
+func LocalZone(t time.Time) string {
+	name := strings.TrimPrefix(os.Getenv("TZ"), ":")
+	if name == "" {
+		// /etc/localtime links to the zone's file in the tz database on
+		// Linux and macOS.
+		if link, err := os.Readlink("/etc/localtime"); err == nil {
+			if i := strings.LastIndex(link, "zoneinfo/"); i >= 0 {
+				name = link[i+len("zoneinfo/"):]
+			}
+		}
+	}
+	if name != "" && name != "Local" {
+		if _, err := loadZone(name); err == nil {
+			return name
+		}
+	}
+	return offsetZone(t)
+}
+
+// offsetZone returns the offset from UTC of t as a zone name, or "UTC"
+// if there is none.
+func offsetZone(t time.Time) string {
+	if _, offset := t.Zone(); offset == 0 {
+		return "UTC"
+	}
+	return t.Format("-07:00")
+}
+
+// zones caches the locations loaded by loadZone, which would otherwise
+// read the tz database for every snapshot.
+var zones sync.Map
+
+// loadZone returns the location called name, which is either the name
+// of a zone of the tz database or an offset such as "+02:00".
+func loadZone(name string) (*time.Location, error) {
+	if loc, ok := zones.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t, perr := time.Parse("-07:00", name)
+		if perr != nil {
+			return nil, err
+		}
+		loc = t.Location()
+	}
+	zones.Store(name, loc)
+	return loc, nil
+}
+
+// Location returns the time zone the snapshot was taken in. Snapshots
+// recorded before the zone was, or in a zone that can't be loaded, are
+// assumed to have been taken in UTC.
+func (s *Snapshot) Location() *time.Location {
+	if s.Zone == "" {
+		return time.UTC
+	}
+	loc, err := loadZone(s.Zone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// InRecordedZones returns a copy of the stream in which the time of
+// each snapshot is in the zone it was taken in, so that days and hours
+// are those of the clock of the machine that recorded it, e.g. when
+// traveling.
+func (s *Stream) InRecordedZones() *Stream {
+	converted := *s
+	converted.Snapshots = make([]*Snapshot, 0, len(s.Snapshots))
+	for _, snap := range s.Snapshots {
+		c := *snap
+		loc := c.Location()
+		c.Time = c.Time.In(loc)
+		if !c.EndTime.IsZero() {
+			c.EndTime = c.EndTime.In(loc)
+		}
+		converted.Snapshots = append(converted.Snapshots, &c)
+	}
+	return &converted
+}
+
+// NormalizeZones returns a copy of the stream whose snapshot times are
+// in UTC, so that the streams of machines in different time zones read
+// alike once merged. The zone of each snapshot is kept in its Zone;
+// snapshots that have none, recorded before the zone was, get the
+// offset of their time, which is the one of the machine that recorded
+// them unless the time was converted since.
+func (s *Stream) NormalizeZones() *Stream {
+	normalized := *s
+	normalized.Snapshots = make([]*Snapshot, 0, len(s.Snapshots))
+	for _, snap := range s.Snapshots {
+		c := *snap
+		if c.Zone == "" {
+			c.Zone = offsetZone(c.Time)
+		}
+		c.Time = c.Time.UTC()
+		if !c.EndTime.IsZero() {
+			c.EndTime = c.EndTime.UTC()
+		}
+		normalized.Snapshots = append(normalized.Snapshots, &c)
+	}
+	return &normalized
+}